@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+)
+
+// petctl runs commands against a store, which is the client of a petserver outside of tests
+type petctl struct {
+	store  pet.Storer
+	out    io.Writer
+	errOut io.Writer
+	// format is the output format, one of table, json or yaml
+	format string
+}
+
+// get shows the pets with the given IDs
+func (c *petctl) get(ctx context.Context, petIDs []uint32) error {
+	pets := make([]pet.Pet, 0, len(petIDs))
+	for _, petID := range petIDs {
+		read, err := c.store.ReadPet(ctx, petID)
+		if err != nil {
+			return err
+		}
+		pets = append(pets, *read)
+	}
+	if len(pets) == 1 {
+		return writePet(c.out, c.format, &pets[0])
+	}
+	return writePets(c.out, c.format, pets)
+}
+
+// create creates the pet read from in, showing it as created
+func (c *petctl) create(ctx context.Context, in io.Reader) error {
+	newPet, err := readPet(in)
+	if err != nil {
+		return err
+	}
+	if err = c.store.CreatePet(ctx, newPet); err != nil {
+		return err
+	}
+	return writePet(c.out, c.format, newPet)
+}
+
+// update creates or replaces the pet with the given ID with the pet read from in
+func (c *petctl) update(ctx context.Context, petID uint32, in io.Reader, pre pet.Precondition) error {
+	newPet, err := readPet(in)
+	if err != nil {
+		return err
+	}
+	if err = c.store.UpdatePet(ctx, petID, newPet, pre); err != nil {
+		return err
+	}
+	newPet.ID = petID
+	return writePet(c.out, c.format, newPet)
+}
+
+// delete deletes the pets with the given IDs, noting the pets that did not exist
+func (c *petctl) delete(ctx context.Context, petIDs []uint32, pre pet.Precondition) error {
+	for _, petID := range petIDs {
+		deleted, err := c.store.DeletePet(ctx, petID, pre)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			fmt.Fprintf(c.errOut, "Pet %d did not exist\n", petID)
+		}
+	}
+	return nil
+}
+
+// list shows a page of the pets matching query, or every page if all is set. The
+// cursor of the next page is noted when there is one.
+func (c *petctl) list(ctx context.Context, query pet.ListQuery, all bool) error {
+	var pets []pet.Pet
+	for {
+		page, err := c.store.ListPets(ctx, query)
+		if err != nil {
+			return err
+		}
+		pets = append(pets, page.Pets...)
+		if page.NextCursor == "" {
+			break
+		}
+		if !all {
+			fmt.Fprintf(c.errOut, "More pets follow, list them with --cursor %s\n", page.NextCursor)
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	return writePets(c.out, c.format, pets)
+}
+
+// importPets writes the pets of a JSON array read from in, in batches of at most
+// pet.MaxBatchSize. Pets with an ID are upserted and other pets created. The failed
+// pets of best effort batches are noted, and the first failure is returned.
+func (c *petctl) importPets(ctx context.Context, in io.Reader, atomic bool) error {
+	var pets []pet.Pet
+	if err := json.NewDecoder(in).Decode(&pets); err != nil {
+		return pet.ErrorEf(pet.ErrInvalidInput, err, "Invalid pets, should be a JSON array of pets")
+	}
+	var (
+		imported int
+		firstErr error
+	)
+	for start := 0; start < len(pets); start += pet.MaxBatchSize {
+		end := start + pet.MaxBatchSize
+		if end > len(pets) {
+			end = len(pets)
+		}
+		ops := make([]pet.BatchOperation, 0, end-start)
+		for i := range pets[start:end] {
+			p := &pets[start+i]
+			if p.ID == 0 {
+				ops = append(ops, pet.BatchOperation{Op: pet.BatchCreate, Pet: p})
+			} else {
+				ops = append(ops, pet.BatchOperation{Op: pet.BatchUpsert, ID: p.ID, Pet: p})
+			}
+		}
+		results, err := c.store.Batch(ctx, ops, atomic)
+		if err != nil {
+			fmt.Fprintf(c.errOut, "Imported %d of %d pets\n", imported, len(pets))
+			return err
+		}
+		for i, result := range results {
+			if result.Err != nil {
+				fmt.Fprintf(c.errOut, "Pet %d not imported: %v\n", start+i, describe(result.Err))
+				if firstErr == nil {
+					firstErr = result.Err
+				}
+				continue
+			}
+			imported++
+		}
+	}
+	fmt.Fprintf(c.errOut, "Imported %d of %d pets\n", imported, len(pets))
+	return firstErr
+}
+
+// exportPets writes every pet to out as a JSON array, in order of ID
+func (c *petctl) exportPets(ctx context.Context, out io.Writer) error {
+	pets := []pet.Pet{}
+	query := pet.ListQuery{Sort: "id", Limit: pet.MaxListLimit}
+	for {
+		page, err := c.store.ListPets(ctx, query)
+		if err != nil {
+			return err
+		}
+		pets = append(pets, page.Pets...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	return writeJSON(out, pets)
+}
+
+// readPet decodes a single pet from JSON
+func readPet(in io.Reader) (*pet.Pet, error) {
+	var p pet.Pet
+	if err := json.NewDecoder(in).Decode(&p); err != nil {
+		return nil, pet.ErrorEf(pet.ErrInvalidInput, err, "Invalid pet")
+	}
+	return &p, nil
+}
+
+// describe returns the message of an error followed by those of its causes, along
+// with its invalid fields
+func describe(err error) string {
+	message := pet.CauseChain(err)
+	var petErr *pet.Error
+	if errors.As(err, &petErr) {
+		for _, field := range petErr.Fields {
+			message += fmt.Sprintf("; %s %s", field.Field, field.Message)
+		}
+	}
+	return message
+}
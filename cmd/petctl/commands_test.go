@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPetctl(store pet.Storer, format string) (*petctl, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &petctl{store: store, out: out, errOut: ioutil.Discard, format: format}, out
+}
+
+func TestPetctl_ExportImport(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	ctx := context.Background()
+	source := pet.NewMemStore()
+	for i := 0; i < pet.MaxListLimit+1; i++ {
+		require.NoError(t, source.CreatePet(ctx, &pet.Pet{Name: "Nemo", Species: "Clownfish"}))
+	}
+	exporter, exported := newPetctl(source, "json")
+	target := pet.NewMemStore()
+	importer, _ := newPetctl(target, "json")
+
+	// when
+	exportErr := exporter.exportPets(ctx, exported)
+	importErr := importer.importPets(ctx, exported, true)
+
+	// then
+	assert.NoError(exportErr)
+	assert.NoError(importErr)
+	page, err := target.ListPets(ctx, pet.ListQuery{Sort: "-id", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(uint32(pet.MaxListLimit+1), page.Pets[0].ID, "Every page of pets should be exported and imported")
+}
+
+func TestPetctl_ImportBestEffort(t *testing.T) {
+	// given
+	ctl, _ := newPetctl(pet.NewMemStore(), "table")
+	in := strings.NewReader(`[{"name": "Nemo", "species": "Clownfish"}, {"name": "Dory", "species": "Blue Tang", "owner_id": 7}]`)
+
+	// when
+	err := ctl.importPets(context.Background(), in, false)
+
+	// then
+	tassert.Equal(t, 6, exitCode(err), "Pet of a missing owner should fail the import with the exit code of a reference violation")
+	_, readErr := ctl.store.ReadPet(context.Background(), 1)
+	tassert.NoError(t, readErr, "Valid pet should be imported")
+}
+
+func TestPetctl_Output(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "json", expected: "{\n  \"id\": 1,\n  \"name\": \"Nemo\",\n  \"species\": \"Clownfish\",\n  \"owner\": \"Marlin\",\n  \"extra\": null,\n  \"version\": 1\n}\n"},
+		{format: "yaml", expected: "id: 1\nname: Nemo\nspecies: Clownfish\nowner: Marlin\nextra: {}\nversion: 1\n"},
+		{format: "table", expected: "ID  NAME  SPECIES    OWNER   OWNER ID  VERSION  EXTRA\n1   Nemo  Clownfish  Marlin            1        \n"},
+	}
+	for _, test := range tests {
+		// given
+		store := pet.NewMemStore()
+		require.NoError(t, store.CreatePet(context.Background(), &pet.Pet{Name: "Nemo", Species: "Clownfish", Owner: "Marlin"}))
+		ctl, out := newPetctl(store, test.format)
+
+		// when
+		err := ctl.get(context.Background(), []uint32{1})
+
+		// then
+		require.NoError(t, err)
+		tassert.Equal(t, test.expected, out.String(), "Output as %s", test.format)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tassert.Equal(t, 3, exitCode(pet.ErrNotFoundSentinel))
+	tassert.Equal(t, 1, exitCode(pet.Errorf(pet.ErrUnknown, "Boom")))
+	tassert.Equal(t, 1, exitCode(io.ErrUnexpectedEOF))
+}
+
+func TestDescribe(t *testing.T) {
+	// given
+	cause := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	err := pet.ErrorEf(pet.ErrUnknown, &url.Error{Op: "Get", URL: "http://localhost:9412/api/pet/1", Err: cause}, "Request to localhost:9412 failed")
+	invalid := pet.Errorf(pet.ErrInvalidInput, "Invalid pet data").AddField("name", "is required")
+
+	// when
+	described, describedInvalid := describe(err), describe(invalid)
+
+	// then
+	tassert.Equal(t, `Request to localhost:9412 failed: Get "http://localhost:9412/api/pet/1": dial tcp: connection refused`, described,
+		"Causes should follow the message, each once")
+	tassert.Equal(t, "Invalid pet data; name is required", describedInvalid)
+}
+
+func TestPetctl_DescribesDecodeErrorsOnce(t *testing.T) {
+	// given
+	ctl, _ := newPetctl(pet.NewMemStore(), "json")
+
+	// when
+	_, readErr := readPet(strings.NewReader(`{"name": 7}`))
+	importErr := ctl.importPets(context.Background(), strings.NewReader(`{"name": "Nemo"}`), true)
+
+	// then
+	tassert.Equal(t, "Invalid pet: json: cannot unmarshal number into Go struct field Pet.name of type string", describe(readErr))
+	tassert.Equal(t, "Invalid pets, should be a JSON array of pets: json: cannot unmarshal object into Go value of type []pet.Pet",
+		describe(importErr))
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+	"github.service.anz/go/samplerest/pkg/petclient"
+
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	server  = kingpin.Flag("server", "URL of the petserver").Short('s').Envar("PETCTL_SERVER").Default("http://localhost:4852").String()
+	output  = kingpin.Flag("output", "Output format, one of {table, json, yaml}").Short('o').Default("table").Enum("table", "json", "yaml")
+	timeout = kingpin.Flag("timeout", "Maximum time of each request to the server, 0 is unlimited").Default("30s").Duration()
+	retries = kingpin.Flag("retries", "How often reads and unconditional writes are retried when the server is unavailable").Default("2").Int()
+
+	getCmd = kingpin.Command("get", "Show pets")
+	getIDs = getCmd.Arg("id", "IDs of the pets").Required().Uint32List()
+
+	createCmd  = kingpin.Command("create", "Create a pet from JSON, allocating an ID if it has none")
+	createFile = createCmd.Flag("file", "File holding the pet, - for stdin").Short('f').Default("-").String()
+
+	updateCmd     = kingpin.Command("update", "Create or replace a pet from JSON")
+	updateID      = updateCmd.Arg("id", "ID of the pet").Required().Uint32()
+	updateFile    = updateCmd.Flag("file", "File holding the pet, - for stdin").Short('f').Default("-").String()
+	updateIfMatch = updateCmd.Flag("if-match", "Only replace the pet if it has this version").Uint64()
+
+	deleteCmd     = kingpin.Command("delete", "Delete pets")
+	deleteIDs     = deleteCmd.Arg("id", "IDs of the pets").Required().Uint32List()
+	deleteIfMatch = deleteCmd.Flag("if-match", "Only delete a pet if it has this version").Uint64()
+
+	listCmd        = kingpin.Command("list", "List pets")
+	listOwner      = listCmd.Flag("owner", "Only list pets with this owner name").String()
+	listOwnerID    = listCmd.Flag("owner-id", "Only list pets of the owner with this ID").Uint32()
+	listSpecies    = listCmd.Flag("species", "Only list pets of this species").String()
+	listNamePrefix = listCmd.Flag("name-prefix", "Only list pets whose name starts with this").String()
+	listSort       = listCmd.Flag("sort", "Order of the pets, one of {id, name, species, owner}, prefixed with - for descending order").String()
+	listLimit      = listCmd.Flag("limit", "Maximum number of pets in a page").Int()
+	listCursor     = listCmd.Flag("cursor", "Cursor of the page to list, from a previous listing").String()
+	listAll        = listCmd.Flag("all", "List every page").Bool()
+
+	importCmd        = kingpin.Command("import", "Write a JSON array of pets, as written by export. Pets with an ID are created or replaced, others are created.")
+	importFile       = importCmd.Flag("file", "File holding the pets, - for stdin").Short('f').Default("-").String()
+	importBestEffort = importCmd.Flag("best-effort", "Write every pet that can be written, instead of writing each batch of pets atomically").Bool()
+
+	exportCmd  = kingpin.Command("export", "Write every pet as a JSON array, which import reads")
+	exportFile = exportCmd.Flag("file", "File to write the pets to, - for stdout").Short('f').Default("-").String()
+)
+
+// exitCodes maps the codes of pet errors to the exit codes of petctl. Other failures exit with 1.
+var exitCodes = map[int]int{
+	pet.ErrInvalidInput:         2,
+	pet.ErrUnsupportedMediaType: 2,
+	pet.ErrTooLarge:             2,
+	pet.ErrNotAcceptable:        2,
+	pet.ErrNotFound:             3,
+	pet.ErrDuplicate:            4,
+	pet.ErrConflict:             5,
+	pet.ErrPatchTestFailed:      5,
+	pet.ErrReference:            6,
+	pet.ErrCanceled:             7,
+	pet.ErrTimeout:              7,
+	pet.ErrUnsupported:          8,
+}
+
+// exitCode returns the exit code of a command that failed with err
+func exitCode(err error) int {
+	var petErr *pet.Error
+	if errors.As(err, &petErr) {
+		if code, ok := exitCodes[petErr.Code]; ok {
+			return code
+		}
+	}
+	return 1
+}
+
+// openInput opens the named file, or stdin for "-"
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return os.Stdin, nil
+	}
+	return os.Open(name)
+}
+
+// createOutput creates the named file, or returns stdout for "-"
+func createOutput(name string) (io.WriteCloser, error) {
+	if name == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(name)
+}
+
+// run runs the selected command against store
+func run(ctx context.Context, command string, store pet.Storer) error {
+	ctl := &petctl{store: store, out: os.Stdout, errOut: os.Stderr, format: *output}
+	switch command {
+	case getCmd.FullCommand():
+		return ctl.get(ctx, *getIDs)
+	case createCmd.FullCommand():
+		in, err := openInput(*createFile)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return ctl.create(ctx, in)
+	case updateCmd.FullCommand():
+		in, err := openInput(*updateFile)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return ctl.update(ctx, *updateID, in, versionPrecondition(*updateIfMatch))
+	case deleteCmd.FullCommand():
+		return ctl.delete(ctx, *deleteIDs, versionPrecondition(*deleteIfMatch))
+	case listCmd.FullCommand():
+		query := pet.ListQuery{
+			Owner:      *listOwner,
+			OwnerID:    *listOwnerID,
+			Species:    *listSpecies,
+			NamePrefix: *listNamePrefix,
+			Sort:       *listSort,
+			Limit:      *listLimit,
+			Cursor:     *listCursor,
+		}
+		return ctl.list(ctx, query, *listAll)
+	case importCmd.FullCommand():
+		in, err := openInput(*importFile)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		return ctl.importPets(ctx, in, !*importBestEffort)
+	case exportCmd.FullCommand():
+		out, err := createOutput(*exportFile)
+		if err != nil {
+			return err
+		}
+		if err = ctl.exportPets(ctx, out); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+	return fmt.Errorf("unknown command %s", command)
+}
+
+// versionPrecondition requires a pet to have the given version, unless it is 0
+func versionPrecondition(version uint64) pet.Precondition {
+	if version == 0 {
+		return pet.Precondition{}
+	}
+	return pet.Precondition{IfMatch: []uint64{version}}
+}
+
+func main() {
+	command := kingpin.Parse()
+	client, err := petclient.New(petclient.Config{BaseURL: *server, Timeout: *timeout, Retries: *retries})
+	kingpin.FatalIfError(err, "Invalid server")
+	if err = run(context.Background(), command, client); err != nil {
+		fmt.Fprintf(os.Stderr, "petctl: %s\n", describe(err))
+		os.Exit(exitCode(err))
+	}
+}
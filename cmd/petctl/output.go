@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+
+	"gopkg.in/yaml.v2"
+)
+
+// writePet writes a single pet in the given format
+func writePet(out io.Writer, format string, p *pet.Pet) error {
+	switch format {
+	case "json":
+		return writeJSON(out, p)
+	case "yaml":
+		return writeYAML(out, yamlPet(p))
+	}
+	return writeTable(out, []pet.Pet{*p})
+}
+
+// writePets writes a list of pets in the given format
+func writePets(out io.Writer, format string, pets []pet.Pet) error {
+	switch format {
+	case "json":
+		if pets == nil {
+			pets = []pet.Pet{}
+		}
+		return writeJSON(out, pets)
+	case "yaml":
+		items := make([]yaml.MapSlice, 0, len(pets))
+		for i := range pets {
+			items = append(items, yamlPet(&pets[i]))
+		}
+		return writeYAML(out, items)
+	}
+	return writeTable(out, pets)
+}
+
+// writeJSON writes v as indented JSON
+func writeJSON(out io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// writeYAML writes v as YAML
+func writeYAML(out io.Writer, v interface{}) error {
+	encoder := yaml.NewEncoder(out)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// yamlPet returns the fields of a pet named and ordered as in its JSON form
+func yamlPet(p *pet.Pet) yaml.MapSlice {
+	item := yaml.MapSlice{
+		{Key: "id", Value: p.ID},
+		{Key: "name", Value: p.Name},
+		{Key: "species", Value: p.Species},
+		{Key: "owner", Value: p.Owner},
+	}
+	if p.OwnerID != 0 {
+		item = append(item, yaml.MapItem{Key: "owner_id", Value: p.OwnerID})
+	}
+	item = append(item, yaml.MapItem{Key: "extra", Value: p.Extra})
+	if p.Version != 0 {
+		item = append(item, yaml.MapItem{Key: "version", Value: p.Version})
+	}
+	return item
+}
+
+// writeTable writes pets as aligned columns, one pet per row. Extra data is
+// summarised as its keys.
+func writeTable(out io.Writer, pets []pet.Pet) error {
+	table := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tNAME\tSPECIES\tOWNER\tOWNER ID\tVERSION\tEXTRA")
+	for _, p := range pets {
+		ownerID := ""
+		if p.OwnerID != 0 {
+			ownerID = fmt.Sprint(p.OwnerID)
+		}
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", p.ID, p.Name, p.Species, p.Owner, ownerID, p.Version, extraKeys(p.Extra))
+	}
+	return table.Flush()
+}
+
+// extraKeys returns the sorted keys of extra data, separated by commas
+func extraKeys(extra map[string]interface{}) string {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
@@ -1,47 +1,377 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.service.anz/go/samplerest/pkg/metrics"
 	"github.service.anz/go/samplerest/pkg/pet"
+	"github.service.anz/go/samplerest/pkg/petgrpc"
+	"github.service.anz/go/samplerest/pkg/trace"
 
 	"github.com/go-chi/chi"
 	mw "github.com/go-chi/chi/middleware"
+	_ "github.com/lib/pq" // registers the "postgres" database/sql driver
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	storeimpl = kingpin.Flag("datastore", "Storage used, one of {mem, pq}").Short('d').Default("mem").Enum("mem", "pq")
+	storeimpl = kingpin.Flag("datastore", "Storage used, one of {mem, pq, file}").Short('d').Default("mem").Enum("mem", "pq", "file")
 	port      = kingpin.Flag("port", "port").Short('p').Default("4852").Int()
+	idgen     = kingpin.Flag("id-generator", "How IDs of pets created without one are allocated, one of {sequential, random, snowflake}").Default("sequential").Enum("sequential", "random", "snowflake")
+	node      = kingpin.Flag("snowflake-node", "Node number of this server for snowflake IDs, unique among servers sharing a store").Default("0").Uint32()
+	migrate   = kingpin.Flag("migrate-owners", "Move the free text owner names of pets to owners before serving").Bool()
+
+	species     = kingpin.Flag("species", "Species pets may have, repeat for several. Any species is allowed if none is given").Strings()
+	extraSchema = kingpin.Flag("extra-schema", "JSON Schema file constraining the extra data of pets").ExistingFile()
+	maxBodySize = kingpin.Flag("max-body-size", "Maximum size of request bodies, larger bodies are rejected").Default("1MB").Bytes()
+	strictJSON  = kingpin.Flag("strict-json", "Reject request bodies with unknown fields, in every media type").Bool()
+
+	dataDir       = kingpin.Flag("data-dir", "Directory the file datastore keeps its snapshot and log in").Default("data").String()
+	fsync         = kingpin.Flag("fsync", "When the file datastore flushes its log to disk, one of {always, interval, never}").Default("always").Enum("always", "interval", "never")
+	fsyncInterval = kingpin.Flag("fsync-interval", "How often the file datastore flushes its log with --fsync interval").Default("1s").Duration()
+	snapshotEvery = kingpin.Flag("snapshot-every", "Number of writes the file datastore logs before compacting them into a snapshot").Default("10000").Int()
+
+	eventBuffer = kingpin.Flag("event-buffer", "Number of pet events kept for clients resuming the event stream").Default("1024").Int()
+	grpcPort    = kingpin.Flag("grpc-port", "Port serving the pet service over gRPC, 0 disables gRPC").Default("0").Int()
+
+	pqConn            = kingpin.Flag("pq-conn", "Postgres connection string").Envar("PETSERVER_PQ_CONN").Default("postgres://localhost/pets?sslmode=disable").String()
+	pqMaxOpenConns    = kingpin.Flag("pq-max-open-conns", "Maximum number of open postgres connections, 0 is unlimited").Default("10").Int()
+	pqMaxIdleConns    = kingpin.Flag("pq-max-idle-conns", "Maximum number of idle postgres connections").Default("2").Int()
+	pqConnMaxLifetime = kingpin.Flag("pq-conn-max-lifetime", "Maximum time a postgres connection is reused, 0 is forever").Default("30m").Duration()
+
+	logLevel  = kingpin.Flag("log-level", "Minimum level logged, one of {debug, info, warn, error}").Default("info").Enum("debug", "info", "warn", "error")
+	logFormat = kingpin.Flag("log-format", "Format of log lines, one of {json, text}").Default("json").Enum("json", "text")
+
+	traceExporter = kingpin.Flag("trace-exporter", "Where spans are exported, one of {none, stdout, otlp}").Default("none").Enum("none", "stdout", "otlp")
+	otlpEndpoint  = kingpin.Flag("otlp-endpoint", "URL of the OTLP/HTTP collector receiving spans").Default("http://localhost:4318/v1/traces").String()
+
+	readTimeout       = kingpin.Flag("read-timeout", "Maximum time to read a request including its body, 0 is unlimited").Default("15s").Duration()
+	readHeaderTimeout = kingpin.Flag("read-header-timeout", "Maximum time to read request headers, 0 uses the read timeout").Default("5s").Duration()
+	writeTimeout      = kingpin.Flag("write-timeout", "Maximum time from the end of reading request headers to the end of writing the response, 0 is unlimited").Default("30s").Duration()
+	idleTimeout       = kingpin.Flag("idle-timeout", "Maximum time a keep-alive connection waits for the next request, 0 uses the read timeout").Default("120s").Duration()
+	requestTimeout    = kingpin.Flag("request-timeout", "Deadline of the datastore operations of a request, 0 is unlimited").Default("10s").Duration()
+	shutdownTimeout   = kingpin.Flag("shutdown-timeout", "Maximum time to wait for in-flight requests to finish on SIGINT or SIGTERM").Default("30s").Duration()
 )
 
+// createIDGenerator returns the generator selected by flags, or nil to use the store's default
+func createIDGenerator() (pet.IDGenerator, error) {
+	switch *idgen {
+	case "random":
+		return pet.RandomIDs{}, nil
+	case "snowflake":
+		ids, err := pet.NewSnowflakeIDs(*node)
+		if err != nil {
+			return nil, err
+		}
+		return ids, nil
+	}
+	return nil, nil
+}
+
 func createStore() (pet.Storer, error) {
+	ids, err := createIDGenerator()
+	if err != nil {
+		return nil, err
+	}
 	switch *storeimpl {
 	case "mem":
-		return &pet.MemStore{}, nil
+		return &pet.MemStore{IDs: ids}, nil
 	case "pq":
-		return nil, errors.New("postgres store not yet implemented")
+		store, err := pet.OpenPQStore(pet.PQConfig{
+			ConnString:      *pqConn,
+			MaxOpenConns:    *pqMaxOpenConns,
+			MaxIdleConns:    *pqMaxIdleConns,
+			ConnMaxLifetime: *pqConnMaxLifetime,
+			IDs:             ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case "file":
+		return openFileStore(ids)
+	}
+	return nil, errors.New("Unknown store implementation, must be one of 'mem', 'pq' or 'file'")
+}
+
+// syncPolicies maps the values of the fsync flag to file store sync policies
+var syncPolicies = map[string]pet.SyncPolicy{
+	"always":   pet.SyncAlways,
+	"interval": pet.SyncInterval,
+	"never":    pet.SyncNever,
+}
+
+// openFileStore opens the file store configured by flags, logging what it recovered
+func openFileStore(ids pet.IDGenerator) (pet.Storer, error) {
+	store, err := pet.OpenFileStore(pet.FileConfig{
+		Dir:           *dataDir,
+		Sync:          syncPolicies[*fsync],
+		SyncInterval:  *fsyncInterval,
+		SnapshotEvery: *snapshotEvery,
+		IDs:           ids,
+	})
+	if err != nil {
+		return nil, err
+	}
+	recovery := store.Recovery()
+	entry := log.WithFields(log.Fields{
+		"pets":    recovery.Pets,
+		"owners":  recovery.Owners,
+		"records": recovery.Records,
+	})
+	if recovery.TruncatedBytes > 0 {
+		entry.WithField("truncated_bytes", recovery.TruncatedBytes).Warn("Cut off the torn tail of the datastore log")
+	}
+	entry.Info("Datastore recovered")
+	return store, nil
+}
+
+// createTraceExporter returns the span exporter selected by flags, or nil to not export spans
+func createTraceExporter() trace.Exporter {
+	switch *traceExporter {
+	case "stdout":
+		return trace.NewWriterExporter(os.Stdout)
+	case "otlp":
+		return trace.NewOTLPExporter(trace.OTLPConfig{
+			Endpoint:    *otlpEndpoint,
+			ServiceName: "petserver",
+			OnError:     func(err error) { log.Warnln(err) },
+		})
+	}
+	return nil
+}
+
+// withRequestTimeout sets a deadline on the context of every request, which
+// datastore operations give up at
+func withRequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// undocumentedRoutes are the routes left out of the OpenAPI document, by method and path
+var undocumentedRoutes = []string{"GET /metrics"}
+
+// newRouter sets up the middleware and every route of the server, with the owner routes
+// if owners is not nil. The event handler is returned to end its streams on shutdown.
+func newRouter(store pet.Storer, owners pet.OwnerStorer, hub *pet.EventHub, validator *pet.Validator,
+	registry *metrics.Registry, tracer *trace.Tracer) (chi.Router, *pet.EventHandler) {
+	router := chi.NewRouter()
+	router.Use(mw.RequestID)
+	router.Use(pet.RequestLogger(log.StandardLogger()))
+	router.Use(metrics.Middleware(registry))
+	router.Use(trace.Middleware(tracer))
+	// event streams outlive the request timeout, and end before the write timeout
+	events := pet.NewEventHandler(hub)
+	events.MaxDuration = *writeTimeout * 9 / 10
+	pet.SetupEventRoutes(router, events)
+	api := router.With(withRequestTimeout(*requestTimeout))
+	service := pet.NewPetService(store)
+	body := pet.BodyConfig{MaxSize: int64(*maxBodySize), DisallowUnknownFields: *strictJSON}
+	service.Body = body
+	service.Validator = validator
+	pet.SetupRoutes(api, service)
+	if owners != nil {
+		ownerService := pet.NewOwnerService(owners, store)
+		ownerService.Body = body
+		pet.SetupOwnerRoutes(api, ownerService)
+	}
+	pet.SetupHealthRoutes(api, pet.NewHealth(store))
+	api.Method("GET", "/metrics", registry)
+	pet.SetupOpenAPIRoutes(api, pet.NewOpenAPIHandler(router, service.Codecs, undocumentedRoutes...))
+	return router, events
+}
+
+// serve runs the server, and the gRPC server unless it is nil, until either fails or
+// SIGINT or SIGTERM is received. On a signal the servers stop accepting connections,
+// end their event streams, and wait up to the shutdown timeout for in-flight requests
+// to finish.
+func serve(server *http.Server, g *grpcServer) error {
+	errs := make(chan error, 2)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	if g != nil {
+		go func() {
+			errs <- g.server.Serve(g.listener)
+		}()
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Infof("Received %v, shutting down", sig)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if g != nil {
+		g.stop(ctx)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("could not finish in-flight requests: %v", err)
+	}
+	return nil
+}
+
+// grpcServer serves the pet service over gRPC
+type grpcServer struct {
+	server   *grpc.Server
+	pets     *petgrpc.Server
+	listener net.Listener
+}
+
+// newGRPCServer listens on the gRPC port, serving the pets of store and the events of hub
+func newGRPCServer(store pet.Storer, hub *pet.EventHub, validator *pet.Validator) (*grpcServer, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort))
+	if err != nil {
+		return nil, err
+	}
+	g := &grpcServer{
+		server:   grpc.NewServer(grpc.MaxRecvMsgSize(int(*maxBodySize))),
+		pets:     petgrpc.NewServer(store, hub),
+		listener: listener,
+	}
+	g.pets.Validator = validator
+	petgrpc.Register(g.server, g.pets)
+	return g, nil
+}
+
+// stop ends the event streams and waits for other calls to finish until ctx is done,
+// after which they are canceled
+func (g *grpcServer) stop(ctx context.Context) {
+	g.pets.Stop()
+	stopped := make(chan struct{})
+	go func() {
+		g.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("Could not finish in-flight gRPC calls")
+		g.server.Stop()
+	}
+}
+
+// createValidator returns the pet validator configured by flags
+func createValidator() (*pet.Validator, error) {
+	validator := &pet.Validator{Species: *species}
+	if *extraSchema != "" {
+		schema, err := pet.LoadSchema(*extraSchema)
+		if err != nil {
+			return nil, err
+		}
+		validator.ExtraSchema = schema
+	}
+	return validator, nil
+}
+
+// migrateOwners moves the owner names of pets to stored owners, see pet.MigrateOwners
+func migrateOwners(pets pet.Storer, owners pet.OwnerStorer) {
+	if owners == nil {
+		log.Fatal("Could not migrate owners, the datastore does not store owners")
+	}
+	migrated, err := pet.MigrateOwners(context.Background(), pets, owners)
+	if err != nil {
+		log.Fatalf("Could not migrate owners. %v", err)
+	}
+	log.WithField("pets", migrated).Info("Owners migrated")
+}
+
+// closeStore releases the resources held by a store implementing io.Closer
+func closeStore(store pet.Storer) {
+	closer, ok := store.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		log.Errorf("Could not close data storage. %v", err)
+	}
+}
+
+// setupLogging configures the standard logger from flags
+func setupLogging() {
+	level, err := log.ParseLevel(*logLevel)
+	if err != nil {
+		log.Fatalf("Invalid log level. %v", err)
+	}
+	log.SetLevel(level)
+	if *logFormat == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{})
 	}
-	return nil, errors.New("Unknown store implementation, must be either 'mem' or 'pq'")
 }
 
 func main() {
 	kingpin.Parse()
+	setupLogging()
 	store, err := createStore()
 	if err != nil {
 		log.Fatalf("Could not connect data storage. %v", err)
 	}
-	router := chi.NewRouter()
-	router.Use(mw.Logger)
-	service := pet.NewPetService(store)
-	pet.SetupRoutes(router, service)
+	registry := metrics.NewRegistry()
+	exporter := createTraceExporter()
+	tracer := trace.NewTracer(exporter)
+	hub := pet.NewEventHub(*eventBuffer)
+	store = pet.NewPublishingStore(pet.NewTracingStore(pet.NewMetricsStore(store, registry), tracer), hub)
+	// the decorators pass owner operations on, so that they are measured and traced too
+	owners, _ := pet.OwnerStore(store)
+	if *migrate {
+		migrateOwners(store, owners)
+	}
+	validator, err := createValidator()
+	if err != nil {
+		log.Fatalf("Could not set up validation. %v", err)
+	}
+	router, events := newRouter(store, owners, hub, validator, registry, tracer)
 	server := &http.Server{
-		Handler: router,
-		Addr:    fmt.Sprintf(":%d", *port),
+		Handler:           router,
+		Addr:              fmt.Sprintf(":%d", *port),
+		ReadTimeout:       *readTimeout,
+		ReadHeaderTimeout: *readHeaderTimeout,
+		WriteTimeout:      *writeTimeout,
+		IdleTimeout:       *idleTimeout,
+	}
+	// Shutdown does not cancel requests, so event streams are ended explicitly
+	server.RegisterOnShutdown(events.Stop)
+	var g *grpcServer
+	if *grpcPort != 0 {
+		if g, err = newGRPCServer(store, hub, validator); err != nil {
+			log.Fatalf("Could not listen for gRPC. %v", err)
+		}
+		log.Infoln("gRPC server listening on port", *grpcPort)
 	}
 	log.Infoln("Server listening on port", *port)
-	log.Fatal(server.ListenAndServe())
+	err = serve(server, g)
+	closeStore(store)
+	if closer, ok := exporter.(io.Closer); ok {
+		closer.Close()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infoln("Server stopped")
 }
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.service.anz/go/samplerest/pkg/metrics"
+	"github.service.anz/go/samplerest/pkg/pet"
+	"github.service.anz/go/samplerest/pkg/trace"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRouter_DescribesEveryRoute(t *testing.T) {
+	// given
+	store := pet.NewMemStore()
+	router, _ := newRouter(store, store, pet.NewEventHub(0), &pet.Validator{}, metrics.NewRegistry(), trace.NewTracer(nil))
+
+	// when
+	_, err := pet.NewOpenAPI(router, nil, undocumentedRoutes...)
+
+	// then
+	require.NoError(t, err, "Every route of the server should be described or left out explicitly")
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	tassert.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+}
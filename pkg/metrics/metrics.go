@@ -0,0 +1,264 @@
+// Package metrics records counters and histograms in process and exposes them
+// in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the histogram upper bounds, in seconds, suited to request latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector is a metric family that can be written in the text format
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metrics and serves them in the text format
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, existing := range r.collectors {
+		if existing.name() == c.name() {
+			panic(fmt.Sprintf("metrics: %s is already registered", c.name()))
+		}
+	}
+	r.collectors = append(r.collectors, c)
+}
+
+// NewCounter registers a counter with the given label names.
+// It panics if a metric of the same name is already registered.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{family: newFamily(name, help, labels)}
+	r.register(c)
+	return c
+}
+
+// NewHistogram registers a histogram with the given bucket upper bounds, in increasing
+// order, and label names. It panics if a metric of the same name is already registered.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{family: newFamily(name, help, labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+// Write writes all metrics in the text format, ordered by registration
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := append([]collector(nil), r.collectors...)
+	r.mu.Unlock()
+	buf := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(buf)
+	}
+	return buf.Flush()
+}
+
+// ServeHTTP responds with all metrics in the text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	_ = r.Write(w)
+}
+
+// family holds the series of a metric, one per combination of label values
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+	mu         sync.Mutex
+	series     map[string]interface{}
+	values     map[string][]string
+}
+
+func newFamily(name, help string, labels []string) family {
+	return family{metricName: name, help: help, labels: labels, series: map[string]interface{}{}, values: map[string][]string{}}
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+// get returns the series for the label values, creating it with create if it does not exist.
+// The caller must hold f.mu.
+func (f *family) get(labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels but %d values were given", f.metricName, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = create()
+		f.series[key] = s
+		f.values[key] = append([]string(nil), labelValues...)
+	}
+	return s
+}
+
+// keys returns the series keys in a stable order. The caller must hold f.mu.
+func (f *family) keys() []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (f *family) writeHeader(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.metricName, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.metricName, kind)
+}
+
+// labelString formats label pairs as {a="x",b="y"}, with any extra pair appended
+func (f *family) labelString(values []string, extra ...string) string {
+	var pairs []string
+	for i, label := range f.labels {
+		pairs = append(pairs, label+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Counter is a metric that only increases, such as a number of requests
+type Counter struct {
+	family
+}
+
+type counterSeries struct {
+	value float64
+}
+
+// Inc adds one to the series with the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds a non-negative amount to the series with the given label values
+func (c *Counter) Add(amount float64, labelValues ...string) {
+	if amount < 0 {
+		panic(fmt.Sprintf("metrics: counter %s cannot decrease", c.metricName))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(labelValues, func() interface{} { return &counterSeries{} }).(*counterSeries).value += amount
+}
+
+// Value returns the current value of the series with the given label values
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.(*counterSeries).value
+	}
+	return 0
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w, "counter")
+	for _, key := range c.keys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelString(c.values[key]), formatFloat(c.series[key].(*counterSeries).value))
+	}
+}
+
+// Histogram counts observations, such as request latencies, in configurable buckets
+type Histogram struct {
+	family
+	buckets []float64
+}
+
+type histogramSeries struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records a value in the series with the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(labelValues, func() interface{} {
+		return &histogramSeries{counts: make([]uint64, len(h.buckets))}
+	}).(*histogramSeries)
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+// Count returns the number of observations in the series with the given label values
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[strings.Join(labelValues, "\xff")]; ok {
+		return s.(*histogramSeries).count
+	}
+	return 0
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w, "histogram")
+	for _, key := range h.keys() {
+		s, values := h.series[key].(*histogramSeries), h.values[key]
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(values, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelString(values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelString(values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelString(values), s.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
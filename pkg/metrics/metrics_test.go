@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func TestCounterText(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	registry := NewRegistry()
+	counter := registry.NewCounter("jobs_total", "Number of jobs.\nDone or failed.", "queue", "result")
+	counter.Inc("default", "ok")
+	counter.Add(2, "default", "ok")
+	counter.Inc(`say "hi"\`, "failed")
+	var out bytes.Buffer
+
+	// when
+	err := registry.Write(&out)
+
+	// then
+	assert.NoError(err)
+	assert.Equal(`# HELP jobs_total Number of jobs.\nDone or failed.
+# TYPE jobs_total counter
+jobs_total{queue="default",result="ok"} 3
+jobs_total{queue="say \"hi\"\\",result="failed"} 1
+`, out.String())
+	assert.Equal(3.0, counter.Value("default", "ok"))
+	assert.Equal(0.0, counter.Value("other", "ok"), "Unknown series should be 0")
+}
+
+func TestHistogramText(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	registry := NewRegistry()
+	histogram := registry.NewHistogram("wait_seconds", "Time waited.", []float64{0.1, 1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(3)
+	var out bytes.Buffer
+
+	// when
+	err := registry.Write(&out)
+
+	// then
+	assert.NoError(err)
+	assert.Equal(`# HELP wait_seconds Time waited.
+# TYPE wait_seconds histogram
+wait_seconds_bucket{le="0.1"} 1
+wait_seconds_bucket{le="1"} 2
+wait_seconds_bucket{le="+Inf"} 3
+wait_seconds_sum 3.55
+wait_seconds_count 3
+`, out.String())
+	assert.Equal(uint64(3), histogram.Count())
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	// given
+	registry := NewRegistry()
+	registry.NewCounter("jobs_total", "Number of jobs.")
+
+	// when, then
+	tassert.Panics(t, func() { registry.NewHistogram("jobs_total", "Number of jobs.", DefaultBuckets) })
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	// given
+	counter := NewRegistry().NewCounter("jobs_total", "Number of jobs.", "queue")
+
+	// when, then
+	tassert.Panics(t, func() { counter.Inc("default", "extra") })
+}
+
+func TestMiddleware(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	registry := NewRegistry()
+	router := chi.NewRouter()
+	router.Use(Middleware(registry))
+	router.Route("/api/pet", func(r chi.Router) {
+		r.Route("/{id}", func(r chi.Router) {
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				if chi.URLParam(r, "id") == "0" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+			})
+		})
+	})
+	router.Method("GET", "/metrics", registry)
+
+	for _, path := range []string{"/api/pet/1", "/api/pet/2", "/api/pet/0", "/no/such/path"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code)
+	assert.Equal(ContentType, resp.Header().Get("Content-Type"))
+	body := resp.Body.String()
+	assert.Contains(body, `http_requests_total{route="/api/pet/{id}",method="GET",status="200"} 2`+"\n")
+	assert.Contains(body, `http_requests_total{route="/api/pet/{id}",method="GET",status="404"} 1`+"\n")
+	assert.Contains(body, `http_requests_total{route="unmatched",method="GET",status="404"} 1`+"\n")
+	assert.Contains(body, `http_request_duration_seconds_count{route="/api/pet/{id}",method="GET",status="200"} 2`+"\n")
+	assert.False(strings.Contains(body, "/no/such/path"), "Unmatched paths should not be labelled")
+}
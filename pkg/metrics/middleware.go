@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.service.anz/go/samplerest/pkg/route"
+
+	mw "github.com/go-chi/chi/middleware"
+)
+
+// unmatchedRoute labels requests no route matched, so that arbitrary paths do not
+// each create a series
+const unmatchedRoute = "unmatched"
+
+// Middleware counts requests and records their latency, labelled by chi route
+// pattern, method and status code. It must be used on a chi router.
+func Middleware(registry *Registry) func(http.Handler) http.Handler {
+	requests := registry.NewCounter("http_requests_total",
+		"Number of HTTP requests handled.", "route", "method", "status")
+	durations := registry.NewHistogram("http_request_duration_seconds",
+		"Time taken to handle HTTP requests.", DefaultBuckets, "route", "method", "status")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := mw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+			status := ww.Status()
+			if status == 0 {
+				// nothing was written, net/http responds 200 OK
+				status = http.StatusOK
+			}
+			pattern := route.Pattern(r)
+			if pattern == "" {
+				pattern = unmatchedRoute
+			}
+			labels := []string{pattern, r.Method, strconv.Itoa(status)}
+			requests.Inc(labels...)
+			durations.Observe(time.Since(start).Seconds(), labels...)
+		})
+	}
+}
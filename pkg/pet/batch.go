@@ -0,0 +1,221 @@
+package pet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+)
+
+// MaxBatchSize is the maximum number of operations in a batch request
+const MaxBatchSize = 1000
+
+// Modes of a batch request, selected with the mode query parameter
+const (
+	batchModeAtomic     = "atomic"
+	batchModeBestEffort = "best_effort"
+)
+
+// BatchItemResult is the outcome of a single operation of a batch request, with the
+// status the operation would have been answered with as a request of its own
+type BatchItemResult struct {
+	Status int `json:"status"`
+	// Code, Detail and Errors describe a failed operation as in a Problem
+	Code   string       `json:"code,omitempty"`
+	Detail string       `json:"detail,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+	// Pet is the pet written by a create or upsert
+	Pet *Pet `json:"pet,omitempty"`
+}
+
+// BatchResponse is the body of a batch response, with a result for every operation
+type BatchResponse struct {
+	Results []BatchItemResult `json:"results"`
+}
+
+// BatchPets handles a POST request applying an array of create, upsert and delete
+// operations. The batch is applied atomically unless the mode query parameter is
+// best_effort, in which case every operation that can be applied is. Either way the
+// response holds the outcome of each operation.
+func (ps *Service) BatchPets(w http.ResponseWriter, r *http.Request) {
+	atomic, err := readBatchMode(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	ops, err := ps.readBatchBody(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	results, err := ps.applyBatch(r.Context(), ops, atomic)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	response := BatchResponse{Results: make([]BatchItemResult, len(results))}
+	for i, result := range results {
+		response.Results[i] = newBatchItemResult(r, ops[i], result)
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, response)
+}
+
+// applyBatch validates the pets of a batch and passes the valid operations to the
+// store. An atomic batch fails without touching the store if any pet is invalid.
+func (ps *Service) applyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+	valid := make([]BatchOperation, 0, len(ops))
+	validIndexes := make([]int, 0, len(ops))
+	for i, op := range ops {
+		if err := ps.validateBatchPet(op); err != nil {
+			if atomic {
+				return nil, batchFailure(i, err)
+			}
+			results[i].Err = err
+			continue
+		}
+		valid = append(valid, op)
+		validIndexes = append(validIndexes, i)
+	}
+	stored, err := ps.store.Batch(ctx, valid, atomic)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range stored {
+		results[validIndexes[i]] = result
+	}
+	return results, nil
+}
+
+// validateBatchPet validates the pet written by an operation, naming invalid fields
+// relative to the operation
+func (ps *Service) validateBatchPet(op BatchOperation) error {
+	if op.Op == BatchDelete || op.Pet == nil {
+		return nil
+	}
+	return withFieldPrefix(ps.Validator.Validate(op.Pet), "pet.")
+}
+
+// withFieldPrefix returns a copy of err with prefix added to the names of its invalid
+// fields, leaving err itself untouched in case its creator reuses it
+func withFieldPrefix(err error, prefix string) error {
+	petErr, ok := err.(*Error)
+	if !ok {
+		return err
+	}
+	prefixed := ErrorEf(petErr.Code, petErr.Cause, "%s", petErr.Message)
+	for _, field := range petErr.Fields {
+		prefixed.AddField(prefix+field.Field, "%s", field.Message)
+	}
+	return prefixed
+}
+
+// newBatchItemResult describes the result of an operation, logging it if it failed
+func newBatchItemResult(r *http.Request, op BatchOperation, result BatchResult) BatchItemResult {
+	if result.Err != nil {
+		problem := newProblem(r, result.Err)
+		logError(r, problem, result.Err)
+		return BatchItemResult{
+			Status: problem.Status,
+			Code:   problem.Code,
+			Detail: problem.Detail,
+			Errors: problem.Errors,
+		}
+	}
+	switch {
+	case op.Op != BatchDelete:
+		return BatchItemResult{Status: http.StatusCreated, Pet: result.Pet}
+	case result.Deleted:
+		return BatchItemResult{Status: http.StatusOK}
+	default:
+		return BatchItemResult{Status: http.StatusNoContent}
+	}
+}
+
+func readBatchMode(r *http.Request) (bool, error) {
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "", batchModeAtomic:
+		return true, nil
+	case batchModeBestEffort:
+		return false, nil
+	default:
+		return false, Errorf(ErrInvalidInput, "Invalid mode %v. Mode should be either %s or %s", mode, batchModeAtomic, batchModeBestEffort).
+			AddField("mode", "should be either %s or %s", batchModeAtomic, batchModeBestEffort)
+	}
+}
+
+func (ps *Service) readBatchBody(r *http.Request) ([]BatchOperation, error) {
+	var ops []BatchOperation
+	if err := ps.Body.decodeJSON(r, &ops, "batch"); err != nil {
+		return nil, err
+	}
+	if len(ops) > MaxBatchSize {
+		return nil, Errorf(ErrInvalidInput, "Batch of %d operations exceeds the maximum of %d", len(ops), MaxBatchSize)
+	}
+	return ops, nil
+}
+
+// validate returns an ErrInvalidInput error if the operation is malformed
+func (op BatchOperation) validate() error {
+	switch op.Op {
+	case BatchCreate, BatchUpsert, BatchDelete:
+	default:
+		return Errorf(ErrInvalidInput, "Unknown batch operation %q", op.Op).
+			AddField("op", "should be one of %s, %s or %s", BatchCreate, BatchUpsert, BatchDelete)
+	}
+	if op.Op != BatchCreate && op.ID == 0 {
+		return Errorf(ErrInvalidInput, "Batch %s needs the ID of a pet", op.Op).AddField("id", "is required")
+	}
+	if op.Op != BatchDelete && op.Pet == nil {
+		return Errorf(ErrInvalidInput, "Batch %s needs a pet", op.Op).AddField("pet", "is required")
+	}
+	if op.Op == BatchUpsert && op.Pet.ID != 0 && op.Pet.ID != op.ID {
+		return Errorf(ErrInvalidInput, "The pet ID %d does not match the ID %d of the upsert", op.Pet.ID, op.ID).
+			AddField("pet.id", "should match the ID of the upsert")
+	}
+	return nil
+}
+
+// applyBatchOp applies a single operation of a batch through the pet operations of store
+func applyBatchOp(ctx context.Context, store Storer, op BatchOperation) BatchResult {
+	if err := op.validate(); err != nil {
+		return BatchResult{Err: err}
+	}
+	if op.Op == BatchDelete {
+		deleted, err := store.DeletePet(ctx, op.ID, Precondition{})
+		return BatchResult{Deleted: deleted, Err: err}
+	}
+	pet := *op.Pet
+	var err error
+	if op.Op == BatchCreate {
+		err = store.CreatePet(ctx, &pet)
+	} else {
+		pet.ID = op.ID
+		err = store.UpdatePet(ctx, op.ID, &pet, Precondition{})
+	}
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	return BatchResult{Pet: &pet}
+}
+
+// batchFailure describes an atomic batch that failed because its operation at index
+// failed with err. It keeps the code and message of err, with invalid fields prefixed
+// by the index of the operation.
+func batchFailure(index int, err error) *Error {
+	code, message := ErrUnknown, "Internal server error"
+	var opErr *Error
+	if errors.As(err, &opErr) {
+		code, message = opErr.Code, opErr.Message
+	}
+	failure := ErrorEf(code, err, "Batch operation %d failed, no operation was applied. %s", index, message)
+	if opErr != nil {
+		for _, field := range opErr.Fields {
+			failure.AddField(fmt.Sprintf("[%d].%s", index, field.Field), "%s", field.Message)
+		}
+	}
+	return failure
+}
@@ -0,0 +1,136 @@
+package pet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchRouter creates a router serving the pet routes of a store holding pet1000
+func batchRouter(t *testing.T) (chi.Router, *MemStore) {
+	store := NewMemStore()
+	testPet := pet1000()
+	require.NoError(t, store.CreatePet(context.Background(), &testPet), "Error in test code, could not add initial data to test")
+	router := chi.NewRouter()
+	SetupRoutes(router, NewPetService(store))
+	return router, store
+}
+
+const testBatch = `[
+	{"op": "create", "pet": {"id": 1001, "name": "Scruff", "species": "Golden Retriever"}},
+	{"op": "create", "pet": {"id": 1000, "name": "Dory", "species": "Bluefish"}},
+	{"op": "delete", "id": 1000}
+]`
+
+func TestBatchPets_Atomic(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, store := batchRouter(t)
+	req, _ := http.NewRequest("POST", "/api/pet:batch", strings.NewReader(testBatch))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusConflict, resp.Code, "Batch should fail with the status of the failed operation")
+	assert.Contains(resp.Body.String(), "Batch operation 1 failed", "Problem should name the failed operation")
+	_, err := store.ReadPet(context.Background(), 1001)
+	assert.Error(err, "No operation of a failed batch should be applied")
+	_, err = store.ReadPet(context.Background(), 1000)
+	assert.NoError(err, "No operation of a failed batch should be applied")
+}
+
+func TestBatchPets_BestEffort(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, store := batchRouter(t)
+	req, _ := http.NewRequest("POST", "/api/pet:batch?mode=best_effort", strings.NewReader(testBatch))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	var response BatchResponse
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &response), "Body should be able to unmarshal to a batch response")
+	if assert.Len(response.Results, 3, "Every operation should have a result") {
+		assert.Equal(http.StatusCreated, response.Results[0].Status)
+		assert.Equal(uint32(1001), response.Results[0].Pet.ID, "Created pet should be returned")
+		assert.Equal(http.StatusConflict, response.Results[1].Status)
+		assert.Equal("duplicate", response.Results[1].Code, "Failures should carry the error code")
+		assert.Nil(response.Results[1].Pet)
+		assert.Equal(http.StatusOK, response.Results[2].Status)
+	}
+	_, err := store.ReadPet(context.Background(), 1001)
+	assert.NoError(err, "Successful operations should be applied")
+	_, err = store.ReadPet(context.Background(), 1000)
+	assert.Error(err, "Successful operations should be applied")
+}
+
+func TestBatchPets_InvalidRequests(t *testing.T) {
+	tooLarge := bytes.NewBufferString("[")
+	for i := 0; i <= MaxBatchSize; i++ {
+		if i > 0 {
+			tooLarge.WriteString(",")
+		}
+		fmt.Fprintf(tooLarge, `{"op": "delete", "id": %d}`, i+1)
+	}
+	tooLarge.WriteString("]")
+	cases := map[string]struct {
+		url  string
+		body string
+	}{
+		"unknown mode":        {"/api/pet:batch?mode=eventually", "[]"},
+		"object body":         {"/api/pet:batch", `{"op": "delete", "id": 1000}`},
+		"too many operations": {"/api/pet:batch", tooLarge.String()},
+		"invalid operation":   {"/api/pet:batch", `[{"op": "upsert", "pet": {"name": "Dory"}}]`},
+	}
+	for name, c := range cases {
+		// given
+		router, store := batchRouter(t)
+		req, _ := http.NewRequest("POST", c.url, strings.NewReader(c.body))
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		tassert.Equal(t, http.StatusBadRequest, resp.Code, "Batch with %s should be rejected", name)
+		_, err := store.ReadPet(context.Background(), 1000)
+		tassert.NoError(t, err, "Batch with %s should not be applied", name)
+	}
+}
+
+func TestWithFieldPrefix_LeavesErrorUntouched(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	cause := fmt.Errorf("bad input")
+	err := ErrorEf(ErrInvalidInput, cause, "Invalid pet data").AddField("name", "is required")
+
+	// when
+	prefixed := withFieldPrefix(err, "pet.")
+
+	// then
+	assert.Equal([]FieldError{{Field: "name", Message: "is required"}}, err.Fields, "Original error should not be changed")
+	var petErr *Error
+	if assert.True(errors.As(prefixed, &petErr), "Prefixed error should be an *Error") {
+		assert.Equal([]FieldError{{Field: "pet.name", Message: "is required"}}, petErr.Fields)
+		assert.Equal(ErrInvalidInput, petErr.Code)
+		assert.Equal("Invalid pet data", petErr.Message)
+		assert.Equal(cause, petErr.Cause)
+	}
+	assert.Nil(withFieldPrefix(nil, "pet."), "No error should stay no error")
+}
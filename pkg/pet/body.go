@@ -0,0 +1,152 @@
+package pet
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxBodySize is the default limit of request bodies, 1 MiB
+const DefaultMaxBodySize = 1 << 20
+
+// BodyConfig controls how request bodies are read. The zero value limits bodies
+// to DefaultMaxBodySize and ignores unknown fields.
+type BodyConfig struct {
+	// MaxSize limits request bodies in bytes, defaults to DefaultMaxBodySize
+	MaxSize int64
+	// DisallowUnknownFields rejects bodies with fields the decoded type does not have,
+	// in every media type
+	DisallowUnknownFields bool
+}
+
+// errBodyTooLarge is returned by a limitedBody once more than its limit is read
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedBody reads a request body, failing once more than remaining bytes are read
+type limitedBody struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
+
+func (c BodyConfig) maxSize() int64 {
+	if c.MaxSize > 0 {
+		return c.MaxSize
+	}
+	return DefaultMaxBodySize
+}
+
+// body returns the request body, limited to the maximum size.
+// Bodies declaring a larger Content-Length are rejected without being read.
+func (c BodyConfig) body(r *http.Request) (io.Reader, error) {
+	if r.Body == nil {
+		return nil, Errorf(ErrInvalidInput, "No request body")
+	}
+	if r.ContentLength > c.maxSize() {
+		return nil, c.tooLarge()
+	}
+	return &limitedBody{r: r.Body, remaining: c.maxSize()}, nil
+}
+
+func (c BodyConfig) tooLarge() *Error {
+	return Errorf(ErrTooLarge, "Request body exceeds the maximum of %d bytes", c.maxSize())
+}
+
+// read reads the whole request body
+func (c BodyConfig) read(r *http.Request) ([]byte, error) {
+	body, err := c.body(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(body)
+	if err == errBodyTooLarge {
+		return nil, c.tooLarge()
+	}
+	if err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Bad request body")
+	}
+	return data, nil
+}
+
+// decodeJSON decodes a JSON request body holding a single value into v. Errors describe
+// the body as the given kind of data. Bodies with a Content-Type other than JSON are
+// rejected, bodies without one are assumed to be JSON.
+func (c BodyConfig) decodeJSON(r *http.Request, v interface{}, kind string) error {
+	if err := checkJSONContentType(r); err != nil {
+		return err
+	}
+	body, err := c.body(r)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(body)
+	if c.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if err = decoder.Decode(v); err != nil {
+		return c.decodeError(err, kind)
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		if err == errBodyTooLarge {
+			return c.tooLarge()
+		}
+		return ErrorEf(ErrInvalidInput, err, "Invalid %s data, the body should hold a single JSON value", kind)
+	}
+	return nil
+}
+
+// decodeError describes why decoding a body failed, naming the invalid field if known
+func (c BodyConfig) decodeError(err error, kind string) *Error {
+	if err == errBodyTooLarge {
+		return c.tooLarge()
+	}
+	if err == io.EOF {
+		return Errorf(ErrInvalidInput, "Empty request body, expected %s data", kind)
+	}
+	decodeErr := ErrorEf(ErrInvalidInput, err, "Invalid %s data", kind)
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+		decodeErr.AddField(typeErr.Field, "should be a %v", typeErr.Type)
+	}
+	// the JSON and MessagePack decoders report unknown fields only through the error message
+	for _, prefix := range []string{"json: unknown field ", "msgpack: unknown field "} {
+		field := strings.TrimPrefix(err.Error(), prefix)
+		if field == err.Error() {
+			continue
+		}
+		if unquoted, unquoteErr := strconv.Unquote(field); unquoteErr == nil {
+			field = unquoted
+		}
+		decodeErr.AddField(field, "is not a known field")
+	}
+	return decodeErr
+}
+
+// checkJSONContentType returns an ErrUnsupportedMediaType error if the request declares
+// a Content-Type other than application/json or a +json type
+func checkJSONContentType(r *http.Request) error {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return Errorf(ErrUnsupportedMediaType, "Content-Type should be application/json")
+	}
+	return nil
+}
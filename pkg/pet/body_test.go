@@ -0,0 +1,121 @@
+package pet
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func bodyRouter(body BodyConfig) (chi.Router, *MemStore) {
+	store := NewMemStore()
+	service := NewPetService(store)
+	service.Body = body
+	router := chi.NewRouter()
+	SetupRoutes(router, service)
+	return router, store
+}
+
+func TestPostPet_BodyTooLarge(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, _ := bodyRouter(BodyConfig{MaxSize: 64})
+	petJSON := `{"id": 1000, "name": "` + strings.Repeat("a", 64) + `", "species": "Goldfish"}`
+	declared, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(petJSON))
+	// a body of unknown length is only rejected once the limit is read
+	streamed, _ := http.NewRequest("POST", "/api/pet", ioutil.NopCloser(strings.NewReader(petJSON)))
+	streamed.ContentLength = -1
+
+	for name, req := range map[string]*http.Request{"declared": declared, "streamed": streamed} {
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(http.StatusRequestEntityTooLarge, resp.Code, "%s body: Response status should be 413 Request Entity Too Large", name)
+		assert.Contains(resp.Body.String(), `"code":"too_large"`, "%s body", name)
+	}
+}
+
+func TestPostPet_UnsupportedContentType(t *testing.T) {
+	// given
+	router, store := bodyRouter(BodyConfig{})
+	req, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(`{"id": 1000, "name": "Nemo", "species": "Goldfish"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	tassert.Equal(t, http.StatusUnsupportedMediaType, resp.Code, "Response status should be 415 Unsupported Media Type")
+	_, err := store.ReadPet(req.Context(), 1000)
+	tassert.Error(t, err, "Pet should not be stored")
+}
+
+func TestPostPet_UnknownFields(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		// given
+		assert := tassert.New(t)
+		router, _ := bodyRouter(BodyConfig{DisallowUnknownFields: strict})
+		req, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(`{"name": "Nemo", "species": "Goldfish", "colour": "orange"}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		if strict {
+			assert.Equal(http.StatusBadRequest, resp.Code, "Unknown field should be rejected by strict decoding")
+			assert.Contains(resp.Body.String(), `{"field":"colour","message":"is not a known field"}`)
+		} else {
+			assert.Equal(http.StatusCreated, resp.Code, "Unknown field should be ignored by default")
+		}
+	}
+}
+
+func TestPostPet_MalformedBodies(t *testing.T) {
+	tests := map[string]string{
+		"empty":         ``,
+		"trailing data": `{"name": "Nemo", "species": "Goldfish"} {"name": "Dory"}`,
+		"wrong type":    `{"name": 7, "species": "Goldfish"}`,
+	}
+	for name, body := range tests {
+		// given
+		router, _ := bodyRouter(BodyConfig{})
+		req, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		tassert.Equal(t, http.StatusBadRequest, resp.Code, "%s body: Response status should be 400 Bad Request", name)
+	}
+}
+
+func TestPutPet_IDMismatch(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, store := bodyRouter(BodyConfig{})
+	req, _ := http.NewRequest("PUT", "/api/pet/1000", strings.NewReader(`{"id": 1001, "name": "Nemo", "species": "Goldfish"}`))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusBadRequest, resp.Code, "Response status should be 400 Bad Request")
+	assert.Contains(resp.Body.String(), `{"field":"id","message":"should match the ID in the URL"}`)
+	for _, id := range []uint32{1000, 1001} {
+		_, err := store.ReadPet(req.Context(), id)
+		assert.Error(err, "Pet %d should not be stored", id)
+	}
+}
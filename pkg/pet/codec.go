@@ -0,0 +1,343 @@
+package pet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/vmihailenco/msgpack/v4"
+	"gopkg.in/yaml.v2"
+)
+
+// Codec reads and writes pets in a single media type
+type Codec interface {
+	// ContentType is the Content-Type of the data the codec writes
+	ContentType() string
+	// EncodePet writes a single pet
+	EncodePet(w io.Writer, pet *Pet) error
+	// EncodePage writes a page of pets
+	EncodePage(w io.Writer, page *PetPage) error
+	// DecodePet reads a single pet, rejecting fields a pet does not have if strict.
+	// It may return an *Error naming the invalid fields, other errors are reported as
+	// invalid input.
+	DecodePet(data []byte, pet *Pet, strict bool) error
+}
+
+// Codecs is a registry of codecs selected by media type, with the Accept header for
+// responses and the Content-Type header for request bodies. The zero value has no codecs.
+type Codecs struct {
+	// mediaTypes lists the registered media types, the first codec's first
+	mediaTypes []string
+	byType     map[string]Codec
+}
+
+// NewCodecs creates an empty registry
+func NewCodecs() *Codecs {
+	return &Codecs{byType: map[string]Codec{}}
+}
+
+// DefaultCodecs returns a registry of JSON, YAML, XML, MessagePack and CSV codecs,
+// with JSON used for requests that state no preference
+func DefaultCodecs() *Codecs {
+	c := NewCodecs()
+	c.Register(JSONCodec{})
+	c.Register(YAMLCodec{}, "application/x-yaml", "text/yaml")
+	c.Register(XMLCodec{}, "text/xml")
+	c.Register(MsgpackCodec{}, "application/x-msgpack", "application/vnd.msgpack")
+	c.Register(CSVCodec{})
+	return c
+}
+
+// defaultCodecs is used by services without codecs of their own
+var defaultCodecs = DefaultCodecs()
+
+// orDefault returns the default codecs for a nil registry
+func (c *Codecs) orDefault() *Codecs {
+	if c == nil {
+		return defaultCodecs
+	}
+	return c
+}
+
+// Register adds a codec for the media type of its ContentType and any aliases,
+// replacing the codecs previously registered for them. The first codec registered
+// is used for requests that state no preference.
+func (c *Codecs) Register(codec Codec, aliases ...string) {
+	mediaType, _, err := mime.ParseMediaType(codec.ContentType())
+	if err != nil {
+		panic(fmt.Sprintf("codec content type %q is invalid: %v", codec.ContentType(), err))
+	}
+	if c.byType == nil {
+		c.byType = map[string]Codec{}
+	}
+	for _, name := range append([]string{mediaType}, aliases...) {
+		if _, ok := c.byType[name]; !ok {
+			c.mediaTypes = append(c.mediaTypes, name)
+		}
+		c.byType[name] = codec
+	}
+}
+
+// forAccept returns the codec of the media type the Accept header of a request
+// prefers, failing with ErrNotAcceptable if it accepts none of them
+func (c *Codecs) forAccept(r *http.Request) (Codec, error) {
+	if len(c.mediaTypes) == 0 {
+		return nil, Errorf(ErrNotAcceptable, "No media types can be written")
+	}
+	mediaType := negotiate(r.Header.Get("Accept"), c.mediaTypes...)
+	if mediaType == "" {
+		return nil, Errorf(ErrNotAcceptable, "Accept should allow one of %s", strings.Join(c.mediaTypes, ", "))
+	}
+	return c.byType[mediaType], nil
+}
+
+// forContentType returns the codec of the Content-Type of a request body, failing with
+// ErrUnsupportedMediaType if no codec reads it. Types with a structured syntax suffix
+// such as +json are read by the codec of that syntax. Bodies without a Content-Type
+// are read by the first codec.
+func (c *Codecs) forContentType(r *http.Request) (Codec, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" && len(c.mediaTypes) > 0 {
+		return c.byType[c.mediaTypes[0]], nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		if codec, ok := c.byType[mediaType]; ok {
+			return codec, nil
+		}
+		if plus := strings.LastIndex(mediaType, "+"); plus >= 0 {
+			if codec, ok := c.byType["application/"+mediaType[plus+1:]]; ok {
+				return codec, nil
+			}
+		}
+	}
+	return nil, Errorf(ErrUnsupportedMediaType, "Content-Type should be one of %s", strings.Join(c.mediaTypes, ", "))
+}
+
+// decodePet reads a pet from a request body with the codec of its Content-Type,
+// rejecting unknown fields if configured. JSON bodies are decoded as by decodeJSON.
+func (c BodyConfig) decodePet(r *http.Request, codecs *Codecs) (*Pet, error) {
+	codec, err := codecs.forContentType(r)
+	if err != nil {
+		return nil, err
+	}
+	var pet Pet
+	if _, ok := codec.(JSONCodec); ok {
+		if err = c.decodeJSON(r, &pet, "pet"); err != nil {
+			return nil, err
+		}
+		return &pet, nil
+	}
+	data, err := c.read(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, c.decodeError(io.EOF, "pet")
+	}
+	if err = codec.DecodePet(data, &pet, c.DisallowUnknownFields); err != nil {
+		var petErr *Error
+		if errors.As(err, &petErr) {
+			return nil, petErr
+		}
+		return nil, c.decodeError(err, "pet")
+	}
+	return &pet, nil
+}
+
+// renderEncoded writes a response encoded by a codec, with the status set by render.Status
+func renderEncoded(w http.ResponseWriter, r *http.Request, codec Codec, encode func(io.Writer) error) {
+	var buf bytes.Buffer
+	if err := encode(&buf); err != nil {
+		renderErrorResponse(w, r, ErrorEf(ErrUnknown, err, "Could not encode response"))
+		return
+	}
+	w.Header().Set("Content-Type", codec.ContentType())
+	w.Header().Add("Vary", "Accept")
+	if status, ok := r.Context().Value(render.StatusCtxKey).(int); ok {
+		w.WriteHeader(status)
+	}
+	w.Write(buf.Bytes())
+}
+
+// renderPet writes a pet with the codec negotiated for the request
+func renderPet(w http.ResponseWriter, r *http.Request, codec Codec, pet *Pet) {
+	renderEncoded(w, r, codec, func(out io.Writer) error {
+		return codec.EncodePet(out, pet)
+	})
+}
+
+// renderPage writes a page of pets with the codec negotiated for the request. The
+// cursor of the next page is also given in a Link header, for formats without a place
+// for it.
+func renderPage(w http.ResponseWriter, r *http.Request, codec Codec, page *PetPage) {
+	if page.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", page.NextCursor)
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+	renderEncoded(w, r, codec, func(out io.Writer) error {
+		return codec.EncodePage(out, page)
+	})
+}
+
+// JSONCodec reads and writes pets as JSON
+type JSONCodec struct{}
+
+// ContentType returns application/json
+func (JSONCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+// EncodePet writes a pet as a JSON object
+func (JSONCodec) EncodePet(w io.Writer, pet *Pet) error {
+	return json.NewEncoder(w).Encode(pet)
+}
+
+// EncodePage writes a page as a JSON object holding the pets and the next cursor
+func (JSONCodec) EncodePage(w io.Writer, page *PetPage) error {
+	return json.NewEncoder(w).Encode(page)
+}
+
+// DecodePet reads a pet from a JSON object
+func (JSONCodec) DecodePet(data []byte, pet *Pet, strict bool) error {
+	return unmarshalJSON(data, pet, strict)
+}
+
+// unmarshalJSON decodes a JSON value into v, rejecting unknown fields if strict
+func unmarshalJSON(data []byte, v interface{}, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(v)
+}
+
+// YAMLCodec reads and writes pets as YAML, with the fields of their JSON form
+type YAMLCodec struct{}
+
+// ContentType returns application/yaml
+func (YAMLCodec) ContentType() string {
+	return "application/yaml; charset=utf-8"
+}
+
+// EncodePet writes a pet as a YAML mapping
+func (YAMLCodec) EncodePet(w io.Writer, pet *Pet) error {
+	return encodeYAML(w, pet)
+}
+
+// EncodePage writes a page as a YAML mapping holding the pets and the next cursor
+func (YAMLCodec) EncodePage(w io.Writer, page *PetPage) error {
+	return encodeYAML(w, page)
+}
+
+// DecodePet reads a pet from a YAML mapping
+func (YAMLCodec) DecodePet(data []byte, pet *Pet, strict bool) error {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	jsonValue, err := yamlToJSON(value)
+	if err != nil {
+		return err
+	}
+	// decoding through JSON applies the field names and types of the JSON form
+	data, err = json.Marshal(jsonValue)
+	if err != nil {
+		return err
+	}
+	return unmarshalJSON(data, pet, strict)
+}
+
+// encodeYAML writes the JSON form of v as YAML, keeping the order of its fields
+func encodeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// JSON is YAML, and a MapSlice keeps the order of the fields
+	var fields yaml.MapSlice
+	if err = yaml.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	encoder := yaml.NewEncoder(w)
+	if err = encoder.Encode(fields); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// yamlToJSON converts a decoded YAML value to one that can be encoded as JSON,
+// whose object keys must be strings
+func yamlToJSON(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			converted, err := yamlToJSON(child)
+			if err != nil {
+				return nil, err
+			}
+			object[fmt.Sprint(key)] = converted
+		}
+		return object, nil
+	case []interface{}:
+		array := make([]interface{}, len(v))
+		for i, child := range v {
+			converted, err := yamlToJSON(child)
+			if err != nil {
+				return nil, err
+			}
+			array[i] = converted
+		}
+		return array, nil
+	}
+	return value, nil
+}
+
+// MsgpackCodec reads and writes pets as MessagePack maps, with the fields of their JSON form
+type MsgpackCodec struct{}
+
+// ContentType returns application/msgpack
+func (MsgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// EncodePet writes a pet as a MessagePack map
+func (MsgpackCodec) EncodePet(w io.Writer, pet *Pet) error {
+	return msgpack.NewEncoder(w).UseJSONTag(true).Encode(pet)
+}
+
+// EncodePage writes a page as a MessagePack map holding the pets and the next cursor
+func (MsgpackCodec) EncodePage(w io.Writer, page *PetPage) error {
+	return msgpack.NewEncoder(w).UseJSONTag(true).Encode(page)
+}
+
+// DecodePet reads a pet from a MessagePack map. Numbers in the extra data are read
+// as float64, as they are from JSON.
+func (MsgpackCodec) DecodePet(data []byte, pet *Pet, strict bool) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data)).UseJSONTag(true)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(pet); err != nil {
+		return err
+	}
+	if pet.Extra == nil {
+		return nil
+	}
+	extra, err := json.Marshal(pet.Extra)
+	if err != nil {
+		return err
+	}
+	pet.Extra = nil
+	return json.Unmarshal(extra, &pet.Extra)
+}
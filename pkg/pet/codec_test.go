@@ -0,0 +1,331 @@
+package pet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/vmihailenco/msgpack/v4"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func codecPet() *Pet {
+	return &Pet{
+		ID:      7,
+		Name:    "Nemo",
+		Species: "Clownfish",
+		Owner:   "Marlin",
+		OwnerID: 3,
+		Extra: map[string]interface{}{
+			"fins":    3.0,
+			"lucky":   true,
+			"tank":    nil,
+			"tags":    []interface{}{"orange", map[string]interface{}{"stripes": 3.0}},
+			"habitat": map[string]interface{}{"reef": "Great Barrier", "depth": map[string]interface{}{}},
+			"friends": []interface{}{},
+		},
+	}
+}
+
+func TestCodecs_RoundTrip(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, YAMLCodec{}, XMLCodec{}, MsgpackCodec{}, CSVCodec{}} {
+		// given
+		var buf bytes.Buffer
+		require.NoError(t, codec.EncodePet(&buf, codecPet()), codec.ContentType())
+
+		// when
+		var decoded Pet
+		err := codec.DecodePet(buf.Bytes(), &decoded, true)
+
+		// then
+		require.NoError(t, err, codec.ContentType())
+		tassert.Equal(t, codecPet(), &decoded, "%s should keep every field of the pet", codec.ContentType())
+	}
+}
+
+func TestCodecs_Pages(t *testing.T) {
+	page := &PetPage{Pets: []Pet{*codecPet(), {ID: 8, Name: "Dory", Species: "Blue tang"}}, NextCursor: "abc"}
+	tests := map[Codec][]string{
+		YAMLCodec{}:    {"pets:\n- id: 7\n  name: Nemo\n", "next_cursor: abc\n"},
+		XMLCodec{}:     {`<pets next_cursor="abc"><pet><id>7</id>`, `<field name="tags.1.stripes" type="number">3</field>`},
+		MsgpackCodec{}: {"next_cursor", "Dory"},
+	}
+	for codec, contains := range tests {
+		// given
+		var buf bytes.Buffer
+
+		// when
+		err := codec.EncodePage(&buf, page)
+
+		// then
+		require.NoError(t, err, codec.ContentType())
+		for _, c := range contains {
+			tassert.Contains(t, buf.String(), c, codec.ContentType())
+		}
+	}
+}
+
+func TestCSVCodec_FlattensExtra(t *testing.T) {
+	// given
+	page := &PetPage{Pets: []Pet{
+		{ID: 1, Name: "Nemo", Species: "Clownfish", Version: 2, Extra: map[string]interface{}{
+			"tags": []interface{}{"orange", "white"}, "size": map[string]interface{}{"cm": 8.5},
+		}},
+		{ID: 2, Name: "Dory", Species: "Blue tang", OwnerID: 4, Extra: map[string]interface{}{
+			"forgetful": true, "tags": []interface{}{"42"},
+		}},
+	}}
+	var buf bytes.Buffer
+
+	// when
+	err := CSVCodec{}.EncodePage(&buf, page)
+
+	// then
+	require.NoError(t, err)
+	tassert.Equal(t, "id,name,species,owner,owner_id,version,extra.forgetful,extra.size.cm,extra.tags.0,extra.tags.1\n"+
+		"1,Nemo,Clownfish,,,2,,8.5,orange,white\n"+
+		"2,Dory,Blue tang,,4,,true,,42,\n", buf.String(), "Columns should be the union of the flattened extra data")
+}
+
+func TestCSVCodec_DecodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		csv   string
+		field string
+	}{
+		"unknown column":  {csv: "name,colour\nNemo,orange\n", field: "colour"},
+		"invalid id":      {csv: "id,name\nseven,Nemo\n", field: "id"},
+		"nested in value": {csv: "name,extra.size,extra.size.cm\nNemo,8,8\n", field: "extra.size.cm"},
+	}
+	for name, test := range tests {
+		// when
+		err := CSVCodec{}.DecodePet([]byte(test.csv), &Pet{}, false)
+
+		// then
+		var petErr *Error
+		if tassert.True(t, errors.As(err, &petErr), "%s: %v should be a pet Error", name, err) {
+			tassert.Equal(t, ErrInvalidInput, petErr.Code, name)
+			tassert.Equal(t, test.field, petErr.Fields[0].Field, name)
+		}
+	}
+}
+
+func codecRouter() (chi.Router, *MemStore) {
+	store := NewMemStore()
+	router := chi.NewRouter()
+	SetupRoutes(router, NewPetService(store))
+	return router, store
+}
+
+func TestService_NegotiatesResponses(t *testing.T) {
+	// given
+	router, store := codecRouter()
+	stored := codecPet()
+	stored.OwnerID = 0
+	require.NoError(t, store.CreatePet(context.Background(), stored))
+	tests := map[string]string{
+		"":                                "application/json; charset=utf-8",
+		"application/yaml":                "application/yaml; charset=utf-8",
+		"text/yaml":                       "application/yaml; charset=utf-8",
+		"application/xml;q=0.5, text/csv": "text/csv; charset=utf-8; header=present",
+		"text/*;q=0.5, application/xml":   "application/xml; charset=utf-8",
+		"application/msgpack":             "application/msgpack",
+		"application/*":                   "application/json; charset=utf-8",
+	}
+	for accept, contentType := range tests {
+		req, _ := http.NewRequest("GET", "/api/pet/7", nil)
+		req.Header.Set("Accept", accept)
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		tassert.Equal(t, http.StatusOK, resp.Code, "Accept %s", accept)
+		tassert.Equal(t, contentType, resp.Header().Get("Content-Type"), "Accept %s", accept)
+		tassert.Equal(t, "Accept", resp.Header().Get("Vary"), "Accept %s", accept)
+	}
+}
+
+func TestService_NotAcceptable(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, store := codecRouter()
+	stored := codecPet()
+	stored.OwnerID = 0
+	require.NoError(t, store.CreatePet(context.Background(), stored))
+	get, _ := http.NewRequest("GET", "/api/pet/7", nil)
+	get.Header.Set("Accept", "image/png")
+	post, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(`{"name": "Dory", "species": "Blue tang"}`))
+	post.Header.Set("Accept", "image/png")
+
+	for _, req := range []*http.Request{get, post} {
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(http.StatusNotAcceptable, resp.Code, "%s should be 406 Not Acceptable", req.Method)
+		assert.Equal(problemType, resp.Header().Get("Content-Type"), "Error should still be problem details")
+		assert.Contains(resp.Body.String(), `"code":"not_acceptable"`)
+	}
+	page, err := store.ListPets(context.Background(), ListQuery{})
+	require.NoError(t, err)
+	assert.Len(page.Pets, 1, "Rejected POST should not create a pet")
+}
+
+func TestPutPet_RendersStoredPet(t *testing.T) {
+	// given
+	router, _ := codecRouter()
+	req, _ := http.NewRequest("PUT", "/api/pet/7", strings.NewReader(`{"name": "Nemo", "species": "Clownfish"}`))
+	req.Header.Set("Accept", "application/yaml")
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	require.Equal(t, http.StatusCreated, resp.Code, resp.Body.String())
+	tassert.Equal(t, "application/yaml; charset=utf-8", resp.Header().Get("Content-Type"))
+	tassert.Equal(t, "id: 7\nname: Nemo\nspecies: Clownfish\nowner: \"\"\nextra: null\nversion: 1\n", resp.Body.String())
+}
+
+func TestService_VariesWithAcceptOnErrors(t *testing.T) {
+	// given
+	router, _ := codecRouter()
+	requests := map[string]*http.Request{
+		"missing pet":  httptest.NewRequest("GET", "/api/pet/7", nil),
+		"invalid id":   httptest.NewRequest("PATCH", "/api/pet/seven", nil),
+		"invalid pet":  httptest.NewRequest("PUT", "/api/pet/7", strings.NewReader(`{"name": "Nemo"}`)),
+		"invalid list": httptest.NewRequest("GET", "/api/pet?limit=many", nil),
+	}
+	for name, req := range requests {
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		tassert.True(t, resp.Code >= 400, "%s should fail, got %d", name, resp.Code)
+		tassert.Equal(t, "Accept", resp.Header().Get("Vary"), name)
+	}
+}
+
+func TestService_DecodesRequestBodies(t *testing.T) {
+	tests := map[string]string{
+		"application/yaml":         "name: Dory\nspecies: Blue tang\nextra:\n  tags: [blue, 3]\n",
+		"application/vnd.pet+yaml": "{name: Dory, species: Blue tang, extra: {tags: [blue, 3]}}",
+		"text/xml":                 `<pet><name>Dory</name><species>Blue tang</species><extra><field name="tags.0" type="string">blue</field><field name="tags.1" type="number">3</field></extra></pet>`,
+		"text/csv":                 "name,species,extra.tags.0,extra.tags.1\nDory,Blue tang,blue,3\n",
+		"application/vnd.pet+json": `{"name": "Dory", "species": "Blue tang", "extra": {"tags": ["blue", 3]}}`,
+	}
+	for contentType, body := range tests {
+		// given
+		router, store := codecRouter()
+		req, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		require.Equal(t, http.StatusCreated, resp.Code, "%s: %s", contentType, resp.Body.String())
+		stored, err := store.ReadPet(context.Background(), 1)
+		require.NoError(t, err, contentType)
+		tassert.Equal(t, "Dory", stored.Name, contentType)
+		tassert.Equal(t, map[string]interface{}{"tags": []interface{}{"blue", 3.0}}, stored.Extra, contentType)
+	}
+}
+
+func TestService_StrictDecodingRejectsUnknownFields(t *testing.T) {
+	var msgpackBody bytes.Buffer
+	require.NoError(t, msgpack.NewEncoder(&msgpackBody).Encode(map[string]interface{}{"name": "Dory", "species": "Blue tang", "colour": "blue"}))
+	tests := map[string]struct {
+		body  string
+		field string
+	}{
+		"application/yaml":    {body: "name: Dory\nspecies: Blue tang\ncolour: blue\n", field: "colour"},
+		"application/xml":     {body: "<pet><name>Dory</name><species>Blue tang</species><colour>blue</colour></pet>", field: "colour"},
+		"text/xml":            {body: `<pet><name>Dory</name><species>Blue tang</species><extra><tag>blue</tag></extra></pet>`, field: "extra.tag"},
+		"application/msgpack": {body: msgpackBody.String(), field: "colour"},
+		"text/csv":            {body: "name,species,colour\nDory,Blue tang,blue\n", field: "colour"},
+	}
+	for contentType, test := range tests {
+		for _, strict := range []bool{true, false} {
+			// given
+			store := NewMemStore()
+			service := NewPetService(store)
+			service.Body.DisallowUnknownFields = strict
+			router := chi.NewRouter()
+			SetupRoutes(router, service)
+			req, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", contentType)
+			resp := httptest.NewRecorder()
+
+			// when
+			router.ServeHTTP(resp, req)
+
+			// then
+			if strict || contentType == "text/csv" {
+				tassert.Equal(t, http.StatusBadRequest, resp.Code, "%s should be rejected: %s", contentType, resp.Body.String())
+				tassert.Contains(t, resp.Body.String(), `"field":"`+test.field+`"`, contentType)
+			} else {
+				tassert.Equal(t, http.StatusCreated, resp.Code, "%s should be accepted: %s", contentType, resp.Body.String())
+			}
+		}
+	}
+}
+
+func TestService_RejectsUnreadableBodies(t *testing.T) {
+	tests := map[string]struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		"unsupported type": {contentType: "application/pdf", body: "%PDF", status: http.StatusUnsupportedMediaType},
+		"empty yaml":       {contentType: "application/yaml", body: "  \n", status: http.StatusBadRequest},
+		"malformed xml":    {contentType: "application/xml", body: "<pet><name>", status: http.StatusBadRequest},
+		"untyped field":    {contentType: "application/xml", body: `<pet><extra><field name="a">1</field></extra></pet>`, status: http.StatusBadRequest},
+	}
+	for name, test := range tests {
+		// given
+		router, _ := codecRouter()
+		req, _ := http.NewRequest("PUT", "/api/pet/1", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		tassert.Equal(t, test.status, resp.Code, "%s: %s", name, resp.Body.String())
+	}
+}
+
+func TestListPets_LinksNextPage(t *testing.T) {
+	// given
+	router, store := codecRouter()
+	for _, name := range []string{"Nemo", "Dory"} {
+		require.NoError(t, store.CreatePet(context.Background(), &Pet{Name: name, Species: "Fish"}))
+	}
+	req, _ := http.NewRequest("GET", "/api/pet?limit=1&species=Fish", nil)
+	req.Header.Set("Accept", "text/csv")
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	require.Equal(t, http.StatusOK, resp.Code)
+	link := resp.Header().Get("Link")
+	tassert.Regexp(t, `^</api/pet\?cursor=[^&>]+&limit=1&species=Fish>; rel="next"$`, link)
+	tassert.Equal(t, "id,name,species,owner,owner_id,version\n1,Nemo,Fish,,,1\n", resp.Body.String())
+}
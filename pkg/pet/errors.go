@@ -1,7 +1,10 @@
 package pet
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 )
 
 // Error codes
@@ -14,20 +17,115 @@ const (
 	ErrDuplicate
 	// ErrNotFound is used when attempting to read a non-existing entry
 	ErrNotFound
+	// ErrConflict is used when a conditional write does not match the stored entry's version
+	ErrConflict
+	// ErrUnsupportedMediaType is used when the request body is in a format that is not understood
+	ErrUnsupportedMediaType
+	// ErrCanceled is used when an operation is abandoned because its request was canceled
+	ErrCanceled
+	// ErrTimeout is used when an operation does not finish before its request's deadline
+	ErrTimeout
+	// ErrReference is used when a write would leave an entry referencing one that does not exist
+	ErrReference
+	// ErrUnsupported is used when a store does not support the requested operation
+	ErrUnsupported
+	// ErrTooLarge is used when a request body exceeds the maximum size
+	ErrTooLarge
+	// ErrNotAcceptable is used when a response cannot be written in any media type the request accepts
+	ErrNotAcceptable
+	// ErrPatchTestFailed is used when a test operation of a JSON Patch does not match the patched entry
+	ErrPatchTestFailed
 )
 
+// Sentinel errors for each code, for use with errors.Is
+var (
+	ErrUnknownSentinel              = &Error{Code: ErrUnknown, Message: "unknown error"}
+	ErrInvalidInputSentinel         = &Error{Code: ErrInvalidInput, Message: "invalid input"}
+	ErrDuplicateSentinel            = &Error{Code: ErrDuplicate, Message: "duplicate"}
+	ErrNotFoundSentinel             = &Error{Code: ErrNotFound, Message: "not found"}
+	ErrConflictSentinel             = &Error{Code: ErrConflict, Message: "conflict"}
+	ErrUnsupportedMediaTypeSentinel = &Error{Code: ErrUnsupportedMediaType, Message: "unsupported media type"}
+	ErrCanceledSentinel             = &Error{Code: ErrCanceled, Message: "canceled"}
+	ErrTimeoutSentinel              = &Error{Code: ErrTimeout, Message: "timeout"}
+	ErrReferenceSentinel            = &Error{Code: ErrReference, Message: "reference violation"}
+	ErrUnsupportedSentinel          = &Error{Code: ErrUnsupported, Message: "unsupported"}
+	ErrTooLargeSentinel             = &Error{Code: ErrTooLarge, Message: "too large"}
+	ErrNotAcceptableSentinel        = &Error{Code: ErrNotAcceptable, Message: "not acceptable"}
+	ErrPatchTestFailedSentinel      = &Error{Code: ErrPatchTestFailed, Message: "patch test failed"}
+)
+
+// errorCodeNames are stable, machine readable names of the error codes, used in error responses
+var errorCodeNames = map[int]string{
+	ErrUnknown:              "unknown",
+	ErrInvalidInput:         "invalid_input",
+	ErrDuplicate:            "duplicate",
+	ErrNotFound:             "not_found",
+	ErrConflict:             "conflict",
+	ErrUnsupportedMediaType: "unsupported_media_type",
+	ErrCanceled:             "canceled",
+	ErrTimeout:              "timeout",
+	ErrReference:            "reference_violation",
+	ErrUnsupported:          "unsupported",
+	ErrTooLarge:             "too_large",
+	ErrNotAcceptable:        "not_acceptable",
+	ErrPatchTestFailed:      "patch_test_failed",
+}
+
+// ErrorCodeName returns the machine readable name of an error code
+func ErrorCodeName(code int) string {
+	if name, ok := errorCodeNames[code]; ok {
+		return name
+	}
+	return errorCodeNames[ErrUnknown]
+}
+
+// ParseErrorCode returns the error code with the given machine readable name
+func ParseErrorCode(name string) (int, bool) {
+	for code, codeName := range errorCodeNames {
+		if codeName == name {
+			return code, true
+		}
+	}
+	return ErrUnknown, false
+}
+
 // Error defines an error that separates internal and external error messages
 type Error struct {
 	Message string
 	Code    int
 	Cause   error
+	// Fields lists the individual invalid fields of an ErrInvalidInput error
+	Fields []FieldError
 }
 
+// FieldError describes why a single field of the input is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error returns the public message of the error. The cause is left out
+// as it may hold internal details, it is available through Unwrap.
 func (e *Error) Error() string {
-	if e.Cause == nil {
-		return e.Message
-	}
-	return fmt.Sprintf("%v\n%v", e.Message, e.Cause)
+	return e.Message
+}
+
+// Unwrap returns the cause of the error
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Is reports whether target is an Error with the same code, so that
+// errors.Is(err, ErrNotFoundSentinel) holds for any not found Error in the chain of err
+func (e *Error) Is(target error) bool {
+	targetErr, ok := target.(*Error)
+	return ok && targetErr.Code == e.Code
+}
+
+// AddField records an invalid field on the error and returns the error
+func (e *Error) AddField(field, format string, args ...interface{}) *Error {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	return e
 }
 
 // Errorf creates a new Error with formatting
@@ -43,3 +141,29 @@ func ErrorEf(code int, cause error, format string, args ...interface{}) *Error {
 		Cause:   cause,
 	}
 }
+
+// ContextError returns an ErrTimeout or ErrCanceled error if ctx is done, and nil otherwise
+func ContextError(ctx context.Context) *Error {
+	switch err := ctx.Err(); err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return ErrorEf(ErrTimeout, err, "Request deadline exceeded")
+	default:
+		return ErrorEf(ErrCanceled, err, "Request was canceled")
+	}
+}
+
+// CauseChain joins the messages of err and the errors it wraps, leaving out
+// messages already included by the error wrapping them
+func CauseChain(err error) string {
+	var messages []string
+	for ; err != nil; err = errors.Unwrap(err) {
+		message := err.Error()
+		if len(messages) > 0 && strings.HasSuffix(messages[len(messages)-1], message) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+	return strings.Join(messages, ": ")
+}
@@ -0,0 +1,35 @@
+package pet
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func TestErrorHidesCause(t *testing.T) {
+	// given
+	cause := errors.New("connection refused")
+
+	// when
+	err := ErrorEf(ErrUnknown, cause, "Could not read pet with id %d", 10)
+
+	// then
+	tassert.Equal(t, "Could not read pet with id 10", err.Error(), "Error message should not include the cause")
+	tassert.Equal(t, cause, errors.Unwrap(err), "Cause should be available through Unwrap")
+	tassert.True(t, errors.Is(err, cause), "Error should match its cause with errors.Is")
+}
+
+func TestErrorIsSentinel(t *testing.T) {
+	// given
+	err := fmt.Errorf("reading pet: %w", Errorf(ErrNotFound, "No pet exists with id %d", 10))
+
+	// then
+	tassert.True(t, errors.Is(err, ErrNotFoundSentinel), "Wrapped not found error should match the not found sentinel")
+	tassert.False(t, errors.Is(err, ErrDuplicateSentinel), "Wrapped not found error should not match the duplicate sentinel")
+	var petErr *Error
+	if tassert.True(t, errors.As(err, &petErr), "Wrapped error should be retrievable with errors.As") {
+		tassert.Equal(t, ErrNotFound, petErr.Code, "Retrieved error should keep its code")
+	}
+}
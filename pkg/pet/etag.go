@@ -0,0 +1,55 @@
+package pet
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// formatETag returns the strong entity tag of a pet version
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// readPrecondition builds a store Precondition from the If-Match and If-None-Match request headers
+func readPrecondition(r *http.Request) Precondition {
+	var pre Precondition
+	pre.IfMatch, pre.IfMatchAny = parseETags(r.Header.Get("If-Match"), false)
+	pre.IfNoneMatch, pre.IfNoneMatchAny = parseETags(r.Header.Get("If-None-Match"), true)
+	return pre
+}
+
+// parseETags parses a comma separated list of entity tags into pet versions, reporting
+// whether the list is "*". Weak tags are only accepted if allowWeak is set, as If-Match
+// requires strong comparison. Tags that are not pet versions are kept as version 0,
+// which never matches a stored pet, so that an If-Match of only foreign tags still fails.
+func parseETags(header string, allowWeak bool) ([]uint64, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, false
+	}
+	if header == "*" {
+		return nil, true
+	}
+	var versions []uint64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			if !allowWeak {
+				versions = append(versions, 0)
+				continue
+			}
+			tag = tag[2:]
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			versions = append(versions, 0)
+			continue
+		}
+		version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			version = 0
+		}
+		versions = append(versions, version)
+	}
+	return versions, false
+}
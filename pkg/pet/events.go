@@ -0,0 +1,366 @@
+package pet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Types of the events published when pets change
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+	// EventReset is sent to a client resuming a stream from an event that is no longer
+	// buffered. Events were missed, and the client should list pets again.
+	EventReset = "reset"
+)
+
+// DefaultEventBufferSize is how many events an EventHub keeps for resuming clients
+const DefaultEventBufferSize = 1024
+
+// DefaultEventHeartbeat is how often an idle event stream sends a comment to keep it open
+const DefaultEventHeartbeat = 15 * time.Second
+
+// eventSubscriberBuffer is how many events a subscriber may lag behind before it is dropped
+const eventSubscriberBuffer = 64
+
+// Event describes a change to a pet. IDs increase monotonically within a server.
+type Event struct {
+	ID    uint64    `json:"id"`
+	Type  string    `json:"type"`
+	PetID uint32    `json:"pet_id"`
+	Time  time.Time `json:"time"`
+	// Pet is the pet as written, nil for deleted events
+	Pet *Pet `json:"pet,omitempty"`
+}
+
+// EventHub assigns IDs to published events, keeps the most recent of them in a ring
+// buffer and passes them on to subscribers
+type EventHub struct {
+	mu          sync.Mutex
+	buffer      []Event
+	start       int
+	count       int
+	last        uint64
+	subscribers map[*EventSubscription]struct{}
+	now         func() time.Time
+}
+
+// NewEventHub creates a hub buffering the given number of events, DefaultEventBufferSize if 0
+func NewEventHub(size int) *EventHub {
+	if size <= 0 {
+		size = DefaultEventBufferSize
+	}
+	return &EventHub{
+		buffer:      make([]Event, size),
+		subscribers: map[*EventSubscription]struct{}{},
+		now:         time.Now,
+	}
+}
+
+// EventSubscription receives the events published after it was made. A subscriber that
+// falls too far behind is dropped, closing Events.
+type EventSubscription struct {
+	Events <-chan Event
+	events chan Event
+	hub    *EventHub
+}
+
+// Publish assigns the next ID to an event of the given type and passes it to subscribers
+func (h *EventHub) Publish(eventType string, petID uint32, pet *Pet) Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last++
+	event := Event{ID: h.last, Type: eventType, PetID: petID, Time: h.now().UTC(), Pet: pet}
+	if h.count < len(h.buffer) {
+		h.buffer[(h.start+h.count)%len(h.buffer)] = event
+		h.count++
+	} else {
+		h.buffer[h.start] = event
+		h.start = (h.start + 1) % len(h.buffer)
+	}
+	for sub := range h.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			h.drop(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe subscribes to events published from now on. If resume is set, the buffered
+// events after lastID are returned to be sent first, and complete reports whether they
+// are all the events published after lastID.
+func (h *EventHub) Subscribe(lastID uint64, resume bool) (sub *EventSubscription, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	events := make(chan Event, eventSubscriberBuffer)
+	sub = &EventSubscription{Events: events, events: events, hub: h}
+	h.subscribers[sub] = struct{}{}
+	if !resume {
+		return sub, nil, true
+	}
+	// lastID is only known if it is buffered, was dropped from the buffer, or is the
+	// last event; an ID after the last event comes from before a restart
+	oldest := h.last - uint64(h.count) + 1
+	complete = lastID <= h.last && lastID+1 >= oldest
+	for i := 0; i < h.count; i++ {
+		if event := h.buffer[(h.start+i)%len(h.buffer)]; event.ID > lastID {
+			replay = append(replay, event)
+		}
+	}
+	if lastID > h.last {
+		replay = nil
+	}
+	return sub, replay, complete
+}
+
+// Close unsubscribes, closing Events
+func (s *EventSubscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// drop removes a subscriber, the hub lock must be held
+func (h *EventHub) drop(sub *EventSubscription) {
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// SetupEventRoutes sets up the route streaming pet events, which must be registered
+// on the same router as the pet routes
+func SetupEventRoutes(r chi.Router, h *EventHandler) {
+	r.Get("/api/pet/events", h.ServeHTTP)
+}
+
+// EventHandler streams the events of a hub as Server-Sent Events. Clients resume a
+// stream with the Last-Event-ID header, receiving the buffered events they missed.
+type EventHandler struct {
+	hub *EventHub
+	// Heartbeat is how often an idle stream sends a comment, defaults to DefaultEventHeartbeat
+	Heartbeat time.Duration
+	// MaxDuration ends streams after this long, so that they end before the server's
+	// write timeout; clients then reconnect and resume. 0 is unlimited.
+	MaxDuration time.Duration
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// NewEventHandler creates a handler streaming the events of hub
+func NewEventHandler(hub *EventHub) *EventHandler {
+	return &EventHandler{hub: hub, stopped: make(chan struct{})}
+}
+
+// Stop ends the open streams and any started later, which would otherwise keep a
+// graceful shutdown of the server waiting. Clients resume them from another server.
+func (h *EventHandler) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.stopped)
+	})
+}
+
+// ServeHTTP handles a GET request streaming pet events until the client goes away or
+// the handler is stopped
+func (h *EventHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		renderErrorResponse(w, r, Errorf(ErrUnsupported, "Streaming is not supported"))
+		return
+	}
+	lastID, resume, err := readLastEventID(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	sub, replay, complete := h.hub.Subscribe(lastID, resume)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if !complete {
+		fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q}\n\n", EventReset, EventReset)
+	}
+	for _, event := range replay {
+		if err = writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := h.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = DefaultEventHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	ctx := r.Context()
+	if h.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.MaxDuration)
+		defer cancel()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-h.stopped:
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				// dropped for falling behind, the client resumes from the buffer
+				return
+			}
+			err = writeEvent(w, event)
+		case <-ticker.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		}
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes an event in the text/event-stream format
+func writeEvent(w io.Writer, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+// readLastEventID returns the ID of the Last-Event-ID header, and whether it was given
+func readLastEventID(r *http.Request) (uint64, bool, error) {
+	header := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if header == "" {
+		return 0, false, nil
+	}
+	lastID, err := strconv.ParseUint(header, 10, 64)
+	if err != nil {
+		return 0, false, Errorf(ErrInvalidInput, "Invalid Last-Event-ID %v. ID should be a number", header).
+			AddField("Last-Event-ID", "should be a number")
+	}
+	return lastID, true, nil
+}
+
+// PublishingStore is a Storer decorator publishing an event to a hub for every pet
+// written or deleted through it. Pets written by UpdatePet and batch upserts are
+// published as updated whether or not they existed before. Events of concurrent writes
+// may be published in a different order than the writes were applied; the versions of
+// the pets they carry tell which is the latest. Owner operations are passed on to the
+// wrapped store without events, since events describe pets and owner writes change none.
+type PublishingStore struct {
+	wrappedStore
+	hub *EventHub
+}
+
+// NewPublishingStore wraps store, publishing its writes to hub
+func NewPublishingStore(store Storer, hub *EventHub) *PublishingStore {
+	return &PublishingStore{wrappedStore: wrappedStore{store}, hub: hub}
+}
+
+// CreatePet creates a pet in the wrapped store, publishing a created event
+func (p *PublishingStore) CreatePet(ctx context.Context, pet *Pet) error {
+	if err := p.store.CreatePet(ctx, pet); err != nil {
+		return err
+	}
+	p.publish(EventCreated, pet.ID, pet)
+	return nil
+}
+
+// ReadPet reads a pet from the wrapped store
+func (p *PublishingStore) ReadPet(ctx context.Context, petID uint32) (*Pet, error) {
+	return p.store.ReadPet(ctx, petID)
+}
+
+// UpdatePet updates a pet in the wrapped store, publishing an updated event
+func (p *PublishingStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) error {
+	if err := p.store.UpdatePet(ctx, petID, pet, pre); err != nil {
+		return err
+	}
+	p.publish(EventUpdated, petID, pet)
+	return nil
+}
+
+// DeletePet deletes a pet from the wrapped store, publishing a deleted event if a pet was deleted
+func (p *PublishingStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (bool, error) {
+	deleted, err := p.store.DeletePet(ctx, petID, pre)
+	if deleted {
+		p.publish(EventDeleted, petID, nil)
+	}
+	return deleted, err
+}
+
+// ListPets lists pets in the wrapped store
+func (p *PublishingStore) ListPets(ctx context.Context, query ListQuery) (*PetPage, error) {
+	return p.store.ListPets(ctx, query)
+}
+
+// Batch applies a batch of operations to the wrapped store, publishing an event for
+// every operation that succeeded
+func (p *PublishingStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	results, err := p.store.Batch(ctx, ops, atomic)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		switch {
+		case result.Err != nil:
+		case ops[i].Op == BatchCreate:
+			p.publish(EventCreated, result.Pet.ID, result.Pet)
+		case ops[i].Op == BatchUpsert:
+			p.publish(EventUpdated, result.Pet.ID, result.Pet)
+		case result.Deleted:
+			p.publish(EventDeleted, ops[i].ID, nil)
+		}
+	}
+	return results, nil
+}
+
+// publish publishes a copy of pet, so that later changes by the caller are not seen by subscribers
+func (p *PublishingStore) publish(eventType string, petID uint32, pet *Pet) {
+	if pet != nil {
+		published := *pet
+		published.ID = petID
+		pet = &published
+	}
+	p.hub.Publish(eventType, petID, pet)
+}
+
+// CreateOwner creates an owner in the wrapped store
+func (p *PublishingStore) CreateOwner(ctx context.Context, owner *Owner) error {
+	return p.owners().CreateOwner(ctx, owner)
+}
+
+// ReadOwner reads an owner from the wrapped store
+func (p *PublishingStore) ReadOwner(ctx context.Context, ownerID uint32) (*Owner, error) {
+	return p.owners().ReadOwner(ctx, ownerID)
+}
+
+// UpdateOwner updates an owner in the wrapped store
+func (p *PublishingStore) UpdateOwner(ctx context.Context, ownerID uint32, owner *Owner) error {
+	return p.owners().UpdateOwner(ctx, ownerID, owner)
+}
+
+// DeleteOwner deletes an owner from the wrapped store
+func (p *PublishingStore) DeleteOwner(ctx context.Context, ownerID uint32) (bool, error) {
+	return p.owners().DeleteOwner(ctx, ownerID)
+}
@@ -0,0 +1,191 @@
+package pet
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventHub_Resume(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	hub := NewEventHub(3)
+	for petID := uint32(1); petID <= 5; petID++ {
+		hub.Publish(EventCreated, petID, &Pet{ID: petID})
+	}
+
+	tests := []struct {
+		lastID     uint64
+		resume     bool
+		replayIDs  []uint64
+		isComplete bool
+	}{
+		{lastID: 0, resume: false, isComplete: true},
+		{lastID: 3, resume: true, replayIDs: []uint64{4, 5}, isComplete: true},
+		{lastID: 2, resume: true, replayIDs: []uint64{3, 4, 5}, isComplete: true},
+		{lastID: 5, resume: true, isComplete: true},
+		{lastID: 1, resume: true, replayIDs: []uint64{3, 4, 5}, isComplete: false},
+		{lastID: 9, resume: true, isComplete: false},
+	}
+	for _, test := range tests {
+		// when
+		sub, replay, complete := hub.Subscribe(test.lastID, test.resume)
+		sub.Close()
+
+		// then
+		var replayIDs []uint64
+		for _, event := range replay {
+			replayIDs = append(replayIDs, event.ID)
+		}
+		assert.Equal(test.replayIDs, replayIDs, "Replay after %d", test.lastID)
+		assert.Equal(test.isComplete, complete, "Completeness after %d", test.lastID)
+	}
+}
+
+func TestEventHub_DropsSlowSubscribers(t *testing.T) {
+	// given
+	hub := NewEventHub(0)
+	sub, _, _ := hub.Subscribe(0, false)
+
+	// when
+	for i := 0; i <= eventSubscriberBuffer; i++ {
+		hub.Publish(EventDeleted, 1, nil)
+	}
+
+	// then
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	tassert.Equal(t, eventSubscriberBuffer, received, "Events should be closed once the subscriber falls behind")
+}
+
+func TestPublishingStore_Batch(t *testing.T) {
+	// given
+	hub := NewEventHub(0)
+	store := NewPublishingStore(NewMemStore(), hub)
+	ctx := context.Background()
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+	sub, _, _ := hub.Subscribe(0, false)
+	defer sub.Close()
+
+	// when
+	_, err := store.Batch(ctx, []BatchOperation{
+		{Op: BatchCreate, Pet: &Pet{ID: 1, Name: "Duplicate", Species: "Clownfish"}},
+		{Op: BatchUpsert, ID: 2, Pet: &Pet{Name: "Dory", Species: "Blue Tang"}},
+		{Op: BatchDelete, ID: 1},
+		{Op: BatchDelete, ID: 3},
+	}, false)
+
+	// then
+	require.NoError(t, err)
+	tassert.Equal(t, Event{ID: 2, Type: EventUpdated, PetID: 2}, withoutDetails(<-sub.Events))
+	tassert.Equal(t, Event{ID: 3, Type: EventDeleted, PetID: 1}, withoutDetails(<-sub.Events))
+	tassert.Len(t, sub.Events, 0, "Failed and ineffective operations should not be published")
+}
+
+func withoutDetails(event Event) Event {
+	event.Time, event.Pet = time.Time{}, nil
+	return event
+}
+
+func TestEventHandler_Stream(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	hub := NewEventHub(0)
+	store := NewPublishingStore(NewMemStore(), hub)
+	router := chi.NewRouter()
+	SetupRoutes(router, NewPetService(store))
+	SetupEventRoutes(router, NewEventHandler(hub))
+	server := httptest.NewServer(router)
+	defer server.Close()
+	ctx := context.Background()
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+
+	// when
+	req, _ := http.NewRequest("GET", server.URL+"/api/pet/events", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	_, err = store.DeletePet(ctx, 1, Precondition{})
+	require.NoError(t, err)
+
+	// then
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+	lines := bufio.NewScanner(resp.Body)
+	var received []string
+	for len(received) < 4 && lines.Scan() {
+		if line := lines.Text(); strings.HasPrefix(line, "id: ") || strings.HasPrefix(line, "event: ") {
+			received = append(received, line)
+		}
+	}
+	assert.Equal([]string{"id: 1", "event: created", "id: 2", "event: deleted"}, received,
+		"Replayed created event should be followed by the live deleted event")
+}
+
+func TestEventHandler_ResetAndMaxDuration(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	hub := NewEventHub(1)
+	hub.Publish(EventCreated, 1, &Pet{ID: 1})
+	hub.Publish(EventCreated, 2, &Pet{ID: 2})
+	handler := NewEventHandler(hub)
+	handler.MaxDuration = 10 * time.Millisecond
+	req, _ := http.NewRequest("GET", "/api/pet/events", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp := httptest.NewRecorder()
+
+	// when
+	handler.ServeHTTP(resp, req)
+
+	// then
+	assert.True(strings.HasPrefix(resp.Body.String(), "event: reset\n"), "Stream should start with a reset when events were missed")
+	assert.Contains(resp.Body.String(), "id: 2\nevent: created\n")
+	assert.NotContains(resp.Body.String(), "id: 1\n")
+}
+
+func TestEventHandler_StopEndsStreamsOnShutdown(t *testing.T) {
+	// given
+	handler := NewEventHandler(NewEventHub(0))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	server.Config.RegisterOnShutdown(handler.Stop)
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// when
+	err = server.Config.Shutdown(ctx)
+
+	// then
+	tassert.NoError(t, err, "Shutdown should not wait for open streams")
+	_, err = ioutil.ReadAll(resp.Body)
+	tassert.NoError(t, err, "Stream should end cleanly")
+}
+
+func TestEventHandler_InvalidLastEventID(t *testing.T) {
+	// given
+	req, _ := http.NewRequest("GET", "/api/pet/events", nil)
+	req.Header.Set("Last-Event-ID", "yesterday")
+	resp := httptest.NewRecorder()
+
+	// when
+	NewEventHandler(NewEventHub(0)).ServeHTTP(resp, req)
+
+	// then
+	tassert.Equal(t, http.StatusBadRequest, resp.Code, "Response status should be 400 Bad Request")
+}
@@ -0,0 +1,4 @@
+package pet
+
+// NewFakePQStore exposes the fake postgres backed store to the external test package
+var NewFakePQStore = newFakePQStore
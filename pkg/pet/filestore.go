@@ -0,0 +1,712 @@
+package pet
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SyncPolicy decides when a FileStore flushes its log to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the log before every write returns, so that no acknowledged
+	// write is lost
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the log periodically, losing at most the writes of the last
+	// interval when the machine fails
+	SyncInterval
+	// SyncNever leaves flushing the log to the operating system
+	SyncNever
+)
+
+// Defaults of a FileConfig
+const (
+	DefaultSyncInterval  = time.Second
+	DefaultSnapshotEvery = 10000
+)
+
+// Names of the files a FileStore keeps in its directory
+const (
+	fileSnapshotName = "snapshot.json"
+	fileLogName      = "wal.log"
+)
+
+// Operations of a walChange
+const (
+	walPutPet      = "put_pet"
+	walDeletePet   = "delete_pet"
+	walPutOwner    = "put_owner"
+	walDeleteOwner = "delete_owner"
+)
+
+// walHeaderSize is the size of the length and checksum preceding every log record
+const walHeaderSize = 8
+
+// maxWALRecordSize bounds the length of a log record, longer lengths are corrupt
+const maxWALRecordSize = 1 << 30
+
+// tornScanLimit caps how much of the log past an invalid record is searched for a valid
+// one. A longer tail is reported as corrupt rather than torn, so it is never cut off
+// unsearched; a torn write leaves only part of a single record.
+const tornScanLimit = 16 << 20
+
+// walTable is the CRC-32C table checksumming log records
+var walTable = crc32.MakeTable(crc32.Castagnoli)
+
+// FileConfig holds the settings of a file backed store
+type FileConfig struct {
+	// Dir holds the snapshot and log of the store, and is created if it does not exist
+	Dir string
+	// Sync decides when the log is flushed to disk, defaults to SyncAlways
+	Sync SyncPolicy
+	// SyncInterval is how often the log is flushed with SyncInterval, defaults to DefaultSyncInterval
+	SyncInterval time.Duration
+	// SnapshotEvery is how many log records are written before a snapshot replaces them,
+	// defaults to DefaultSnapshotEvery
+	SnapshotEvery int
+	// IDs allocates the IDs of pets created without one, defaults to SequentialIDs
+	// continuing after the highest stored ID
+	IDs IDGenerator
+}
+
+// FileRecovery describes what a FileStore recovered from its directory when it was opened
+type FileRecovery struct {
+	// Pets and Owners are the numbers of pets and owners recovered
+	Pets   int
+	Owners int
+	// Records is the number of log records replayed on top of the snapshot
+	Records int
+	// TruncatedBytes is the size of the torn tail cut off the log, left by a write
+	// interrupted by a crash
+	TruncatedBytes int64
+}
+
+// FileStore is a file backed implementation of Storer and OwnerStorer. Pets and owners
+// are held in memory, and every write is appended to a write-ahead log as a single
+// checksummed record before it is acknowledged, so that batches are applied atomically
+// on recovery too. Every SnapshotEvery records the whole state is written to a snapshot
+// and the log is emptied. On opening, the snapshot is loaded and the log replayed; a torn
+// record at the end of the log is cut off, while a corrupt record followed by valid data
+// fails the open.
+// If a write cannot be logged the state on disk is unknown, and the store stops serving
+// until it is reopened.
+type FileStore struct {
+	// mu is held for writing while a write is applied and logged, so that reads never
+	// see writes that are not logged
+	mu  sync.RWMutex
+	mem *MemStore
+	cfg FileConfig
+	log *os.File
+	lsn uint64
+	// lastPetID and lastOwnerID are the highest IDs ever stored, which sequential IDs
+	// continue after even if they were deleted
+	lastPetID   uint32
+	lastOwnerID uint32
+	records     int
+	dirty       bool
+	err         error
+	snapErr     error
+	recovery    FileRecovery
+	stop        chan struct{}
+	stopped     sync.WaitGroup
+}
+
+// walRecord is a single entry of the log, holding the changes made by one write.
+// LSN numbers records in order, and Version is the last version assigned to a pet.
+type walRecord struct {
+	LSN     uint64      `json:"lsn"`
+	Version uint64      `json:"version"`
+	Changes []walChange `json:"changes"`
+}
+
+// walChange puts or deletes a single pet or owner
+type walChange struct {
+	Op    string `json:"op"`
+	Pet   *Pet   `json:"pet,omitempty"`
+	Owner *Owner `json:"owner,omitempty"`
+	ID    uint32 `json:"id,omitempty"`
+}
+
+// fileSnapshot is the whole state of a FileStore as of the record LSN
+type fileSnapshot struct {
+	LSN         uint64  `json:"lsn"`
+	Version     uint64  `json:"version"`
+	LastPetID   uint32  `json:"last_pet_id"`
+	LastOwnerID uint32  `json:"last_owner_id"`
+	Pets        []Pet   `json:"pets"`
+	Owners      []Owner `json:"owners"`
+}
+
+// OpenFileStore opens the store in cfg.Dir, recovering its pets and owners
+func OpenFileStore(cfg FileConfig) (*FileStore, error) {
+	if cfg.SyncInterval <= 0 {
+		cfg.SyncInterval = DefaultSyncInterval
+	}
+	if cfg.SnapshotEvery <= 0 {
+		cfg.SnapshotEvery = DefaultSnapshotEvery
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, ErrorEf(ErrUnknown, err, "Could not create data directory %s", cfg.Dir)
+	}
+	f := &FileStore{mem: &MemStore{IDs: cfg.IDs}, cfg: cfg, stop: make(chan struct{})}
+	if err := f.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	logFile, err := os.OpenFile(filepath.Join(cfg.Dir, fileLogName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, ErrorEf(ErrUnknown, err, "Could not open log")
+	}
+	f.log = logFile
+	if err = f.replay(); err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	f.seedIDs()
+	if cfg.Sync == SyncInterval {
+		f.stopped.Add(1)
+		go f.syncPeriodically()
+	}
+	return f, nil
+}
+
+// Recovery describes what was recovered when the store was opened
+func (f *FileStore) Recovery() FileRecovery {
+	return f.recovery
+}
+
+// loadSnapshot loads the snapshot into memory, if there is one
+func (f *FileStore) loadSnapshot() error {
+	data, err := ioutil.ReadFile(filepath.Join(f.cfg.Dir, fileSnapshotName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not read snapshot")
+	}
+	var snapshot fileSnapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return ErrorEf(ErrUnknown, err, "Corrupt snapshot")
+	}
+	for _, pet := range snapshot.Pets {
+		f.mem.Store(pet.ID, pet)
+	}
+	for _, owner := range snapshot.Owners {
+		f.mem.storeOwner(owner)
+	}
+	f.mem.version = snapshot.Version
+	f.lsn = snapshot.LSN
+	f.lastPetID, f.lastOwnerID = snapshot.LastPetID, snapshot.LastOwnerID
+	return nil
+}
+
+// replay applies the records of the log that are newer than the snapshot, cutting off
+// a torn tail
+func (f *FileStore) replay() error {
+	info, err := f.log.Stat()
+	if err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not read log")
+	}
+	size := info.Size()
+	reader := bufio.NewReader(f.log)
+	var offset int64
+	for offset < size {
+		record, length, err := readWALRecord(reader, size-offset)
+		if err != nil {
+			torn, tornErr := f.tornAt(offset, size)
+			if tornErr != nil {
+				return tornErr
+			}
+			if !torn {
+				return ErrorEf(ErrUnknown, err, "Corrupt log record at offset %d", offset)
+			}
+			return f.truncate(offset, size)
+		}
+		offset += length
+		f.records++
+		if record.LSN <= f.lsn {
+			continue
+		}
+		f.apply(record)
+		f.recovery.Records++
+	}
+	f.recovery.Pets, f.recovery.Owners = f.count()
+	return nil
+}
+
+// readWALRecord reads the next record of the log, of which remaining bytes are left,
+// returning its length including the header
+func readWALRecord(r io.Reader, remaining int64) (*walRecord, int64, error) {
+	var header [walHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, 0, err
+	}
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if length == 0 || length > maxWALRecordSize || walHeaderSize+length > remaining {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, walTable) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, 0, Errorf(ErrUnknown, "Log record checksum mismatch")
+	}
+	var record walRecord
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return nil, 0, err
+	}
+	return &record, walHeaderSize + length, nil
+}
+
+// tornAt reports whether the invalid record at offset is the torn tail of an interrupted
+// write: either its header is cut short, or no valid record follows it. A corrupt length
+// can make a record in the middle of the log appear to run past its end, so the rest of
+// the log, up to tornScanLimit bytes, is searched for a valid record before the tail is
+// taken to be torn.
+func (f *FileStore) tornAt(offset, size int64) (bool, error) {
+	if size-offset < walHeaderSize {
+		return true, nil
+	}
+	if size-offset > tornScanLimit {
+		return false, nil
+	}
+	tail := make([]byte, size-offset)
+	if _, err := f.log.ReadAt(tail, offset); err != nil {
+		return false, ErrorEf(ErrUnknown, err, "Could not read log")
+	}
+	for next := 1; next+walHeaderSize < len(tail); next++ {
+		if isWALRecord(tail[next:]) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// isWALRecord reports whether data starts with a record whose length fits in data and
+// whose checksum matches. Only lengths that fit and payloads starting a JSON object are
+// checksummed, so scanning every offset of a tail stays cheap.
+func isWALRecord(data []byte) bool {
+	length := int64(binary.BigEndian.Uint32(data[:4]))
+	if length == 0 || walHeaderSize+length > int64(len(data)) || data[walHeaderSize] != '{' {
+		return false
+	}
+	payload := data[walHeaderSize : walHeaderSize+length]
+	return crc32.Checksum(payload, walTable) == binary.BigEndian.Uint32(data[4:walHeaderSize])
+}
+
+// truncate cuts the log off at offset
+func (f *FileStore) truncate(offset, size int64) error {
+	if err := f.log.Truncate(offset); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not truncate torn log")
+	}
+	if err := f.log.Sync(); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not truncate torn log")
+	}
+	f.recovery.TruncatedBytes = size - offset
+	f.recovery.Pets, f.recovery.Owners = f.count()
+	return nil
+}
+
+// apply replays a record onto the pets and owners in memory
+func (f *FileStore) apply(record *walRecord) {
+	for _, change := range record.Changes {
+		f.track(change)
+		switch change.Op {
+		case walPutPet:
+			f.mem.Store(change.Pet.ID, *change.Pet)
+		case walDeletePet:
+			f.mem.Delete(change.ID)
+		case walPutOwner:
+			f.mem.storeOwner(*change.Owner)
+		case walDeleteOwner:
+			delete(f.mem.owners, change.ID)
+		}
+	}
+	f.mem.version = record.Version
+	f.lsn = record.LSN
+}
+
+// track remembers the highest pet and owner IDs put by a change
+func (f *FileStore) track(change walChange) {
+	if change.Pet != nil && change.Pet.ID > f.lastPetID {
+		f.lastPetID = change.Pet.ID
+	}
+	if change.Owner != nil && change.Owner.ID > f.lastOwnerID {
+		f.lastOwnerID = change.Owner.ID
+	}
+}
+
+func (f *FileStore) count() (pets, owners int) {
+	f.mem.Range(func(_, _ interface{}) bool {
+		pets++
+		return true
+	})
+	return pets, len(f.mem.owners)
+}
+
+// seedIDs makes sequential IDs continue after the highest pet and owner IDs ever stored
+func (f *FileStore) seedIDs() {
+	if f.mem.IDs == nil {
+		f.mem.IDs = &SequentialIDs{}
+	}
+	if ids, ok := f.mem.IDs.(*SequentialIDs); ok {
+		ids.advance(f.lastPetID)
+	}
+	f.mem.ownerIDs.advance(f.lastOwnerID)
+}
+
+// write applies a write to memory with apply, and logs the changes it returns before
+// returning the error of apply
+func (f *FileStore) write(apply func() ([]walChange, error)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.failed()
+	}
+	changes, err := apply()
+	if len(changes) > 0 {
+		if logErr := f.append(changes); logErr != nil {
+			return logErr
+		}
+	}
+	return err
+}
+
+// append logs a record of changes, flushing it as the sync policy requires, and takes a
+// snapshot once enough records are logged. The store fails if the record is not logged.
+func (f *FileStore) append(changes []walChange) error {
+	record := walRecord{LSN: f.lsn + 1, Version: f.mem.version, Changes: changes}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		f.err = err
+		return f.failed()
+	}
+	data := make([]byte, walHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(data[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(data[4:walHeaderSize], crc32.Checksum(payload, walTable))
+	copy(data[walHeaderSize:], payload)
+	if _, err = f.log.Write(data); err != nil {
+		f.err = err
+		return f.failed()
+	}
+	f.lsn = record.LSN
+	f.records++
+	f.dirty = true
+	for _, change := range changes {
+		f.track(change)
+	}
+	if f.cfg.Sync == SyncAlways {
+		if err = f.syncLog(); err != nil {
+			return err
+		}
+	}
+	if f.records >= f.cfg.SnapshotEvery {
+		// the write is logged whether or not the snapshot succeeds, a failed snapshot
+		// is retried after the next write
+		f.snapErr = f.snapshot()
+	}
+	return nil
+}
+
+// syncLog flushes the log to disk if it has unflushed records, failing the store if it cannot
+func (f *FileStore) syncLog() error {
+	if !f.dirty {
+		return nil
+	}
+	if err := f.log.Sync(); err != nil {
+		f.err = err
+		return f.failed()
+	}
+	f.dirty = false
+	return nil
+}
+
+// failed returns the error of operations on a failed store
+func (f *FileStore) failed() error {
+	return ErrorEf(ErrUnknown, f.err, "File store failed, it must be reopened")
+}
+
+func (f *FileStore) syncPeriodically() {
+	defer f.stopped.Done()
+	ticker := time.NewTicker(f.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			if f.err == nil {
+				f.syncLog()
+			}
+			f.mu.Unlock()
+		}
+	}
+}
+
+// Snapshot writes the whole state of the store to a snapshot and empties the log
+func (f *FileStore) Snapshot(ctx context.Context) error {
+	if err := ContextError(ctx); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return f.failed()
+	}
+	f.snapErr = f.snapshot()
+	return f.snapErr
+}
+
+// snapshot replaces the snapshot with the state in memory, written to a temporary file
+// that is renamed over the old snapshot once flushed. The log is only emptied after the
+// rename, and records the snapshot already holds are skipped when the log is replayed.
+func (f *FileStore) snapshot() error {
+	snapshot := fileSnapshot{
+		LSN:         f.lsn,
+		Version:     f.mem.version,
+		LastPetID:   f.lastPetID,
+		LastOwnerID: f.lastOwnerID,
+		Pets:        []Pet{},
+		Owners:      []Owner{},
+	}
+	f.mem.Range(func(_, petData interface{}) bool {
+		if pet, ok := petData.(Pet); ok {
+			snapshot.Pets = append(snapshot.Pets, pet)
+		}
+		return true
+	})
+	sort.Slice(snapshot.Pets, func(i, j int) bool { return snapshot.Pets[i].ID < snapshot.Pets[j].ID })
+	for _, owner := range f.mem.owners {
+		snapshot.Owners = append(snapshot.Owners, owner)
+	}
+	sort.Slice(snapshot.Owners, func(i, j int) bool { return snapshot.Owners[i].ID < snapshot.Owners[j].ID })
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not write snapshot")
+	}
+	path := filepath.Join(f.cfg.Dir, fileSnapshotName)
+	if err = writeFileSynced(path+".tmp", data); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not write snapshot")
+	}
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not write snapshot")
+	}
+	if err = syncDir(f.cfg.Dir); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not write snapshot")
+	}
+	if err = f.log.Truncate(0); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not empty log")
+	}
+	f.dirty = true
+	if err = f.syncLog(); err != nil {
+		return err
+	}
+	f.records = 0
+	return nil
+}
+
+func writeFileSynced(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir flushes a directory, making renames within it durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Close flushes the log and closes it
+func (f *FileStore) Close() error {
+	close(f.stop)
+	f.stopped.Wait()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	if f.err == nil {
+		err = f.syncLog()
+	}
+	f.err = errFileStoreClosed
+	if closeErr := f.log.Close(); closeErr != nil && err == nil {
+		err = ErrorEf(ErrUnknown, closeErr, "Could not close log")
+	}
+	return err
+}
+
+// errFileStoreClosed fails the operations of a closed FileStore
+var errFileStoreClosed = Errorf(ErrUnknown, "File store is closed")
+
+// CheckHealth fails once the store has failed, or while snapshots fail
+func (f *FileStore) CheckHealth(context.Context) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.err != nil {
+		return f.failed()
+	}
+	return f.snapErr
+}
+
+// CreatePet adds a new pet to the store, allocating an ID if the pet's ID is 0
+func (f *FileStore) CreatePet(ctx context.Context, pet *Pet) error {
+	return f.write(func() ([]walChange, error) {
+		if err := f.mem.CreatePet(ctx, pet); err != nil {
+			return nil, err
+		}
+		return []walChange{putPetChange(pet)}, nil
+	})
+}
+
+// ReadPet gets a pet from the store given an ID
+func (f *FileStore) ReadPet(ctx context.Context, petID uint32) (*Pet, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.err != nil {
+		return nil, f.failed()
+	}
+	return f.mem.ReadPet(ctx, petID)
+}
+
+// UpdatePet puts new pet data to the store, either creating a new one or overriding an old
+func (f *FileStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) error {
+	return f.write(func() ([]walChange, error) {
+		if err := f.mem.UpdatePet(ctx, petID, pet, pre); err != nil {
+			return nil, err
+		}
+		stored := *pet
+		stored.ID = petID
+		return []walChange{putPetChange(&stored)}, nil
+	})
+}
+
+// DeletePet deletes a pet from the store
+func (f *FileStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (bool, error) {
+	var deleted bool
+	err := f.write(func() ([]walChange, error) {
+		var err error
+		deleted, err = f.mem.DeletePet(ctx, petID, pre)
+		if !deleted {
+			return nil, err
+		}
+		return []walChange{{Op: walDeletePet, ID: petID}}, err
+	})
+	return deleted, err
+}
+
+// ListPets returns a page of pets matching the query
+func (f *FileStore) ListPets(ctx context.Context, query ListQuery) (*PetPage, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.err != nil {
+		return nil, f.failed()
+	}
+	return f.mem.ListPets(ctx, query)
+}
+
+// Batch applies a batch of operations, logging the writes of the whole batch as a
+// single record
+func (f *FileStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	var results []BatchResult
+	err := f.write(func() ([]walChange, error) {
+		var err error
+		results, err = f.mem.Batch(ctx, ops, atomic)
+		if err != nil {
+			return nil, err
+		}
+		var changes []walChange
+		for i, result := range results {
+			switch {
+			case result.Err != nil:
+			case result.Pet != nil:
+				changes = append(changes, putPetChange(result.Pet))
+			case result.Deleted:
+				changes = append(changes, walChange{Op: walDeletePet, ID: ops[i].ID})
+			}
+		}
+		return changes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CreateOwner adds a new owner to the store, allocating an ID if the owner's ID is 0
+func (f *FileStore) CreateOwner(ctx context.Context, owner *Owner) error {
+	return f.write(func() ([]walChange, error) {
+		if err := f.mem.CreateOwner(ctx, owner); err != nil {
+			return nil, err
+		}
+		return []walChange{putOwnerChange(owner)}, nil
+	})
+}
+
+// ReadOwner gets an owner from the store given an ID
+func (f *FileStore) ReadOwner(ctx context.Context, ownerID uint32) (*Owner, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.err != nil {
+		return nil, f.failed()
+	}
+	return f.mem.ReadOwner(ctx, ownerID)
+}
+
+// UpdateOwner puts new owner data to the store, either creating a new one or overriding an old
+func (f *FileStore) UpdateOwner(ctx context.Context, ownerID uint32, owner *Owner) error {
+	return f.write(func() ([]walChange, error) {
+		if err := f.mem.UpdateOwner(ctx, ownerID, owner); err != nil {
+			return nil, err
+		}
+		return []walChange{putOwnerChange(owner)}, nil
+	})
+}
+
+// DeleteOwner deletes an owner from the store, failing with ErrReference while pets reference it
+func (f *FileStore) DeleteOwner(ctx context.Context, ownerID uint32) (bool, error) {
+	var deleted bool
+	err := f.write(func() ([]walChange, error) {
+		var err error
+		deleted, err = f.mem.DeleteOwner(ctx, ownerID)
+		if !deleted {
+			return nil, err
+		}
+		return []walChange{{Op: walDeleteOwner, ID: ownerID}}, err
+	})
+	return deleted, err
+}
+
+// putPetChange copies pet into a change, so that the caller cannot modify the logged pet
+func putPetChange(pet *Pet) walChange {
+	stored := *pet
+	return walChange{Op: walPutPet, Pet: &stored}
+}
+
+func putOwnerChange(owner *Owner) walChange {
+	stored := *owner
+	return walChange{Op: walPutOwner, Owner: &stored}
+}
@@ -0,0 +1,221 @@
+package pet
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tempFileStore(t *testing.T, cfg FileConfig) (*FileStore, func()) {
+	dir, err := ioutil.TempDir("", "filestore")
+	require.NoError(t, err)
+	cfg.Dir = dir
+	store, err := OpenFileStore(cfg)
+	require.NoError(t, err)
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func reopenFileStore(t *testing.T, store *FileStore) *FileStore {
+	require.NoError(t, store.Close())
+	reopened, err := OpenFileStore(store.cfg)
+	require.NoError(t, err)
+	return reopened
+}
+
+func TestFileStore_Reopen(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{SnapshotEvery: 3})
+	defer cleanup()
+	owner := &Owner{Name: "Marlin"}
+	require.NoError(t, store.CreateOwner(ctx, owner))
+	nemo := &Pet{Name: "Nemo", Species: "Clownfish", OwnerID: owner.ID}
+	require.NoError(t, store.CreatePet(ctx, nemo))
+	dory := &Pet{Name: "Dory", Species: "Blue Tang"}
+	require.NoError(t, store.CreatePet(ctx, dory))
+	_, err := store.Batch(ctx, []BatchOperation{
+		{Op: BatchUpsert, ID: nemo.ID, Pet: &Pet{Name: "Nemo", Species: "Clownfish", Extra: map[string]interface{}{"fins": 1.5}}},
+		{Op: BatchDelete, ID: dory.ID},
+	}, true)
+	require.NoError(t, err)
+	_, err = store.Batch(ctx, []BatchOperation{{Op: BatchCreate, Pet: &Pet{ID: nemo.ID, Name: "Duplicate", Species: "Shark"}}}, true)
+	require.Error(t, err)
+
+	// when
+	store = reopenFileStore(t, store)
+	defer store.Close()
+
+	// then
+	recovered, err := store.ReadPet(ctx, nemo.ID)
+	if assert.NoError(err, "Upserted pet should be recovered") {
+		assert.Equal(map[string]interface{}{"fins": 1.5}, recovered.Extra)
+		assert.Equal(uint64(3), recovered.Version, "Version should be recovered")
+	}
+	_, err = store.ReadPet(ctx, dory.ID)
+	assert.Error(err, "Deleted pet should stay deleted")
+	_, err = store.ReadOwner(ctx, owner.ID)
+	assert.NoError(err, "Owner should be recovered")
+	assert.Equal(FileRecovery{Pets: 1, Owners: 1, Records: 1}, store.Recovery(), "Snapshot should hold all but the last record")
+	next := &Pet{Name: "Gill", Species: "Moorish Idol"}
+	if assert.NoError(store.CreatePet(ctx, next)) {
+		assert.Equal(uint32(3), next.ID, "Sequential IDs should not reuse the IDs of deleted pets")
+		assert.Equal(uint64(4), next.Version, "Versions should continue after the recovered versions")
+	}
+}
+
+func TestFileStore_TornTail(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{})
+	defer cleanup()
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+	require.NoError(t, store.Close())
+	logPath := filepath.Join(store.cfg.Dir, fileLogName)
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	// the header of a record whose payload was never written
+	_, err = logFile.Write([]byte{0, 0, 0, 100, 1, 2, 3, 4, '{', '"'})
+	require.NoError(t, err)
+	require.NoError(t, logFile.Close())
+
+	// when
+	store, err = OpenFileStore(store.cfg)
+
+	// then
+	require.NoError(t, err, "Torn tail should not fail the open")
+	defer func() { store.Close() }()
+	assert.Equal(FileRecovery{Pets: 1, Records: 1, TruncatedBytes: 10}, store.Recovery())
+	assert.NoError(store.CreatePet(ctx, &Pet{ID: 2, Name: "Dory", Species: "Blue Tang"}), "Store should accept writes after truncating")
+	store = reopenFileStore(t, store)
+	assert.Equal(FileRecovery{Pets: 2, Records: 2}, store.Recovery(), "Writes after the truncation should be recovered")
+}
+
+func TestFileStore_CorruptRecord(t *testing.T) {
+	// given
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{})
+	defer cleanup()
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 2, Name: "Dory", Species: "Blue Tang"}))
+	require.NoError(t, store.Close())
+	logPath := filepath.Join(store.cfg.Dir, fileLogName)
+	data, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	data[walHeaderSize+2] ^= 0xff
+	require.NoError(t, ioutil.WriteFile(logPath, data, 0644))
+
+	// when
+	_, err = OpenFileStore(store.cfg)
+
+	// then
+	tassert.Error(t, err, "Corrupt record followed by valid records should fail the open")
+}
+
+func TestFileStore_CorruptLength(t *testing.T) {
+	// given
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{})
+	defer cleanup()
+	for id, name := range []string{"Nemo", "Dory", "Marlin"} {
+		require.NoError(t, store.CreatePet(ctx, &Pet{ID: uint32(id + 1), Name: name, Species: "Fish"}))
+	}
+	require.NoError(t, store.Close())
+	logPath := filepath.Join(store.cfg.Dir, fileLogName)
+	data, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	// the length of the first record now runs past the end of the log
+	data[1] ^= 0x01
+	require.NoError(t, ioutil.WriteFile(logPath, data, 0644))
+
+	// when
+	_, err = OpenFileStore(store.cfg)
+
+	// then
+	tassert.Error(t, err, "Corrupt length followed by valid records should fail the open")
+	after, readErr := ioutil.ReadFile(logPath)
+	require.NoError(t, readErr)
+	tassert.Equal(t, data, after, "Log should not be truncated")
+}
+
+func TestFileStore_LongInvalidTail(t *testing.T) {
+	// given
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{})
+	defer cleanup()
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+	require.NoError(t, store.Close())
+	logPath := filepath.Join(store.cfg.Dir, fileLogName)
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	// an invalid record followed by more than can be searched for a valid one
+	_, err = logFile.Write(make([]byte, tornScanLimit+1))
+	require.NoError(t, err)
+	require.NoError(t, logFile.Close())
+	before, err := os.Stat(logPath)
+	require.NoError(t, err)
+
+	// when
+	_, err = OpenFileStore(store.cfg)
+
+	// then
+	tassert.Error(t, err, "Invalid tail too long to search should fail the open")
+	after, statErr := os.Stat(logPath)
+	require.NoError(t, statErr)
+	tassert.Equal(t, before.Size(), after.Size(), "Log should not be truncated")
+}
+
+func TestFileStore_SnapshotBeforeLogEmptied(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{})
+	defer cleanup()
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+	_, err := store.DeletePet(ctx, 1, Precondition{})
+	require.NoError(t, err)
+	logPath := filepath.Join(store.cfg.Dir, fileLogName)
+	data, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	require.NoError(t, store.CreatePet(ctx, &Pet{ID: 1, Name: "Dory", Species: "Blue Tang"}))
+	require.NoError(t, store.Snapshot(ctx))
+	require.NoError(t, store.Close())
+	// a crash after the snapshot was written but before the log was emptied
+	require.NoError(t, ioutil.WriteFile(logPath, data, 0644))
+
+	// when
+	store, err = OpenFileStore(store.cfg)
+
+	// then
+	require.NoError(t, err)
+	defer store.Close()
+	pet, err := store.ReadPet(ctx, 1)
+	if assert.NoError(err, "Records held by the snapshot should not be replayed") {
+		assert.Equal("Dory", pet.Name)
+	}
+	assert.Equal(0, store.Recovery().Records)
+}
+
+func TestFileStore_FailsWhenLogFails(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	ctx := context.Background()
+	store, cleanup := tempFileStore(t, FileConfig{})
+	defer cleanup()
+	store.log.Close()
+
+	// when
+	err := store.CreatePet(ctx, &Pet{ID: 1, Name: "Nemo", Species: "Clownfish"})
+
+	// then
+	assert.Error(err, "Write should fail when it cannot be logged")
+	_, readErr := store.ReadPet(ctx, 1)
+	assert.Error(readErr, "Failed store should not serve reads")
+	assert.Error(store.CheckHealth(ctx), "Failed store should be unhealthy")
+}
@@ -0,0 +1,144 @@
+package pet
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+// HealthChecker is implemented by components, such as Storer backends, that can report
+// whether they are able to serve requests. CheckHealth returns an error when they cannot.
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to a HealthChecker
+type HealthCheckerFunc func(ctx context.Context) error
+
+// CheckHealth calls f
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+// DefaultHealthTimeout is how long readiness checks may take when Health.Timeout is zero
+const DefaultHealthTimeout = 2 * time.Second
+
+// Status values of a HealthReport and its components
+const (
+	HealthUp   = "up"
+	HealthDown = "down"
+)
+
+// HealthReport is the body of the liveness and readiness endpoints
+type HealthReport struct {
+	Status     string            `json:"status"`
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
+// ComponentHealth is the outcome of checking a single component
+type ComponentHealth struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type healthComponent struct {
+	name    string
+	checker HealthChecker
+}
+
+// Health reports the liveness of the server and the readiness of the components it depends on
+type Health struct {
+	// Timeout bounds how long all readiness checks may take together
+	Timeout    time.Duration
+	components []healthComponent
+}
+
+// NewHealth creates a Health that checks store if it implements HealthChecker
+func NewHealth(store Storer) *Health {
+	h := &Health{}
+	if checker, ok := store.(HealthChecker); ok {
+		h.Add("store", checker)
+	}
+	return h
+}
+
+// Add registers a component checked for readiness
+func (h *Health) Add(name string, checker HealthChecker) {
+	h.components = append(h.components, healthComponent{name: name, checker: checker})
+}
+
+// Check runs the checks of all components concurrently and reports the server down if any fails
+func (h *Health) Check(ctx context.Context) *HealthReport {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	report := &HealthReport{Status: HealthUp, Components: make([]ComponentHealth, len(h.components))}
+	var wg sync.WaitGroup
+	for i, c := range h.components {
+		wg.Add(1)
+		go func(i int, c healthComponent) {
+			defer wg.Done()
+			report.Components[i] = checkComponent(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, c := range report.Components {
+		if c.Status != HealthUp {
+			report.Status = HealthDown
+		}
+	}
+	return report
+}
+
+func checkComponent(ctx context.Context, c healthComponent) ComponentHealth {
+	start := time.Now()
+	err := c.checker.CheckHealth(ctx)
+	if err == nil {
+		// a check ignoring the context may return after the deadline
+		err = ctx.Err()
+	}
+	result := ComponentHealth{
+		Name:      c.name,
+		Status:    HealthUp,
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Status = HealthDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// SetupHealthRoutes adds the liveness and readiness endpoints to a router
+func SetupHealthRoutes(r chi.Router, h *Health) {
+	r.Get("/healthz", h.Live)
+	r.Get("/readyz", h.Ready)
+}
+
+// Live responds 200 OK while the server is able to handle requests at all
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, &HealthReport{Status: HealthUp})
+}
+
+// Ready responds 200 OK when every component is healthy and 503 Service Unavailable otherwise
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == HealthUp {
+		render.Status(r, http.StatusOK)
+	} else {
+		render.Status(r, http.StatusServiceUnavailable)
+	}
+	render.JSON(w, r, report)
+}
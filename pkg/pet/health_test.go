@@ -0,0 +1,123 @@
+package pet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+// uncheckedStore hides every method of a store beyond those of Storer
+type uncheckedStore struct {
+	Storer
+}
+
+// serveHealth sends a GET request for path to the health routes of h
+func serveHealth(h *Health, path string) (*httptest.ResponseRecorder, *HealthReport) {
+	router := chi.NewRouter()
+	SetupHealthRoutes(router, h)
+	req, _ := http.NewRequest("GET", path, nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	var report HealthReport
+	if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+		panic("Error in test code, health report is not valid JSON")
+	}
+	return resp, &report
+}
+
+func TestLive(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	h := NewHealth(NewMemStore())
+	h.Add("broken", HealthCheckerFunc(func(context.Context) error { return errors.New("broken") }))
+
+	// when
+	resp, report := serveHealth(h, "/healthz")
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Liveness should not depend on components")
+	assert.Equal(HealthUp, report.Status, "Server should be up")
+	assert.Empty(report.Components, "Liveness should not check components")
+}
+
+func TestReadyWithHealthyStore(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	h := NewHealth(NewMemStore())
+
+	// when
+	resp, report := serveHealth(h, "/readyz")
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	assert.Equal("no-store", resp.Header().Get("Cache-Control"), "Health reports should not be cached")
+	assert.Equal(HealthUp, report.Status, "Server should be ready")
+	if assert.Len(report.Components, 1, "Store should be checked") {
+		assert.Equal("store", report.Components[0].Name)
+		assert.Equal(HealthUp, report.Components[0].Status, "Memory store should be up")
+		assert.Empty(report.Components[0].Error)
+	}
+}
+
+func TestReadyWithUnavailableComponent(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	h := NewHealth(NewMemStore())
+	h.Add("broken", HealthCheckerFunc(func(context.Context) error {
+		return Errorf(ErrUnknown, "Could not connect to database")
+	}))
+
+	// when
+	resp, report := serveHealth(h, "/readyz")
+
+	// then
+	assert.Equal(http.StatusServiceUnavailable, resp.Code, "Response status should be 503 Service Unavailable")
+	assert.Equal(HealthDown, report.Status, "Server should not be ready")
+	if assert.Len(report.Components, 2, "Every component should be reported") {
+		assert.Equal(HealthUp, report.Components[0].Status, "Memory store should be up")
+		assert.Equal("broken", report.Components[1].Name)
+		assert.Equal(HealthDown, report.Components[1].Status, "Failing component should be down")
+		assert.Equal("Could not connect to database", report.Components[1].Error)
+	}
+}
+
+func TestReadyTimesOut(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	h := &Health{Timeout: 10 * time.Millisecond}
+	h.Add("slow", HealthCheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	// when
+	resp, report := serveHealth(h, "/readyz")
+
+	// then
+	assert.Equal(http.StatusServiceUnavailable, resp.Code, "Response status should be 503 Service Unavailable")
+	if assert.Len(report.Components, 1) {
+		assert.Equal(HealthDown, report.Components[0].Status, "Slow component should be down")
+		assert.True(report.Components[0].LatencyMS >= 10, "Latency should cover the time waited")
+	}
+}
+
+func TestReadyWithoutHealthChecker(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	h := NewHealth(uncheckedStore{NewMemStore()})
+
+	// when
+	resp, report := serveHealth(h, "/readyz")
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Stores without checks should be assumed ready")
+	assert.Empty(report.Components, "Stores without checks should not be reported")
+}
@@ -0,0 +1,124 @@
+package pet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator allocates IDs for pets created without one. Stores retry with a new ID
+// when a generated ID is already taken, so generators need not know which IDs are in use.
+// Generators that wait or query a database give up with ErrCanceled or ErrTimeout once
+// the context of the create is done.
+type IDGenerator interface {
+	NextID(ctx context.Context) (uint32, error)
+}
+
+// maxIDAttempts limits how often a store asks for a new ID when generated IDs are taken
+const maxIDAttempts = 100
+
+// SequentialIDs allocates increasing IDs starting at 1
+type SequentialIDs struct {
+	last uint32
+}
+
+// NextID returns the ID after the last one allocated
+func (s *SequentialIDs) NextID(context.Context) (uint32, error) {
+	id := atomic.AddUint32(&s.last, 1)
+	if id == 0 {
+		return 0, Errorf(ErrUnknown, "Sequential pet IDs are exhausted")
+	}
+	return id, nil
+}
+
+// advance makes the IDs allocated next follow id, unless they already do
+func (s *SequentialIDs) advance(id uint32) {
+	for {
+		last := atomic.LoadUint32(&s.last)
+		if last >= id || atomic.CompareAndSwapUint32(&s.last, last, id) {
+			return
+		}
+	}
+}
+
+// RandomIDs allocates uniformly random IDs
+type RandomIDs struct{}
+
+// NextID returns a random non-zero ID
+func (RandomIDs) NextID(context.Context) (uint32, error) {
+	var data [4]byte
+	for {
+		if _, err := rand.Read(data[:]); err != nil {
+			return 0, ErrorEf(ErrUnknown, err, "Could not generate a random pet ID")
+		}
+		if id := binary.BigEndian.Uint32(data[:]); id != 0 {
+			return id, nil
+		}
+	}
+}
+
+// Bit layout of snowflake IDs, from most to least significant
+const (
+	snowflakeTimeBits     = 21
+	snowflakeNodeBits     = 4
+	snowflakeSequenceBits = 7
+
+	// MaxSnowflakeNode is the largest node number of a SnowflakeIDs generator
+	MaxSnowflakeNode = 1<<snowflakeNodeBits - 1
+)
+
+// snowflakeEpoch is the time snowflake timestamps count from
+var snowflakeEpoch = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeIDs allocates roughly time ordered IDs without coordination between servers.
+// An ID packs the seconds since 2019 into its top 21 bits, followed by a 4 bit node
+// number unique to each server and a 7 bit sequence within the second. With only
+// 32 bits the timestamp wraps around every 24 days, after which IDs may collide with
+// older pets and are retried by the store; at most 128 IDs are allocated per second.
+type SnowflakeIDs struct {
+	sync.Mutex
+	node     uint32
+	now      func() time.Time
+	last     uint32
+	sequence uint32
+}
+
+// NewSnowflakeIDs creates a snowflake generator for the given node, between 0 and MaxSnowflakeNode
+func NewSnowflakeIDs(node uint32) (*SnowflakeIDs, error) {
+	if node > MaxSnowflakeNode {
+		return nil, Errorf(ErrInvalidInput, "Invalid snowflake node %d. Node should be at most %d", node, MaxSnowflakeNode)
+	}
+	return &SnowflakeIDs{node: node, now: time.Now}, nil
+}
+
+// NextID returns the next ID, waiting for the next second if this second's IDs are used
+// up, unless ctx is done first
+func (s *SnowflakeIDs) NextID(ctx context.Context) (uint32, error) {
+	s.Lock()
+	defer s.Unlock()
+	for {
+		now := s.now()
+		seconds := uint32(now.Sub(snowflakeEpoch)/time.Second) & (1<<snowflakeTimeBits - 1)
+		if seconds != s.last {
+			s.last, s.sequence = seconds, 0
+		}
+		if s.sequence < 1<<snowflakeSequenceBits {
+			id := seconds<<(snowflakeNodeBits+snowflakeSequenceBits) | s.node<<snowflakeSequenceBits | s.sequence
+			s.sequence++
+			if id != 0 {
+				return id, nil
+			}
+			continue
+		}
+		timer := time.NewTimer(now.Truncate(time.Second).Add(time.Second).Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ContextError(ctx)
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package pet
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func TestSequentialIDs(t *testing.T) {
+	// given
+	ids := &SequentialIDs{}
+
+	// when
+	first, err1 := ids.NextID(context.Background())
+	second, err2 := ids.NextID(context.Background())
+
+	// then
+	tassert.NoError(t, err1, "Sequential IDs should be allocated")
+	tassert.NoError(t, err2, "Sequential IDs should be allocated")
+	tassert.Equal(t, uint32(1), first, "First sequential ID should be 1")
+	tassert.Equal(t, uint32(2), second, "Second sequential ID should be 2")
+}
+
+func TestRandomIDs(t *testing.T) {
+	// given
+	seen := map[uint32]bool{}
+
+	for i := 0; i < 100; i++ {
+		// when
+		id, err := RandomIDs{}.NextID(context.Background())
+
+		// then
+		tassert.NoError(t, err, "Random IDs should be allocated")
+		tassert.NotZero(t, id, "Random IDs should not be 0")
+		seen[id] = true
+	}
+	tassert.True(t, len(seen) > 90, "Random IDs should rarely repeat")
+}
+
+func TestSnowflakeIDs(t *testing.T) {
+	// given
+	ids, err := NewSnowflakeIDs(5)
+	if err != nil {
+		panic("Error in test code, could not create snowflake generator")
+	}
+	start := snowflakeEpoch.Add(1000*time.Second + 999*time.Millisecond)
+	calls := 0
+	ids.now = func() time.Time {
+		calls++
+		if calls > 1<<snowflakeSequenceBits {
+			return start.Add(time.Second)
+		}
+		return start
+	}
+
+	// when
+	var allocated []uint32
+	for i := 0; i <= 1<<snowflakeSequenceBits; i++ {
+		id, err := ids.NextID(context.Background())
+		tassert.NoError(t, err, "Snowflake IDs should be allocated")
+		allocated = append(allocated, id)
+	}
+
+	// then
+	tassert.Equal(t, uint32(1000<<11|5<<7), allocated[0], "First ID should hold the time, node and sequence 0")
+	tassert.Equal(t, uint32(1000<<11|5<<7|127), allocated[127], "Last ID of the second should hold sequence 127")
+	tassert.Equal(t, uint32(1001<<11|5<<7), allocated[128], "IDs should continue in the next second once a second is used up")
+	for i := 1; i < len(allocated); i++ {
+		tassert.True(t, allocated[i] > allocated[i-1], "Snowflake IDs should increase")
+	}
+}
+
+func TestSnowflakeIDs_InvalidNode(t *testing.T) {
+	// when
+	_, err := NewSnowflakeIDs(MaxSnowflakeNode + 1)
+
+	// then
+	tassert.Error(t, err, "Nodes above the maximum should be rejected")
+}
+
+func TestSnowflakeIDs_GivesUpWhenContextIsDone(t *testing.T) {
+	// given
+	ids, err := NewSnowflakeIDs(5)
+	if err != nil {
+		panic("Error in test code, could not create snowflake generator")
+	}
+	ids.now = func() time.Time { return snowflakeEpoch.Add(1000 * time.Second) }
+	for i := 0; i < 1<<snowflakeSequenceBits; i++ {
+		_, err = ids.NextID(context.Background())
+		tassert.NoError(t, err, "Error in test code, could not use up the second")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// when
+	_, err = ids.NextID(ctx)
+
+	// then
+	tassert.True(t, errors.Is(err, ErrTimeoutSentinel), "Waiting for the next second should give up at the deadline, got %v", err)
+}
@@ -0,0 +1,88 @@
+package pet
+
+import (
+	"context"
+	"io"
+)
+
+// LegacyStorer is the Storer interface from before its operations took a context.
+// Backends written against it can still be served through FromLegacy.
+type LegacyStorer interface {
+	CreatePet(*Pet) error
+	ReadPet(ID uint32) (*Pet, error)
+	UpdatePet(ID uint32, pet *Pet, pre Precondition) error
+	DeletePet(ID uint32, pre Precondition) (bool, error)
+	ListPets(query ListQuery) (*PetPage, error)
+}
+
+// FromLegacy adapts a LegacyStorer to a Storer. An operation is not started once its
+// context is done, but the legacy store cannot interrupt one that is already running.
+// Batches are applied one operation at a time and cannot be atomic.
+// Close and CheckHealth are passed on if the legacy store implements them.
+func FromLegacy(store LegacyStorer) Storer {
+	return legacyStore{store: store}
+}
+
+type legacyStore struct {
+	store LegacyStorer
+}
+
+func (l legacyStore) CreatePet(ctx context.Context, pet *Pet) error {
+	if err := ContextError(ctx); err != nil {
+		return err
+	}
+	return l.store.CreatePet(pet)
+}
+
+func (l legacyStore) ReadPet(ctx context.Context, petID uint32) (*Pet, error) {
+	if err := ContextError(ctx); err != nil {
+		return nil, err
+	}
+	return l.store.ReadPet(petID)
+}
+
+func (l legacyStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) error {
+	if err := ContextError(ctx); err != nil {
+		return err
+	}
+	return l.store.UpdatePet(petID, pet, pre)
+}
+
+func (l legacyStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (bool, error) {
+	if err := ContextError(ctx); err != nil {
+		return false, err
+	}
+	return l.store.DeletePet(petID, pre)
+}
+
+func (l legacyStore) ListPets(ctx context.Context, query ListQuery) (*PetPage, error) {
+	if err := ContextError(ctx); err != nil {
+		return nil, err
+	}
+	return l.store.ListPets(query)
+}
+
+func (l legacyStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if atomic {
+		return nil, Errorf(ErrUnsupported, "Atomic batches are not supported by this store")
+	}
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = applyBatchOp(ctx, l, op)
+	}
+	return results, nil
+}
+
+func (l legacyStore) CheckHealth(ctx context.Context) error {
+	if checker, ok := l.store.(HealthChecker); ok {
+		return checker.CheckHealth(ctx)
+	}
+	return nil
+}
+
+func (l legacyStore) Close() error {
+	if closer, ok := l.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package pet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Page size limits for ListPets
+const (
+	// DefaultListLimit is the page size used when ListQuery.Limit is zero
+	DefaultListLimit = 50
+	// MaxListLimit is the largest page size ListPets accepts
+	MaxListLimit = 500
+)
+
+// sortFields maps the sort fields accepted by ListQuery to the pet values they order by.
+// Sorting by id has no entry as every ordering falls back to the ID.
+var sortFields = map[string]func(*Pet) string{
+	"name":    func(p *Pet) string { return p.Name },
+	"species": func(p *Pet) string { return p.Species },
+	"owner":   func(p *Pet) string { return p.Owner },
+}
+
+// listCursor is the decoded form of a page cursor. It records the sort position of the
+// last pet on a page rather than an offset, so the next page starts directly after that
+// pet even when other pets are created or deleted in between. It also records a hash
+// of the filters of the listing, as a position is meaningless under other filters.
+type listCursor struct {
+	Sort    string `json:"s"`
+	Filters string `json:"f,omitempty"`
+	Key     string `json:"k,omitempty"`
+	ID      uint32 `json:"i"`
+}
+
+// listPlan is a validated ListQuery shared by the Storer implementations
+type listPlan struct {
+	ListQuery
+	// field is the sort field, empty when sorting by id
+	field string
+	desc  bool
+	after *listCursor
+}
+
+// planList validates a query and fills in its defaults
+func planList(query ListQuery) (*listPlan, error) {
+	plan := &listPlan{ListQuery: query}
+	if plan.Sort == "" {
+		plan.Sort = "id"
+	}
+	plan.field = strings.TrimPrefix(plan.Sort, "-")
+	plan.desc = plan.field != plan.Sort
+	if _, ok := sortFields[plan.field]; !ok && plan.field != "id" {
+		return nil, Errorf(ErrInvalidInput, "Invalid sort %q. Sort should be one of id, name, species or owner, optionally prefixed with '-'", query.Sort).
+			AddField("sort", "should be one of id, name, species or owner")
+	}
+	if plan.field == "id" {
+		plan.field = ""
+	}
+	switch {
+	case plan.Limit == 0:
+		plan.Limit = DefaultListLimit
+	case plan.Limit < 0 || plan.Limit > MaxListLimit:
+		return nil, Errorf(ErrInvalidInput, "Invalid limit %d. Limit should be between 1 and %d", query.Limit, MaxListLimit).
+			AddField("limit", "should be between 1 and %d", MaxListLimit)
+	}
+	if plan.Cursor != "" {
+		cursor, err := decodeCursor(plan.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != plan.Sort {
+			return nil, Errorf(ErrInvalidInput, "Cursor was created for sort %q and cannot be used with sort %q", cursor.Sort, plan.Sort).
+				AddField("cursor", "does not match sort")
+		}
+		if cursor.Filters != plan.filtersHash() {
+			return nil, Errorf(ErrInvalidInput, "Cursor was created for other owner, owner_id, species or name_prefix filters").
+				AddField("cursor", "does not match filters")
+		}
+		plan.after = cursor
+	}
+	return plan, nil
+}
+
+// filtersHash identifies the filters of a query, empty when it has none
+func (p *listPlan) filtersHash() string {
+	if p.Owner == "" && p.OwnerID == 0 && p.Species == "" && p.NamePrefix == "" {
+		return ""
+	}
+	filters, err := json.Marshal([]interface{}{p.Owner, p.OwnerID, p.Species, p.NamePrefix})
+	if err != nil {
+		// Reaching this indicates a bug, the filters are strings and numbers
+		panic(err)
+	}
+	h := fnv.New64a()
+	h.Write(filters)
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+// sortKey returns the value of the sort field for a pet, empty when sorting by id
+func (p *listPlan) sortKey(pet *Pet) string {
+	if p.field == "" {
+		return ""
+	}
+	return sortFields[p.field](pet)
+}
+
+// compare orders a pet against a sort position, returning a negative number if the
+// pet comes first, zero if it is at the position and a positive number otherwise
+func (p *listPlan) compare(pet *Pet, key string, id uint32) int {
+	result := strings.Compare(p.sortKey(pet), key)
+	if result == 0 {
+		switch {
+		case pet.ID < id:
+			result = -1
+		case pet.ID > id:
+			result = 1
+		}
+	}
+	if p.desc {
+		return -result
+	}
+	return result
+}
+
+// less orders two pets for a listing
+func (p *listPlan) less(a, b *Pet) bool {
+	return p.compare(a, p.sortKey(b), b.ID) < 0
+}
+
+// matches reports whether a pet passes the query filters and comes after the cursor
+func (p *listPlan) matches(pet *Pet) bool {
+	if p.Owner != "" && pet.Owner != p.Owner {
+		return false
+	}
+	if p.OwnerID != 0 && pet.OwnerID != p.OwnerID {
+		return false
+	}
+	if p.Species != "" && pet.Species != p.Species {
+		return false
+	}
+	if !strings.HasPrefix(pet.Name, p.NamePrefix) {
+		return false
+	}
+	return p.after == nil || p.compare(pet, p.after.Key, p.after.ID) > 0
+}
+
+// page builds the result page from sorted, matching pets. Passing more than Limit
+// pets indicates another page exists, and a cursor to it is included.
+func (p *listPlan) page(pets []Pet) *PetPage {
+	page := &PetPage{Pets: pets}
+	if page.Pets == nil {
+		page.Pets = []Pet{}
+	}
+	if len(pets) > p.Limit {
+		page.Pets = pets[:p.Limit]
+		last := &page.Pets[p.Limit-1]
+		page.NextCursor = encodeCursor(&listCursor{Sort: p.Sort, Filters: p.filtersHash(), Key: p.sortKey(last), ID: last.ID})
+	}
+	return page
+}
+
+func encodeCursor(cursor *listCursor) string {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		// Reaching this indicates a bug, a cursor only holds strings and numbers
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*listCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Invalid cursor %q", cursor).AddField("cursor", "is malformed")
+	}
+	var decoded listCursor
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Invalid cursor %q", cursor).AddField("cursor", "is malformed")
+	}
+	return &decoded, nil
+}
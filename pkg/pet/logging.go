@@ -0,0 +1,82 @@
+package pet
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.service.anz/go/samplerest/pkg/route"
+
+	"github.com/go-chi/chi"
+	mw "github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// requestIDHeader carries the request ID, both on requests continuing an ID and on responses
+const requestIDHeader = "X-Request-Id"
+
+// RequestLogger logs every request as a structured entry with its request ID, route
+// pattern, pet or owner ID, status, latency and response size. Handlers find a logger
+// carrying the request ID in the request context. Request IDs are taken from chi's RequestID
+// middleware, which should run first, and are returned in the X-Request-Id header.
+func RequestLogger(logger logrus.FieldLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			requestID := mw.GetReqID(r.Context())
+			entry := logger.WithField("request_id", requestID)
+			if requestID != "" {
+				w.Header().Set(requestIDHeader, requestID)
+			}
+			ww := mw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), loggerKey, entry)))
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			pattern := route.Pattern(r)
+			fields := logrus.Fields{
+				"method":     r.Method,
+				"path":       r.URL.Path,
+				"route":      pattern,
+				"status":     status,
+				"latency_ms": float64(time.Since(start)) / float64(time.Millisecond),
+				"bytes":      ww.BytesWritten(),
+			}
+			if rctx, ok := r.Context().Value(chi.RouteCtxKey).(*chi.Context); ok && rctx.URLParam("id") != "" {
+				idField := "pet_id"
+				if strings.HasPrefix(pattern, "/api/owner/") {
+					idField = "owner_id"
+				}
+				fields[idField] = rctx.URLParam("id")
+			}
+			entry.WithFields(fields).Info("Request handled")
+		})
+	}
+}
+
+// requestLogger returns the logger of the request RequestLogger stored in ctx,
+// or the standard logger if there is none
+func requestLogger(ctx context.Context) logrus.FieldLogger {
+	if entry, ok := ctx.Value(loggerKey).(logrus.FieldLogger); ok {
+		return entry
+	}
+	return logrus.StandardLogger()
+}
+
+// logError logs an error response with the whole chain of causes behind it, which
+// are never disclosed to the client. Server errors are logged as errors and client
+// errors at info level.
+func logError(r *http.Request, problem *Problem, err error) {
+	entry := requestLogger(r.Context()).WithFields(logrus.Fields{
+		"status":     problem.Status,
+		"error_code": problem.Code,
+		"error":      CauseChain(err),
+	})
+	if problem.Status >= http.StatusInternalServerError {
+		entry.Error("Request failed")
+	} else {
+		entry.Info("Request rejected")
+	}
+}
@@ -0,0 +1,113 @@
+package pet
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+	mw "github.com/go-chi/chi/middleware"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	tassert "github.com/stretchr/testify/assert"
+)
+
+// loggedRouter creates a router serving store with request logging to a test logger
+func loggedRouter(store Storer) (chi.Router, *test.Hook) {
+	logger, hook := test.NewNullLogger()
+	router := chi.NewRouter()
+	router.Use(mw.RequestID)
+	router.Use(RequestLogger(logger))
+	SetupRoutes(router, NewPetService(store))
+	return router, hook
+}
+
+func TestRequestLogger(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, hook := loggedRouter(NewMemStore())
+	req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal("abc-123", resp.Header().Get(requestIDHeader), "Request ID should be returned")
+	entry := hook.LastEntry()
+	if assert.NotNil(entry, "Request should be logged") {
+		assert.Equal("Request handled", entry.Message)
+		assert.Equal(logrus.InfoLevel, entry.Level)
+		assert.Equal("abc-123", entry.Data["request_id"])
+		assert.Equal("GET", entry.Data["method"])
+		assert.Equal("/api/pet/{id}", entry.Data["route"])
+		assert.Equal("1000", entry.Data["pet_id"])
+		assert.Equal(http.StatusNotFound, entry.Data["status"])
+		assert.Equal(resp.Body.Len(), entry.Data["bytes"])
+		assert.Contains(entry.Data, "latency_ms")
+	}
+}
+
+func TestRequestLoggerGeneratesRequestIDs(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, hook := loggedRouter(NewMemStore())
+	req, _ := http.NewRequest("GET", "/api/pet/", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	requestID := resp.Header().Get(requestIDHeader)
+	assert.NotEmpty(requestID, "Request ID should be generated")
+	if entry := hook.LastEntry(); assert.NotNil(entry) {
+		assert.Equal(requestID, entry.Data["request_id"])
+		assert.NotContains(entry.Data, "pet_id", "Requests without a pet should not log a pet ID")
+	}
+}
+
+func TestErrorResponseLogsCauses(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	cause := fmt.Errorf("reading pet: %w", ErrorEf(ErrUnknown, errors.New("dial tcp 10.0.0.1:5432: secret cause"), "Could not read pet"))
+	router, hook := loggedRouter(&failingStore{MemStore: NewMemStore(), err: cause})
+	req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.NotContains(resp.Body.String(), "secret cause", "Causes should not be returned")
+	entries := hook.AllEntries()
+	if assert.Len(entries, 2, "Error and request should be logged") {
+		entry := entries[0]
+		assert.Equal(logrus.ErrorLevel, entry.Level, "Server errors should be logged as errors")
+		assert.Equal("abc-123", entry.Data["request_id"], "Error should be logged with the request ID")
+		assert.Equal("unknown", entry.Data["error_code"])
+		assert.Equal("reading pet: Could not read pet: dial tcp 10.0.0.1:5432: secret cause", entry.Data["error"],
+			"Every cause should be logged once")
+	}
+}
+
+func TestErrorResponseLogsClientErrorsAsInfo(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, hook := loggedRouter(NewMemStore())
+	req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+
+	// when
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	entries := hook.AllEntries()
+	if assert.Len(entries, 2) {
+		assert.Equal(logrus.InfoLevel, entries[0].Level, "Client errors should not be logged as errors")
+		assert.Equal("not_found", entries[0].Data["error_code"])
+	}
+}
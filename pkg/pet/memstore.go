@@ -1,6 +1,8 @@
 package pet
 
 import (
+	"context"
+	"sort"
 	"sync"
 )
 
@@ -8,6 +10,14 @@ import (
 type MemStore struct {
 	sync.Mutex
 	sync.Map
+	// IDs allocates the IDs of pets created without one, defaults to SequentialIDs
+	IDs IDGenerator
+	// version is the last version assigned to a pet, shared by all pets so that
+	// a version is never reused even when a pet is deleted and created again
+	version uint64
+	// owners holds the owners pets reference, guarded by the mutex like all writes
+	owners   map[uint32]Owner
+	ownerIDs SequentialIDs
 }
 
 // NewMemStore creates a new in-memory store with map intialised
@@ -15,19 +25,50 @@ func NewMemStore() *MemStore {
 	return &MemStore{}
 }
 
-// CreatePet adds a new pet to the store
-func (m *MemStore) CreatePet(pet *Pet) error {
+// lock acquires the write lock, giving up if ctx is done by the time it is acquired.
+// Nothing is written when it fails, and the lock is only held if it succeeds.
+func (m *MemStore) lock(ctx context.Context) error {
+	if err := ContextError(ctx); err != nil {
+		return err
+	}
 	m.Lock()
+	if err := ContextError(ctx); err != nil {
+		m.Unlock()
+		return err
+	}
+	return nil
+}
+
+// CreatePet adds a new pet to the store, allocating an ID if the pet's ID is 0
+func (m *MemStore) CreatePet(ctx context.Context, pet *Pet) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
 	defer m.Unlock()
+	if err := m.checkOwner(pet); err != nil {
+		return err
+	}
+	if pet.ID == 0 {
+		id, err := m.allocateID(ctx, m.load)
+		if err != nil {
+			return err
+		}
+		pet.ID = id
+	}
 	if _, ok := m.Load(pet.ID); ok {
 		return Errorf(ErrDuplicate, "Pet with id %d already exists", pet.ID)
 	}
+	m.version++
+	pet.Version = m.version
 	m.Store(pet.ID, *pet)
 	return nil
 }
 
 // ReadPet gets a pet from the store given an ID
-func (m *MemStore) ReadPet(petID uint32) (*Pet, error) {
+func (m *MemStore) ReadPet(ctx context.Context, petID uint32) (*Pet, error) {
+	if err := ContextError(ctx); err != nil {
+		return nil, err
+	}
 	petData, ok := m.Load(uint32(petID))
 	if !ok {
 		return nil, Errorf(ErrNotFound, "No pet exists with id %d", petID)
@@ -40,18 +81,283 @@ func (m *MemStore) ReadPet(petID uint32) (*Pet, error) {
 }
 
 // UpdatePet puts new pet data to the store, either creating a new one or overriding an old
-func (m *MemStore) UpdatePet(petID uint32, pet *Pet) error {
+func (m *MemStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.Unlock()
+	if err := pre.Check(petID, m.load(petID)); err != nil {
+		return err
+	}
+	if err := m.checkOwner(pet); err != nil {
+		return err
+	}
+	m.version++
+	pet.Version = m.version
 	m.Store(petID, *pet)
 	return nil
 }
 
 // DeletePet deletes a pet from the store
-func (m *MemStore) DeletePet(petID uint32) (bool, error) {
-	m.Lock()
+func (m *MemStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (bool, error) {
+	if err := m.lock(ctx); err != nil {
+		return false, err
+	}
 	defer m.Unlock()
-	if _, ok := m.Load(petID); !ok {
+	stored := m.load(petID)
+	if err := pre.Check(petID, stored); err != nil {
+		return false, err
+	}
+	if stored == nil {
 		return false, nil
 	}
 	m.Delete(petID)
 	return true, nil
 }
+
+// allocateID returns a generated ID that load finds no pet for
+func (m *MemStore) allocateID(ctx context.Context, load func(uint32) *Pet) (uint32, error) {
+	if m.IDs == nil {
+		m.IDs = &SequentialIDs{}
+	}
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := m.IDs.NextID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if load(id) == nil {
+			return id, nil
+		}
+	}
+	return 0, Errorf(ErrUnknown, "Could not allocate a free pet ID")
+}
+
+// load returns the stored pet with the given ID, or nil if there is none
+func (m *MemStore) load(petID uint32) *Pet {
+	petData, ok := m.Load(petID)
+	if !ok {
+		return nil
+	}
+	pet, ok := petData.(Pet)
+	if !ok {
+		return nil
+	}
+	return &pet
+}
+
+// Batch applies a batch of operations under the write lock, so that no other write
+// is interleaved. Writes are staged and only stored once the batch is known to succeed.
+func (m *MemStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.Unlock()
+	batch := &memBatch{m: m, staged: map[uint32]*Pet{}, version: m.version}
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = batch.apply(ctx, op)
+		if atomic && results[i].Err != nil {
+			return nil, batchFailure(i, results[i].Err)
+		}
+	}
+	m.version = batch.version
+	for petID, pet := range batch.staged {
+		if pet == nil {
+			m.Delete(petID)
+		} else {
+			m.Store(petID, *pet)
+		}
+	}
+	return results, nil
+}
+
+// memBatch stages the writes of a batch on top of the pets of a MemStore.
+// A nil staged pet is deleted.
+type memBatch struct {
+	m       *MemStore
+	staged  map[uint32]*Pet
+	version uint64
+}
+
+// load returns the pet with the given ID as staged, or nil if there is none
+func (b *memBatch) load(petID uint32) *Pet {
+	if pet, ok := b.staged[petID]; ok {
+		return pet
+	}
+	return b.m.load(petID)
+}
+
+// apply stages a single operation, following the rules of the equivalent pet operation
+func (b *memBatch) apply(ctx context.Context, op BatchOperation) BatchResult {
+	if err := op.validate(); err != nil {
+		return BatchResult{Err: err}
+	}
+	if op.Op == BatchDelete {
+		deleted := b.load(op.ID) != nil
+		b.staged[op.ID] = nil
+		return BatchResult{Deleted: deleted}
+	}
+	pet := *op.Pet
+	if err := b.m.checkOwner(&pet); err != nil {
+		return BatchResult{Err: err}
+	}
+	if op.Op == BatchUpsert {
+		pet.ID = op.ID
+	} else if pet.ID == 0 {
+		id, err := b.m.allocateID(ctx, b.load)
+		if err != nil {
+			return BatchResult{Err: err}
+		}
+		pet.ID = id
+	} else if b.load(pet.ID) != nil {
+		return BatchResult{Err: Errorf(ErrDuplicate, "Pet with id %d already exists", pet.ID)}
+	}
+	b.version++
+	pet.Version = b.version
+	b.staged[pet.ID] = &pet
+	stored := pet
+	return BatchResult{Pet: &stored}
+}
+
+// memScanCheck is how many pets ListPets scans between checks for cancellation
+const memScanCheck = 256
+
+// ListPets returns a page of pets matching the query
+func (m *MemStore) ListPets(ctx context.Context, query ListQuery) (*PetPage, error) {
+	if err := ContextError(ctx); err != nil {
+		return nil, err
+	}
+	plan, err := planList(query)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pets    []Pet
+		scanned int
+		ctxErr  *Error
+	)
+	m.Range(func(_, petData interface{}) bool {
+		if scanned++; scanned%memScanCheck == 0 {
+			if ctxErr = ContextError(ctx); ctxErr != nil {
+				return false
+			}
+		}
+		if pet, ok := petData.(Pet); ok && plan.matches(&pet) {
+			pets = append(pets, pet)
+		}
+		return true
+	})
+	if ctxErr != nil {
+		return nil, ctxErr
+	}
+	sort.Slice(pets, func(i, j int) bool {
+		return plan.less(&pets[i], &pets[j])
+	})
+	if len(pets) > plan.Limit+1 {
+		pets = pets[:plan.Limit+1]
+	}
+	return plan.page(pets), nil
+}
+
+// CheckHealth always succeeds, the in-memory store cannot become unavailable
+func (m *MemStore) CheckHealth(context.Context) error {
+	return nil
+}
+
+// checkOwner returns an ErrReference error if the owner the pet references does not exist
+func (m *MemStore) checkOwner(pet *Pet) error {
+	if pet.OwnerID == 0 {
+		return nil
+	}
+	if _, ok := m.owners[pet.OwnerID]; !ok {
+		return Errorf(ErrReference, "No owner exists with id %d", pet.OwnerID)
+	}
+	return nil
+}
+
+// CreateOwner adds a new owner to the store, allocating an ID if the owner's ID is 0
+func (m *MemStore) CreateOwner(ctx context.Context, owner *Owner) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.Unlock()
+	if owner.ID == 0 {
+		id, err := m.allocateOwnerID(ctx)
+		if err != nil {
+			return err
+		}
+		owner.ID = id
+	}
+	if _, ok := m.owners[owner.ID]; ok {
+		return Errorf(ErrDuplicate, "Owner with id %d already exists", owner.ID)
+	}
+	m.storeOwner(*owner)
+	return nil
+}
+
+// ReadOwner gets an owner from the store given an ID
+func (m *MemStore) ReadOwner(ctx context.Context, ownerID uint32) (*Owner, error) {
+	if err := m.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer m.Unlock()
+	owner, ok := m.owners[ownerID]
+	if !ok {
+		return nil, Errorf(ErrNotFound, "No owner exists with id %d", ownerID)
+	}
+	return &owner, nil
+}
+
+// UpdateOwner puts new owner data to the store, either creating a new one or overriding an old
+func (m *MemStore) UpdateOwner(ctx context.Context, ownerID uint32, owner *Owner) error {
+	if err := m.lock(ctx); err != nil {
+		return err
+	}
+	defer m.Unlock()
+	owner.ID = ownerID
+	m.storeOwner(*owner)
+	return nil
+}
+
+// DeleteOwner deletes an owner from the store, failing with ErrReference while pets reference it
+func (m *MemStore) DeleteOwner(ctx context.Context, ownerID uint32) (bool, error) {
+	if err := m.lock(ctx); err != nil {
+		return false, err
+	}
+	defer m.Unlock()
+	if _, ok := m.owners[ownerID]; !ok {
+		return false, nil
+	}
+	referenced := false
+	m.Range(func(_, petData interface{}) bool {
+		pet, ok := petData.(Pet)
+		referenced = ok && pet.OwnerID == ownerID
+		return !referenced
+	})
+	if referenced {
+		return false, Errorf(ErrReference, "Owner with id %d still has pets", ownerID)
+	}
+	delete(m.owners, ownerID)
+	return true, nil
+}
+
+func (m *MemStore) storeOwner(owner Owner) {
+	if m.owners == nil {
+		m.owners = map[uint32]Owner{}
+	}
+	m.owners[owner.ID] = owner
+}
+
+// allocateOwnerID returns a sequential owner ID that is not in use
+func (m *MemStore) allocateOwnerID(ctx context.Context) (uint32, error) {
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := m.ownerIDs.NextID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if _, taken := m.owners[id]; !taken {
+			return id, nil
+		}
+	}
+	return 0, Errorf(ErrUnknown, "Could not allocate a free owner ID")
+}
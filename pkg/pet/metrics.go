@@ -0,0 +1,103 @@
+package pet
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.service.anz/go/samplerest/pkg/metrics"
+)
+
+// MetricsStore is a Storer decorator that counts the operations of the store it wraps
+// and records their latency, labelled by operation and result. The result is "ok" or
+// the name of the error code the operation failed with.
+type MetricsStore struct {
+	wrappedStore
+	operations *metrics.Counter
+	durations  *metrics.Histogram
+}
+
+// NewMetricsStore wraps store, registering its metrics with registry
+func NewMetricsStore(store Storer, registry *metrics.Registry) *MetricsStore {
+	return &MetricsStore{
+		wrappedStore: wrappedStore{store},
+		operations: registry.NewCounter("pet_store_operations_total",
+			"Number of pet store operations.", "operation", "result"),
+		durations: registry.NewHistogram("pet_store_operation_duration_seconds",
+			"Time taken by pet store operations.", metrics.DefaultBuckets, "operation", "result"),
+	}
+}
+
+// observe records an operation that started at start and finished with err
+func (m *MetricsStore) observe(operation string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = ErrorCodeName(ErrUnknown)
+		var petErr *Error
+		if errors.As(err, &petErr) {
+			result = ErrorCodeName(petErr.Code)
+		}
+	}
+	m.operations.Inc(operation, result)
+	m.durations.Observe(time.Since(start).Seconds(), operation, result)
+}
+
+// CreatePet creates a pet in the wrapped store
+func (m *MetricsStore) CreatePet(ctx context.Context, pet *Pet) (err error) {
+	defer func(start time.Time) { m.observe("create", start, err) }(time.Now())
+	return m.store.CreatePet(ctx, pet)
+}
+
+// ReadPet reads a pet from the wrapped store
+func (m *MetricsStore) ReadPet(ctx context.Context, petID uint32) (pet *Pet, err error) {
+	defer func(start time.Time) { m.observe("read", start, err) }(time.Now())
+	return m.store.ReadPet(ctx, petID)
+}
+
+// UpdatePet updates a pet in the wrapped store
+func (m *MetricsStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) (err error) {
+	defer func(start time.Time) { m.observe("update", start, err) }(time.Now())
+	return m.store.UpdatePet(ctx, petID, pet, pre)
+}
+
+// DeletePet deletes a pet from the wrapped store
+func (m *MetricsStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (deleted bool, err error) {
+	defer func(start time.Time) { m.observe("delete", start, err) }(time.Now())
+	return m.store.DeletePet(ctx, petID, pre)
+}
+
+// ListPets lists pets in the wrapped store
+func (m *MetricsStore) ListPets(ctx context.Context, query ListQuery) (page *PetPage, err error) {
+	defer func(start time.Time) { m.observe("list", start, err) }(time.Now())
+	return m.store.ListPets(ctx, query)
+}
+
+// Batch applies a batch of operations to the wrapped store
+func (m *MetricsStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) (results []BatchResult, err error) {
+	defer func(start time.Time) { m.observe("batch", start, err) }(time.Now())
+	return m.store.Batch(ctx, ops, atomic)
+}
+
+// CreateOwner creates an owner in the wrapped store
+func (m *MetricsStore) CreateOwner(ctx context.Context, owner *Owner) (err error) {
+	defer func(start time.Time) { m.observe("create_owner", start, err) }(time.Now())
+	return m.owners().CreateOwner(ctx, owner)
+}
+
+// ReadOwner reads an owner from the wrapped store
+func (m *MetricsStore) ReadOwner(ctx context.Context, ownerID uint32) (owner *Owner, err error) {
+	defer func(start time.Time) { m.observe("read_owner", start, err) }(time.Now())
+	return m.owners().ReadOwner(ctx, ownerID)
+}
+
+// UpdateOwner updates an owner in the wrapped store
+func (m *MetricsStore) UpdateOwner(ctx context.Context, ownerID uint32, owner *Owner) (err error) {
+	defer func(start time.Time) { m.observe("update_owner", start, err) }(time.Now())
+	return m.owners().UpdateOwner(ctx, ownerID, owner)
+}
+
+// DeleteOwner deletes an owner from the wrapped store
+func (m *MetricsStore) DeleteOwner(ctx context.Context, ownerID uint32) (deleted bool, err error) {
+	defer func(start time.Time) { m.observe("delete_owner", start, err) }(time.Now())
+	return m.owners().DeleteOwner(ctx, ownerID)
+}
@@ -0,0 +1,112 @@
+package pet
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.service.anz/go/samplerest/pkg/metrics"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+// unhealthyStore is a Storer whose health check always fails
+type unhealthyStore struct {
+	Storer
+}
+
+func (unhealthyStore) CheckHealth(context.Context) error {
+	return Errorf(ErrUnknown, "Store is unavailable")
+}
+
+func TestMetricsStoreCountsOperations(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	registry := metrics.NewRegistry()
+	store := NewMetricsStore(NewMemStore(), registry)
+	testPet := pet1000()
+
+	// when
+	createErr := store.CreatePet(context.Background(), &testPet)
+	_, readErr := store.ReadPet(context.Background(), 1000)
+	_, missingErr := store.ReadPet(context.Background(), 1001)
+	duplicateErr := store.CreatePet(context.Background(), &testPet)
+
+	// then
+	assert.NoError(createErr)
+	assert.NoError(readErr)
+	assert.Error(missingErr)
+	assert.Error(duplicateErr)
+	assert.Equal(1.0, store.operations.Value("create", "ok"), "Successful create should be counted")
+	assert.Equal(1.0, store.operations.Value("create", "duplicate"), "Duplicate create should be counted by error code")
+	assert.Equal(1.0, store.operations.Value("read", "ok"), "Successful read should be counted")
+	assert.Equal(1.0, store.operations.Value("read", "not_found"), "Missing read should be counted by error code")
+	assert.Equal(uint64(1), store.durations.Count("read", "not_found"), "Read latency should be recorded")
+}
+
+func TestMetricsStoreCountsForeignErrors(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := NewMetricsStore(&failingStore{MemStore: NewMemStore(), err: errors.New("boom")}, metrics.NewRegistry())
+
+	// when
+	_, err := store.ReadPet(context.Background(), 1000)
+
+	// then
+	assert.Error(err)
+	assert.Equal(1.0, store.operations.Value("read", "unknown"), "Errors that are not pet errors should count as unknown")
+}
+
+func TestMetricsStoreForwardsOptionalInterfaces(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	checked := NewMetricsStore(unhealthyStore{NewMemStore()}, metrics.NewRegistry())
+	unchecked := NewMetricsStore(uncheckedStore{NewMemStore()}, metrics.NewRegistry())
+
+	// when
+	checkedErr := checked.CheckHealth(context.Background())
+	uncheckedErr := unchecked.CheckHealth(context.Background())
+	closeErr := unchecked.Close()
+
+	// then
+	assert.Error(checkedErr, "Health check should be forwarded to the wrapped store")
+	assert.NoError(uncheckedErr, "Stores without health checks should be healthy")
+	assert.NoError(closeErr, "Stores that cannot be closed should close successfully")
+}
+
+func TestMetricsStoreCountsOwnerOperations(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := NewMetricsStore(NewMemStore(), metrics.NewRegistry())
+	owners, ok := OwnerStore(store)
+	if !assert.True(ok, "Decorated MemStore should store owners") {
+		return
+	}
+
+	// when
+	createErr := owners.CreateOwner(context.Background(), &Owner{Name: "Andy"})
+	_, missingErr := owners.ReadOwner(context.Background(), 99)
+
+	// then
+	assert.NoError(createErr)
+	assert.Error(missingErr)
+	assert.Equal(1.0, store.operations.Value("create_owner", "ok"), "Owner create should be counted")
+	assert.Equal(1.0, store.operations.Value("read_owner", "not_found"), "Missing owner read should be counted by error code")
+}
+
+func TestOwnerStore_SeesThroughDecorators(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	withoutOwners := NewPublishingStore(NewMetricsStore(uncheckedStore{NewMemStore()}, metrics.NewRegistry()), NewEventHub(0))
+
+	// when
+	_, ok := OwnerStore(withoutOwners)
+	createErr := withoutOwners.CreateOwner(context.Background(), &Owner{Name: "Andy"})
+
+	// then
+	assert.False(ok, "Decorators of a store without owners should not store owners")
+	var petErr *Error
+	if assert.True(errors.As(createErr, &petErr), "%v should be a pet Error", createErr) {
+		assert.Equal(ErrUnsupported, petErr.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package pet
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// mediaRange is a single entry of an Accept header
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses an Accept header into its media ranges, skipping malformed entries
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if qParam, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(qParam, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+// quality returns the q value the media ranges give to a media type, using the most
+// specific matching range, and -1 if no range matches
+func quality(ranges []mediaRange, mediaType string) float64 {
+	q, specificity := -1.0, -1
+	slash := strings.Index(mediaType, "/")
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.mediaType == mediaType:
+			s = 2
+		case slash > 0 && r.mediaType == mediaType[:slash]+"/*":
+			s = 1
+		case r.mediaType == "*/*":
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// negotiate picks the offered media type the Accept header prefers, favouring earlier
+// offers on ties. An empty Accept header accepts the first offer. An empty string is
+// returned if the header rejects every offer.
+func negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
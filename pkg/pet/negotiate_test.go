@@ -0,0 +1,24 @@
+package pet
+
+import (
+	"testing"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "text/csv"}
+	cases := []struct{ accept, expected string }{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"text/csv", "text/csv"},
+		{"application/xml;q=0.8, text/csv;q=0.9", "text/csv"},
+		{"application/*;q=0.5, application/xml", "application/xml"},
+		{"*/*;q=0.1, application/json;q=0", "application/xml"},
+		{"text/html", ""},
+		{"application/json;q=abc, text/csv", "text/csv"},
+	}
+	for _, c := range cases {
+		tassert.Equal(t, c.expected, negotiate(c.accept, offers...), "Unexpected negotiation for Accept %q", c.accept)
+	}
+}
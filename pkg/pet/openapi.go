@@ -0,0 +1,546 @@
+package pet
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the API is described in
+const openAPIVersion = "3.0.3"
+
+// OpenAPI is an OpenAPI 3 document describing the routes of the API
+type OpenAPI struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	// Paths maps each path to its operations by lower case method
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components jsonObject                       `json:"components"`
+}
+
+// Operation describes a single method of a path
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []jsonObject          `json:"parameters,omitempty"`
+	RequestBody jsonObject            `json:"requestBody,omitempty"`
+	Responses   map[string]jsonObject `json:"responses"`
+}
+
+// jsonObject is a free form part of the OpenAPI document
+type jsonObject map[string]interface{}
+
+// apiOperations describes the operations of the routes set up by this package, keyed by
+// method and path as in "GET /api/pet/{id}", with pets in the media types of codecs
+func apiOperations(codecs *Codecs) map[string]*Operation {
+	return map[string]*Operation{
+		"GET /api/pet": {
+			OperationID: "listPets",
+			Summary:     "List pets matching the filters, a page at a time",
+			Tags:        []string{"pets"},
+			Parameters:  listParameters(),
+			Responses: errorResponses(map[string]jsonObject{
+				"200": withHeaders(petResponse(codecs, "A page of pets", schemaRef("PetPage")), "Link"),
+			}, ErrInvalidInput, ErrNotAcceptable),
+		},
+		"POST /api/pet": {
+			OperationID: "createPet",
+			Summary:     "Create a pet, allocating an ID if it has none",
+			Tags:        []string{"pets"},
+			RequestBody: petRequestBody(codecs),
+			Responses: errorResponses(map[string]jsonObject{
+				"201": withHeaders(petResponse(codecs, "The created pet", schemaRef("Pet")), "ETag", "Location"),
+			}, ErrInvalidInput, ErrDuplicate, ErrReference, ErrUnsupportedMediaType, ErrTooLarge, ErrNotAcceptable),
+		},
+		"GET /api/pet/{id}": {
+			OperationID: "getPet",
+			Summary:     "Read a pet",
+			Tags:        []string{"pets"},
+			Parameters:  []jsonObject{ref("parameters", "PetID")},
+			Responses: errorResponses(map[string]jsonObject{
+				"200": withHeaders(petResponse(codecs, "The pet", schemaRef("Pet")), "ETag"),
+			}, ErrInvalidInput, ErrNotFound, ErrNotAcceptable),
+		},
+		"PUT /api/pet/{id}": {
+			OperationID: "putPet",
+			Summary:     "Create or replace a pet, conditionally on its version",
+			Tags:        []string{"pets"},
+			Parameters:  []jsonObject{ref("parameters", "PetID"), ref("parameters", "IfMatch"), ref("parameters", "IfNoneMatch")},
+			RequestBody: petRequestBody(codecs),
+			Responses: errorResponses(map[string]jsonObject{
+				"201": withHeaders(petResponse(codecs, "The pet as stored", schemaRef("Pet")), "ETag"),
+			}, ErrInvalidInput, ErrConflict, ErrReference, ErrUnsupportedMediaType, ErrTooLarge, ErrNotAcceptable),
+		},
+		"PATCH /api/pet/{id}": {
+			OperationID: "patchPet",
+			Summary:     "Modify part of a pet with a JSON Merge Patch or a JSON Patch",
+			Tags:        []string{"pets"},
+			Parameters:  []jsonObject{ref("parameters", "PetID"), ref("parameters", "IfMatch"), ref("parameters", "IfNoneMatch")},
+			RequestBody: jsonObject{
+				"required": true,
+				"content": jsonObject{
+					mergePatchType: jsonObject{"schema": jsonObject{"type": "object", "description": "JSON Merge Patch (RFC 7396) of the pet"}},
+					jsonPatchType:  jsonObject{"schema": schemaRef("JSONPatch")},
+				},
+			},
+			Responses: errorResponses(map[string]jsonObject{
+				"200": withHeaders(petResponse(codecs, "The patched pet", schemaRef("Pet")), "ETag"),
+			}, ErrInvalidInput, ErrNotFound, ErrConflict, ErrReference, ErrUnsupportedMediaType, ErrTooLarge, ErrNotAcceptable, ErrPatchTestFailed),
+		},
+		"DELETE /api/pet/{id}": {
+			OperationID: "deletePet",
+			Summary:     "Delete a pet, conditionally on its version",
+			Tags:        []string{"pets"},
+			Parameters:  []jsonObject{ref("parameters", "PetID"), ref("parameters", "IfMatch"), ref("parameters", "IfNoneMatch")},
+			Responses: errorResponses(map[string]jsonObject{
+				"200": response("The pet was deleted", nil),
+				"204": response("No pet existed", nil),
+			}, ErrInvalidInput, ErrConflict),
+		},
+		"POST /api/pet:batch": {
+			OperationID: "batchPets",
+			Summary:     "Apply create, upsert and delete operations, atomically unless mode is best_effort",
+			Tags:        []string{"pets"},
+			Parameters: []jsonObject{{
+				"name": "mode", "in": "query",
+				"schema": jsonObject{"type": "string", "enum": []string{batchModeAtomic, batchModeBestEffort}, "default": batchModeAtomic},
+			}},
+			RequestBody: requestBody(jsonObject{"type": "array", "maxItems": MaxBatchSize, "items": schemaRef("BatchOperation")}),
+			Responses: errorResponses(map[string]jsonObject{
+				"200": response("The outcome of each operation", schemaRef("BatchResponse")),
+			}, ErrInvalidInput, ErrDuplicate, ErrNotFound, ErrReference, ErrUnsupported, ErrUnsupportedMediaType, ErrTooLarge),
+		},
+		"GET /api/pet/events": {
+			OperationID: "streamPetEvents",
+			Summary:     "Stream pet changes as Server-Sent Events, resuming after Last-Event-ID",
+			Tags:        []string{"pets"},
+			Parameters: []jsonObject{{
+				"name": "Last-Event-ID", "in": "header",
+				"description": "ID of the last event received, the events after it are replayed",
+				"schema":      jsonObject{"type": "integer", "minimum": 0},
+			}},
+			Responses: errorResponses(map[string]jsonObject{
+				"200": {
+					"description": "Events with the type as event name and an Event as data. A reset event means events were missed.",
+					"content": jsonObject{"text/event-stream": jsonObject{"schema": jsonObject{
+						"type": "string", "description": "Stream of events, whose data is described by the Event schema",
+					}}},
+				},
+			}, ErrInvalidInput),
+		},
+		"POST /api/owner": {
+			OperationID: "createOwner",
+			Summary:     "Create an owner, allocating an ID if it has none",
+			Tags:        []string{"owners"},
+			RequestBody: requestBody(schemaRef("Owner")),
+			Responses: errorResponses(map[string]jsonObject{
+				"201": withHeaders(response("The created owner", schemaRef("Owner")), "Location"),
+			}, ErrInvalidInput, ErrDuplicate, ErrUnsupportedMediaType, ErrTooLarge),
+		},
+		"GET /api/owner/{id}": {
+			OperationID: "getOwner",
+			Summary:     "Read an owner",
+			Tags:        []string{"owners"},
+			Parameters:  []jsonObject{ref("parameters", "OwnerID")},
+			Responses: errorResponses(map[string]jsonObject{
+				"200": response("The owner", schemaRef("Owner")),
+			}, ErrInvalidInput, ErrNotFound),
+		},
+		"PUT /api/owner/{id}": {
+			OperationID: "putOwner",
+			Summary:     "Create or replace an owner",
+			Tags:        []string{"owners"},
+			Parameters:  []jsonObject{ref("parameters", "OwnerID")},
+			RequestBody: requestBody(schemaRef("Owner")),
+			Responses: errorResponses(map[string]jsonObject{
+				"201": response("The owner was written", nil),
+			}, ErrInvalidInput, ErrUnsupportedMediaType, ErrTooLarge),
+		},
+		"DELETE /api/owner/{id}": {
+			OperationID: "deleteOwner",
+			Summary:     "Delete an owner that has no pets",
+			Tags:        []string{"owners"},
+			Parameters:  []jsonObject{ref("parameters", "OwnerID")},
+			Responses: errorResponses(map[string]jsonObject{
+				"200": response("The owner was deleted", nil),
+				"204": response("No owner existed", nil),
+			}, ErrInvalidInput, ErrReference),
+		},
+		"GET /api/owner/{id}/pets": {
+			OperationID: "listOwnerPets",
+			Summary:     "List the pets of an owner",
+			Tags:        []string{"owners"},
+			Parameters:  append([]jsonObject{ref("parameters", "OwnerID")}, listParameters()...),
+			Responses: errorResponses(map[string]jsonObject{
+				"200": withHeaders(petResponse(codecs, "A page of the pets of the owner", schemaRef("PetPage")), "Link"),
+			}, ErrInvalidInput, ErrNotFound, ErrNotAcceptable),
+		},
+		"GET /api/openapi.json": {
+			OperationID: "getOpenAPI",
+			Summary:     "This OpenAPI document",
+			Tags:        []string{"meta"},
+			Responses: map[string]jsonObject{
+				"200": response("The OpenAPI document of the API", jsonObject{"type": "object"}),
+			},
+		},
+		"GET /healthz": {
+			OperationID: "live",
+			Summary:     "Report whether the server is able to handle requests at all",
+			Tags:        []string{"health"},
+			Responses: map[string]jsonObject{
+				"200": response("The server is live", schemaRef("HealthReport")),
+			},
+		},
+		"GET /readyz": {
+			OperationID: "ready",
+			Summary:     "Report whether every component of the server is healthy",
+			Tags:        []string{"health"},
+			Responses: map[string]jsonObject{
+				"200": response("Every component is healthy", schemaRef("HealthReport")),
+				"503": response("A component is unhealthy", schemaRef("HealthReport")),
+			},
+		},
+	}
+}
+
+// listParameters are the query parameters of pet listings
+func listParameters() []jsonObject {
+	query := func(name, description string, schema jsonObject) jsonObject {
+		return jsonObject{"name": name, "in": "query", "description": description, "schema": schema}
+	}
+	return []jsonObject{
+		query("owner", "Only list pets with this owner name", jsonObject{"type": "string"}),
+		query("owner_id", "Only list pets of the owner with this ID", idSchema("")),
+		query("species", "Only list pets of this species", jsonObject{"type": "string"}),
+		query("name_prefix", "Only list pets whose name starts with this", jsonObject{"type": "string"}),
+		query("sort", "Order of the pets, prefixed with - for descending order", jsonObject{
+			"type": "string", "default": "id", "enum": sortValues(),
+		}),
+		query("limit", "Maximum number of pets in the page", jsonObject{
+			"type": "integer", "minimum": 1, "maximum": MaxListLimit, "default": DefaultListLimit,
+		}),
+		query("cursor", "Continues a listing from the next_cursor of a previous page", jsonObject{"type": "string"}),
+	}
+}
+
+// sortValues returns the values of the sort query parameter, ascending and descending
+func sortValues() []string {
+	var values []string
+	for field := range sortFields {
+		values = append(values, field, "-"+field)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// openAPIComponents returns the schemas, parameters, headers and responses that
+// operations reference
+func openAPIComponents() jsonObject {
+	responses := jsonObject{}
+	for code, status := range errStatusMap {
+		if code == ErrCanceled {
+			continue
+		}
+		responses[ErrorCodeName(code)] = jsonObject{
+			"description": http.StatusText(status),
+			"content": jsonObject{
+				problemType:        jsonObject{"schema": schemaRef("Problem")},
+				"application/json": jsonObject{"schema": schemaRef("Problem")},
+				"text/plain":       jsonObject{"schema": jsonObject{"type": "string"}},
+			},
+		}
+	}
+	return jsonObject{
+		"schemas": openAPISchemas(),
+		"parameters": jsonObject{
+			"PetID":   jsonObject{"name": "id", "in": "path", "required": true, "schema": idSchema("")},
+			"OwnerID": jsonObject{"name": "id", "in": "path", "required": true, "schema": idSchema("")},
+			"IfMatch": jsonObject{
+				"name": "If-Match", "in": "header", "schema": jsonObject{"type": "string"},
+				"description": "Only write if the stored pet has one of these entity tags, or exists for *",
+			},
+			"IfNoneMatch": jsonObject{
+				"name": "If-None-Match", "in": "header", "schema": jsonObject{"type": "string"},
+				"description": "Only write if the stored pet has none of these entity tags, or does not exist for *",
+			},
+		},
+		"headers": jsonObject{
+			"ETag": jsonObject{
+				"description": "Strong entity tag of the version of the pet",
+				"schema":      jsonObject{"type": "string"},
+			},
+			"Location": jsonObject{
+				"description": "URL of the created entry",
+				"schema":      jsonObject{"type": "string"},
+			},
+			"Link": jsonObject{
+				"description": "URL of the next page with rel=\"next\", for media types without a next_cursor field",
+				"schema":      jsonObject{"type": "string"},
+			},
+		},
+		"responses": responses,
+	}
+}
+
+// openAPISchemas returns the schemas of the request and response bodies
+func openAPISchemas() jsonObject {
+	codeNames := make([]string, 0, len(errorCodeNames))
+	for _, name := range errorCodeNames {
+		codeNames = append(codeNames, name)
+	}
+	sort.Strings(codeNames)
+	return jsonObject{
+		"Pet": object([]string{"name", "species"}, jsonObject{
+			"id":       idSchema("Allocated by the server when 0 or missing"),
+			"name":     jsonObject{"type": "string", "minLength": 1, "maxLength": MaxNameLength},
+			"species":  jsonObject{"type": "string", "minLength": 1, "maxLength": MaxSpeciesLength},
+			"owner":    jsonObject{"type": "string", "maxLength": MaxOwnerLength, "description": "Free text owner name, superseded by owner_id"},
+			"owner_id": idSchema("ID of the owner of the pet, 0 or missing if it has none"),
+			"extra": jsonObject{
+				"type": "object", "nullable": true, "additionalProperties": true,
+				"description": "Arbitrary JSON data about the pet, nested at most " + strconv.Itoa(DefaultMaxExtraDepth) +
+					" levels deep by default. The server may constrain it further with a JSON Schema.",
+			},
+			"version": jsonObject{"type": "integer", "minimum": 0, "readOnly": true, "description": "Assigned by the server on every write"},
+		}),
+		"Owner": object([]string{"name"}, jsonObject{
+			"id":   idSchema("Allocated by the server when 0 or missing"),
+			"name": jsonObject{"type": "string"},
+		}),
+		"PetPage": object([]string{"pets"}, jsonObject{
+			"pets":        jsonObject{"type": "array", "items": schemaRef("Pet")},
+			"next_cursor": jsonObject{"type": "string", "description": "Cursor of the next page, missing on the last page"},
+		}),
+		"JSONPatch": jsonObject{
+			"type":        "array",
+			"description": "JSON Patch (RFC 6902) of the pet",
+			"items": object([]string{"op", "path"}, jsonObject{
+				"op":    jsonObject{"type": "string", "enum": []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  jsonObject{"type": "string"},
+				"from":  jsonObject{"type": "string"},
+				"value": jsonObject{},
+			}),
+		},
+		"BatchOperation": object([]string{"op"}, jsonObject{
+			"op":  jsonObject{"type": "string", "enum": []string{BatchCreate, BatchUpsert, BatchDelete}},
+			"id":  idSchema("ID of the pet to upsert or delete"),
+			"pet": schemaRef("Pet"),
+		}),
+		"BatchResponse": object([]string{"results"}, jsonObject{
+			"results": jsonObject{"type": "array", "items": schemaRef("BatchItemResult")},
+		}),
+		"BatchItemResult": object([]string{"status"}, jsonObject{
+			"status": jsonObject{"type": "integer"},
+			"code":   jsonObject{"type": "string", "enum": codeNames},
+			"detail": jsonObject{"type": "string"},
+			"errors": jsonObject{"type": "array", "items": schemaRef("FieldError")},
+			"pet":    schemaRef("Pet"),
+		}),
+		"Event": object([]string{"id", "type", "pet_id", "time"}, jsonObject{
+			"id":     jsonObject{"type": "integer", "minimum": 1},
+			"type":   jsonObject{"type": "string", "enum": []string{EventCreated, EventUpdated, EventDeleted}},
+			"pet_id": idSchema(""),
+			"time":   jsonObject{"type": "string", "format": "date-time"},
+			"pet":    schemaRef("Pet"),
+		}),
+		"Problem": object([]string{"type", "title", "status", "code"}, jsonObject{
+			"type":     jsonObject{"type": "string", "description": "URN ending in the code"},
+			"title":    jsonObject{"type": "string"},
+			"status":   jsonObject{"type": "integer"},
+			"detail":   jsonObject{"type": "string"},
+			"instance": jsonObject{"type": "string"},
+			"code":     jsonObject{"type": "string", "enum": codeNames},
+			"errors":   jsonObject{"type": "array", "items": schemaRef("FieldError")},
+		}),
+		"FieldError": object([]string{"field", "message"}, jsonObject{
+			"field":   jsonObject{"type": "string"},
+			"message": jsonObject{"type": "string"},
+		}),
+		"HealthReport": object([]string{"status"}, jsonObject{
+			"status": jsonObject{"type": "string", "enum": []string{HealthUp, HealthDown}},
+			"components": jsonObject{"type": "array", "items": object([]string{"name", "status", "latency_ms"}, jsonObject{
+				"name":       jsonObject{"type": "string"},
+				"status":     jsonObject{"type": "string", "enum": []string{HealthUp, HealthDown}},
+				"latency_ms": jsonObject{"type": "number"},
+				"error":      jsonObject{"type": "string"},
+			})},
+		}),
+	}
+}
+
+// object returns the schema of a JSON object with the given properties
+func object(required []string, properties jsonObject) jsonObject {
+	return jsonObject{"type": "object", "required": required, "properties": properties}
+}
+
+// idSchema returns the schema of a pet or owner ID
+func idSchema(description string) jsonObject {
+	schema := jsonObject{"type": "integer", "format": "int64", "minimum": 0, "maximum": math.MaxUint32}
+	if description != "" {
+		schema["description"] = description
+	}
+	return schema
+}
+
+// ref returns a reference to a component of the given kind
+func ref(kind, name string) jsonObject {
+	return jsonObject{"$ref": "#/components/" + kind + "/" + name}
+}
+
+func schemaRef(name string) jsonObject {
+	return ref("schemas", name)
+}
+
+// requestBody returns a required JSON request body
+func requestBody(schema jsonObject) jsonObject {
+	return jsonObject{"required": true, "content": jsonObject{"application/json": jsonObject{"schema": schema}}}
+}
+
+// response returns a response with a JSON body, or without a body if schema is nil
+func response(description string, schema jsonObject) jsonObject {
+	resp := jsonObject{"description": description}
+	if schema != nil {
+		resp["content"] = jsonObject{"application/json": jsonObject{"schema": schema}}
+	}
+	return resp
+}
+
+// petRequestBody returns a request body holding a pet in the media type of any codec
+func petRequestBody(codecs *Codecs) jsonObject {
+	return jsonObject{"required": true, "content": petContent(codecs, schemaRef("Pet"))}
+}
+
+// petResponse returns a response holding pets in the media type of any codec,
+// negotiated with the Accept header
+func petResponse(codecs *Codecs, description string, schema jsonObject) jsonObject {
+	return jsonObject{"description": description, "content": petContent(codecs, schema)}
+}
+
+// petContent returns the content of pets in the media types of codecs, nil for the
+// default codecs, described by the schema of their JSON form
+func petContent(codecs *Codecs, schema jsonObject) jsonObject {
+	content := jsonObject{}
+	for _, mediaType := range codecs.orDefault().mediaTypes {
+		content[mediaType] = jsonObject{"schema": schema}
+	}
+	return content
+}
+
+// withHeaders adds references to response headers to a response
+func withHeaders(resp jsonObject, names ...string) jsonObject {
+	headers := jsonObject{}
+	for _, name := range names {
+		headers[name] = ref("headers", name)
+	}
+	resp["headers"] = headers
+	return resp
+}
+
+// errorResponses adds the problem responses of the given error codes to responses,
+// along with those every operation may fail with
+func errorResponses(responses map[string]jsonObject, codes ...int) map[string]jsonObject {
+	for _, code := range append(codes, ErrTimeout) {
+		responses[strconv.Itoa(errStatusMap[code])] = ref("responses", ErrorCodeName(code))
+	}
+	responses["default"] = ref("responses", ErrorCodeName(ErrUnknown))
+	return responses
+}
+
+// routeKey returns the key of a chi route in the map of apiOperations. Routes of mounted
+// routers are reported by chi with /*/ separators and a trailing slash.
+func routeKey(method, route string) string {
+	route = strings.Replace(route, "/*/", "/", -1)
+	if len(route) > 1 {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return method + " " + route
+}
+
+// NewOpenAPI describes the routes registered with routes that this package sets up,
+// with pets in the media types of codecs, nil for DefaultCodecs. Routes to leave out of
+// the document are listed in skip by method and path, such as "GET /metrics". Any other
+// route without a description is an error.
+func NewOpenAPI(routes chi.Routes, codecs *Codecs, skip ...string) (*OpenAPI, error) {
+	spec := &OpenAPI{
+		OpenAPI:    openAPIVersion,
+		Paths:      map[string]map[string]*Operation{},
+		Components: openAPIComponents(),
+	}
+	spec.Info.Title = "Sample Rest pet API"
+	spec.Info.Version = "1.0.0"
+	skipped := make(map[string]bool, len(skip))
+	for _, key := range skip {
+		skipped[key] = true
+	}
+	operations := apiOperations(codecs)
+	var undescribed []string
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		key := routeKey(method, route)
+		operation, ok := operations[key]
+		if !ok {
+			if !skipped[key] {
+				undescribed = append(undescribed, key)
+			}
+			return nil
+		}
+		path := key[len(method)+1:]
+		if spec.Paths[path] == nil {
+			spec.Paths[path] = map[string]*Operation{}
+		}
+		spec.Paths[path][strings.ToLower(method)] = operation
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(undescribed) > 0 {
+		sort.Strings(undescribed)
+		return nil, Errorf(ErrUnknown, "Routes %s have no OpenAPI description", strings.Join(undescribed, ", "))
+	}
+	return spec, nil
+}
+
+// OpenAPIHandler serves the OpenAPI document of the routes of a router. The document
+// is built on the first request, once every route has been registered.
+type OpenAPIHandler struct {
+	routes chi.Routes
+	codecs *Codecs
+	skip   []string
+	once   sync.Once
+	spec   *OpenAPI
+	err    error
+}
+
+// NewOpenAPIHandler creates a handler serving the OpenAPI document of routes, described
+// as by NewOpenAPI
+func NewOpenAPIHandler(routes chi.Routes, codecs *Codecs, skip ...string) *OpenAPIHandler {
+	return &OpenAPIHandler{routes: routes, codecs: codecs, skip: skip}
+}
+
+// SetupOpenAPIRoutes adds the OpenAPI document endpoint to a router
+func SetupOpenAPIRoutes(r chi.Router, h *OpenAPIHandler) {
+	r.Get("/api/openapi.json", h.ServeHTTP)
+}
+
+// ServeHTTP responds with the OpenAPI document
+func (h *OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.once.Do(func() {
+		h.spec, h.err = NewOpenAPI(h.routes, h.codecs, h.skip...)
+	})
+	if h.err != nil {
+		renderErrorResponse(w, r, h.err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, h.spec)
+}
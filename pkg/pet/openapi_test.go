@@ -0,0 +1,281 @@
+package pet
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAPIRouter sets up every route of the package. The routes petserver sets up are
+// checked in its own tests.
+func newAPIRouter() chi.Router {
+	router := chi.NewRouter()
+	store := NewMemStore()
+	SetupEventRoutes(router, NewEventHandler(NewEventHub(0)))
+	SetupRoutes(router, NewPetService(store))
+	SetupOwnerRoutes(router, NewOwnerService(store, store))
+	SetupHealthRoutes(router, NewHealth(store))
+	SetupOpenAPIRoutes(router, NewOpenAPIHandler(router, nil))
+	return router
+}
+
+func TestOpenAPI_DescribesEveryRoute(t *testing.T) {
+	// given
+	router := newAPIRouter()
+	var routes []string
+	require.NoError(t, chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes = append(routes, routeKey(method, route))
+		return nil
+	}))
+	var described []string
+	for key := range apiOperations(nil) {
+		described = append(described, key)
+	}
+	sort.Strings(routes)
+	sort.Strings(described)
+
+	// when
+	spec, err := NewOpenAPI(router, nil)
+
+	// then
+	require.NoError(t, err)
+	tassert.Equal(t, described, routes, "Every registered route should be described, and every description registered")
+	operations := 0
+	for _, pathOperations := range spec.Paths {
+		operations += len(pathOperations)
+	}
+	tassert.Equal(t, len(routes), operations, "Every route should be in the document")
+}
+
+func TestOpenAPI_UndescribedRoutes(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router := newAPIRouter()
+	router.Get("/metrics", func(http.ResponseWriter, *http.Request) {})
+	router.Get("/debug", func(http.ResponseWriter, *http.Request) {})
+
+	// when
+	_, undescribedErr := NewOpenAPI(router, nil)
+	_, skippedErr := NewOpenAPI(router, nil, "GET /debug", "GET /metrics")
+
+	// then
+	if assert.Error(undescribedErr) {
+		assert.Contains(undescribedErr.Error(), "GET /debug, GET /metrics")
+	}
+	assert.NoError(skippedErr, "Skipped routes should be left out of the document")
+}
+
+func TestOpenAPI_ResolvesReferences(t *testing.T) {
+	// given
+	spec, err := NewOpenAPI(newAPIRouter(), nil)
+	require.NoError(t, err)
+	data, err := json.Marshal(spec)
+	require.NoError(t, err)
+	var document interface{}
+	require.NoError(t, json.Unmarshal(data, &document))
+
+	// when
+	var refs []string
+	collectRefs(document, &refs)
+
+	// then
+	tassert.NotEmpty(t, refs)
+	for _, ref := range refs {
+		var target interface{} = document
+		for _, name := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			object, _ := target.(map[string]interface{})
+			target = object[name]
+		}
+		tassert.NotNil(t, target, "Reference %s should resolve", ref)
+	}
+}
+
+// collectRefs appends every $ref of a decoded JSON document to refs
+func collectRefs(value interface{}, refs *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				*refs = append(*refs, ref)
+			}
+			collectRefs(child, refs)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectRefs(child, refs)
+		}
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	// given
+	server := httptest.NewServer(newAPIRouter())
+	defer server.Close()
+
+	// when
+	resp, err := http.Get(server.URL + "/api/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	// then
+	assert := tassert.New(t)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	var spec struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&spec))
+	assert.Equal(openAPIVersion, spec.OpenAPI)
+	assert.Contains(spec.Paths["/api/pet/{id}"], "patch")
+	assert.Contains(spec.Paths, "/api/openapi.json", "Document should describe its own route")
+}
+
+// documentedExchange is a request to a route of the API, with the status the handler
+// is expected to respond with
+type documentedExchange struct {
+	method, path, body string
+	header             map[string]string
+	status             int
+}
+
+// documentedExchanges exercises every operation of the API, in order on a single router
+func documentedExchanges() []documentedExchange {
+	yaml := map[string]string{"Accept": "application/yaml"}
+	png := map[string]string{"Accept": "image/png"}
+	nemo := `{"name": "Nemo", "species": "Clownfish", "owner_id": 1}`
+	return []documentedExchange{
+		{method: "POST", path: "/api/owner", body: `{"name": "Andy"}`, status: http.StatusCreated},
+		{method: "POST", path: "/api/owner", body: `{"id": 1, "name": "Andy"}`, status: http.StatusConflict},
+		{method: "GET", path: "/api/owner/1", status: http.StatusOK},
+		{method: "GET", path: "/api/owner/2", status: http.StatusNotFound},
+		{method: "PUT", path: "/api/owner/2", body: `{"name": "Sid"}`, status: http.StatusCreated},
+		{method: "POST", path: "/api/pet", body: nemo, status: http.StatusCreated},
+		{method: "POST", path: "/api/pet", body: nemo, header: yaml, status: http.StatusCreated},
+		{method: "POST", path: "/api/pet", body: `{"id": 1, "name": "Nemo", "species": "Clownfish"}`, status: http.StatusConflict},
+		{method: "POST", path: "/api/pet", body: `{"name": "Nemo"}`, status: http.StatusBadRequest},
+		{method: "POST", path: "/api/pet", body: `{"name": "Nemo", "species": "Clownfish", "owner_id": 9}`, status: http.StatusUnprocessableEntity},
+		{method: "POST", path: "/api/pet", body: "%PDF", header: map[string]string{"Content-Type": "application/pdf"}, status: http.StatusUnsupportedMediaType},
+		{method: "POST", path: "/api/pet", body: nemo, header: png, status: http.StatusNotAcceptable},
+		{method: "GET", path: "/api/pet?limit=1", header: map[string]string{"Accept": "text/csv"}, status: http.StatusOK},
+		{method: "GET", path: "/api/pet?limit=many", status: http.StatusBadRequest},
+		{method: "GET", path: "/api/pet", header: png, status: http.StatusNotAcceptable},
+		{method: "GET", path: "/api/pet/1", header: map[string]string{"Accept": "application/xml"}, status: http.StatusOK},
+		{method: "GET", path: "/api/pet/9", status: http.StatusNotFound},
+		{method: "GET", path: "/api/pet/nine", status: http.StatusBadRequest},
+		{method: "GET", path: "/api/pet/1", header: png, status: http.StatusNotAcceptable},
+		{method: "PUT", path: "/api/pet/1", body: nemo, header: yaml, status: http.StatusCreated},
+		{method: "PUT", path: "/api/pet/1", body: nemo, header: map[string]string{"If-Match": `"99"`}, status: http.StatusPreconditionFailed},
+		{method: "PUT", path: "/api/pet/1", body: `{"id": 2, "name": "Nemo", "species": "Clownfish"}`, status: http.StatusBadRequest},
+		{method: "PUT", path: "/api/pet/1", body: `{"name": "Nemo", "species": "Clownfish", "owner_id": 9}`, status: http.StatusUnprocessableEntity},
+		{method: "PUT", path: "/api/pet/1", body: nemo, header: png, status: http.StatusNotAcceptable},
+		{method: "PATCH", path: "/api/pet/1", body: `{"name": "Marlin"}`, header: map[string]string{"Content-Type": mergePatchType}, status: http.StatusOK},
+		{method: "PATCH", path: "/api/pet/1", body: `[{"op": "test", "path": "/name", "value": "Dory"}]`,
+			header: map[string]string{"Content-Type": jsonPatchType}, status: http.StatusConflict},
+		{method: "PATCH", path: "/api/pet/9", body: `{"name": "Marlin"}`, header: map[string]string{"Content-Type": mergePatchType}, status: http.StatusNotFound},
+		{method: "DELETE", path: "/api/pet/1", header: map[string]string{"If-Match": `"99"`}, status: http.StatusPreconditionFailed},
+		{method: "DELETE", path: "/api/pet/2", status: http.StatusOK},
+		{method: "DELETE", path: "/api/pet/2", status: http.StatusNoContent},
+		{method: "POST", path: "/api/pet:batch", body: `[{"op": "create", "pet": {"name": "Dory", "species": "Blue tang"}}]`, status: http.StatusOK},
+		{method: "POST", path: "/api/pet:batch?mode=eventually", body: `[]`, status: http.StatusBadRequest},
+		{method: "GET", path: "/api/owner/1/pets", status: http.StatusOK},
+		{method: "GET", path: "/api/owner/9/pets", status: http.StatusNotFound},
+		{method: "DELETE", path: "/api/owner/1", status: http.StatusUnprocessableEntity},
+		{method: "DELETE", path: "/api/owner/2", status: http.StatusOK},
+		{method: "DELETE", path: "/api/owner/2", status: http.StatusNoContent},
+		{method: "GET", path: "/api/pet/events", status: http.StatusOK},
+		{method: "GET", path: "/api/pet/events", header: map[string]string{"Last-Event-ID": "yesterday"}, status: http.StatusBadRequest},
+		{method: "GET", path: "/api/openapi.json", status: http.StatusOK},
+		{method: "GET", path: "/healthz", status: http.StatusOK},
+		{method: "GET", path: "/readyz", status: http.StatusOK},
+	}
+}
+
+func TestOpenAPI_DocumentsHandlerResponses(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router := newAPIRouter()
+	spec, err := NewOpenAPI(router, nil)
+	require.NoError(t, err)
+	exercised := map[string]map[string]bool{}
+	// event streams end once their request is done
+	done, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, exchange := range documentedExchanges() {
+		req := httptest.NewRequest(exchange.method, exchange.path, strings.NewReader(exchange.body))
+		if exchange.path == "/api/pet/events" {
+			req = req.WithContext(done)
+		}
+		for name, value := range exchange.header {
+			req.Header.Set(name, value)
+		}
+		resp := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(resp, req)
+
+		// then
+		name := exchange.method + " " + exchange.path
+		require.Equal(t, exchange.status, resp.Code, "%s: %s", name, resp.Body.String())
+		path := strings.SplitN(routePattern(router, req), " ", 2)[1]
+		operation := spec.Paths[path][strings.ToLower(exchange.method)]
+		require.NotNil(t, operation, name)
+		status := strconv.Itoa(resp.Code)
+		documented, ok := operation.Responses[status]
+		if !assert.True(ok, "%s: status %s should be documented", name, status) {
+			continue
+		}
+		if exercised[operation.OperationID] == nil {
+			exercised[operation.OperationID] = map[string]bool{}
+		}
+		exercised[operation.OperationID][status] = true
+		documented = resolveResponse(spec, documented)
+		content, hasContent := documented["content"].(jsonObject)
+		if !hasContent {
+			continue
+		}
+		mediaType, _, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+		if assert.NoError(err, "%s: response should have a Content-Type", name) {
+			assert.Contains(content, mediaType, "%s: %s responses should be documented as %s", name, status, mediaType)
+		}
+	}
+	for _, pathOperations := range spec.Paths {
+		for _, operation := range pathOperations {
+			for status := range operation.Responses {
+				if strings.HasPrefix(status, "2") {
+					assert.True(exercised[operation.OperationID][status], "%s %s should be exercised", operation.OperationID, status)
+				}
+			}
+		}
+	}
+}
+
+// routePattern returns the method and route pattern a request is routed to
+func routePattern(router chi.Routes, req *http.Request) string {
+	rctx := chi.NewRouteContext()
+	if !router.Match(rctx, req.Method, req.URL.Path) {
+		return ""
+	}
+	return routeKey(req.Method, rctx.RoutePattern())
+}
+
+// resolveResponse follows the reference of a response to the response components
+func resolveResponse(spec *OpenAPI, response jsonObject) jsonObject {
+	ref, ok := response["$ref"].(string)
+	if !ok {
+		return response
+	}
+	responses := spec.Components["responses"].(jsonObject)
+	return responses[strings.TrimPrefix(ref, "#/components/responses/")].(jsonObject)
+}
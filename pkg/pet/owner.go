@@ -0,0 +1,236 @@
+package pet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/render"
+)
+
+// Owner defines the data structure corresponding to a pet owner
+type Owner struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+// OwnerStorer defines standard CRUD operations for Owners.
+// Stores implementing both Storer and OwnerStorer keep pets and owners consistent:
+// writing a pet whose OwnerID references a missing owner, and deleting an owner
+// that pets still reference, fail with ErrReference.
+// CreateOwner allocates an ID for an owner with ID 0 and sets it on the owner passed in.
+type OwnerStorer interface {
+	CreateOwner(ctx context.Context, owner *Owner) error
+	ReadOwner(ctx context.Context, ID uint32) (*Owner, error)
+	UpdateOwner(ctx context.Context, ID uint32, owner *Owner) error
+	DeleteOwner(ctx context.Context, ID uint32) (bool, error)
+}
+
+// MigrateOwners moves the free text Owner names of pets to stored owners. An owner is
+// created for every distinct name, and each pet with a name but no OwnerID is updated
+// to reference it, clearing the name. Pets modified while they are migrated are left
+// for a later run. It returns the number of pets migrated.
+func MigrateOwners(ctx context.Context, pets Storer, owners OwnerStorer) (int, error) {
+	ownerIDs := map[string]uint32{}
+	migrated := 0
+	query := ListQuery{Limit: MaxListLimit}
+	for {
+		page, err := pets.ListPets(ctx, query)
+		if err != nil {
+			return migrated, err
+		}
+		for _, pet := range page.Pets {
+			if pet.Owner == "" || pet.OwnerID != 0 {
+				continue
+			}
+			ownerID, ok := ownerIDs[pet.Owner]
+			if !ok {
+				owner := &Owner{Name: pet.Owner}
+				if err = owners.CreateOwner(ctx, owner); err != nil {
+					return migrated, err
+				}
+				ownerID = owner.ID
+				ownerIDs[pet.Owner] = ownerID
+			}
+			pet.OwnerID, pet.Owner = ownerID, ""
+			err = pets.UpdatePet(ctx, pet.ID, &pet, Precondition{IfMatch: []uint64{pet.Version}})
+			switch {
+			case err == nil:
+				migrated++
+			case !errors.Is(err, ErrConflictSentinel):
+				return migrated, err
+			}
+		}
+		if page.NextCursor == "" {
+			return migrated, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// SetupOwnerRoutes sets up owner service routes for the given router
+func SetupOwnerRoutes(r chi.Router, s *OwnerService) {
+	r.Route("/api/owner", func(r chi.Router) {
+		r.Post("/", s.PostOwner)
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(urlParamContextSaverMiddleware("id", idKey))
+			r.Get("/", s.GetOwner)
+			r.Put("/", s.PutOwner)
+			r.Delete("/", s.DeleteOwner)
+			r.Get("/pets", s.ListOwnerPets)
+		})
+	})
+}
+
+// OwnerService defines a rest api for interaction with an OwnerStorer
+type OwnerService struct {
+	owners OwnerStorer
+	pets   Storer
+	// Body controls how request bodies are read
+	Body BodyConfig
+	// Codecs writes the pets of owners in the media types of requests, nil uses DefaultCodecs
+	Codecs *Codecs
+}
+
+// NewOwnerService creates a new owner service, listing the pets of owners from pets
+func NewOwnerService(owners OwnerStorer, pets Storer) *OwnerService {
+	return &OwnerService{
+		owners: owners,
+		pets:   pets,
+	}
+}
+
+// GetOwner handles a GET request to retrieve an owner
+func (s *OwnerService) GetOwner(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := readOwnerID(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	owner, err := s.owners.ReadOwner(r.Context(), ownerID)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	render.JSON(w, r, owner)
+}
+
+// PostOwner handles a POST request to add a new owner.
+// The store allocates an ID if the owner has none, and the created owner is returned.
+func (s *OwnerService) PostOwner(w http.ResponseWriter, r *http.Request) {
+	owner, err := s.readOwnerBody(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if err = s.owners.CreateOwner(r.Context(), owner); err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("Location", path.Join(r.URL.Path, strconv.FormatUint(uint64(owner.ID), 10)))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, owner)
+}
+
+// PutOwner handles a PUT request to create or rename an owner.
+// An owner ID in the body must match the ID in the URL.
+func (s *OwnerService) PutOwner(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := readOwnerID(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	owner, err := s.readOwnerBody(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if err = matchBodyID(ownerID, &owner.ID, "owner"); err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if err = s.owners.UpdateOwner(r.Context(), ownerID, owner); err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, nil)
+}
+
+// DeleteOwner handles a DELETE request to delete an owner without pets
+func (s *OwnerService) DeleteOwner(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := readOwnerID(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	ownerDeleted, err := s.owners.DeleteOwner(r.Context(), ownerID)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if ownerDeleted {
+		render.Status(r, http.StatusOK)
+	} else {
+		render.Status(r, http.StatusNoContent)
+	}
+	render.JSON(w, r, nil)
+}
+
+// ListOwnerPets handles a GET request to list the pets of an owner, accepting the
+// same query parameters as ListPets
+func (s *OwnerService) ListOwnerPets(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := readOwnerID(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	query, err := readListQuery(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if _, err = s.owners.ReadOwner(r.Context(), ownerID); err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	query.OwnerID = ownerID
+	page, err := s.pets.ListPets(r.Context(), query)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	codec, err := s.Codecs.orDefault().forAccept(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderPage(w, r, codec, page)
+}
+
+func readOwnerID(r *http.Request) (uint32, error) {
+	ownerID := r.Context().Value(idKey)
+	if ownerID == nil {
+		// Reaching this indicates a bug. At this point, request context should contain an id
+		return 0, Errorf(ErrUnknown, "owner ID was lost somewhere")
+	}
+	intID, err := strconv.ParseUint(ownerID.(string), 10, 32)
+	if err != nil {
+		return 0, Errorf(ErrInvalidInput, "Invalid owner ID %v. ID should be a number", ownerID).
+			AddField("id", "should be a number")
+	}
+	return uint32(intID), nil
+}
+
+func (s *OwnerService) readOwnerBody(r *http.Request) (*Owner, error) {
+	var owner Owner
+	if err := s.Body.decodeJSON(r, &owner, "owner"); err != nil {
+		return nil, err
+	}
+	return &owner, nil
+}
@@ -0,0 +1,143 @@
+package pet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ownerRouter creates a router serving the pet and owner routes of store
+func ownerRouter(store *MemStore) chi.Router {
+	router := chi.NewRouter()
+	SetupRoutes(router, NewPetService(store))
+	SetupOwnerRoutes(router, NewOwnerService(store, store))
+	return router
+}
+
+func TestOwnerRoutes(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := NewMemStore()
+	router := ownerRouter(store)
+	req, _ := http.NewRequest("POST", "/api/owner", bytes.NewBufferString(`{"name": "Marlin"}`))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusCreated, resp.Code, "Response status should be 201 Created")
+	var created Owner
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &created), "Body should be able to unmarshal to an owner")
+	assert.Equal("/api/owner/1", resp.Header().Get("Location"), "Location should point to the allocated ID")
+
+	// when
+	req, _ = http.NewRequest("PUT", "/api/owner/1", bytes.NewBufferString(`{"name": "Marlin Clownfish"}`))
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	getReq, _ := http.NewRequest("GET", "/api/owner/1", nil)
+	getResp := httptest.NewRecorder()
+	router.ServeHTTP(getResp, getReq)
+
+	// then
+	assert.Equal(http.StatusCreated, resp.Code, "Response status should be 201 Created")
+	assert.Equal(http.StatusOK, getResp.Code, "Response status should be 200 OK")
+	assert.JSONEq(`{"id": 1, "name": "Marlin Clownfish"}`, getResp.Body.String(), "Owner should be renamed")
+
+	// when
+	req, _ = http.NewRequest("DELETE", "/api/owner/1", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	getResp = httptest.NewRecorder()
+	router.ServeHTTP(getResp, getReq)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	assert.Equal(http.StatusNotFound, getResp.Code, "Deleted owner should not be found")
+}
+
+func TestOwnerRoutes_References(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := NewMemStore()
+	router := ownerRouter(store)
+	marlin := &Owner{Name: "Marlin"}
+	require.NoError(t, store.CreateOwner(context.Background(), marlin), "Error in test code, could not add initial owner")
+	nemo, orphan := pet1000(), pet1001()
+	nemo.OwnerID, orphan.OwnerID = marlin.ID, marlin.ID+1
+	require.NoError(t, store.CreatePet(context.Background(), &nemo), "Error in test code, could not add initial pet")
+	body, _ := json.Marshal(orphan)
+	req, _ := http.NewRequest("POST", "/api/pet", bytes.NewBuffer(body))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusUnprocessableEntity, resp.Code, "Pet of a missing owner should be rejected with 422")
+	assert.Contains(resp.Body.String(), `"code":"reference_violation"`)
+
+	// when
+	req, _ = http.NewRequest("DELETE", "/api/owner/1", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusUnprocessableEntity, resp.Code, "Owner with pets should not be deleted")
+
+	// when
+	req, _ = http.NewRequest("GET", "/api/owner/1/pets", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	missingReq, _ := http.NewRequest("GET", "/api/owner/2/pets", nil)
+	missingResp := httptest.NewRecorder()
+	router.ServeHTTP(missingResp, missingReq)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	var page PetPage
+	if assert.NoError(json.Unmarshal(resp.Body.Bytes(), &page), "Body should be able to unmarshal to a pet page") {
+		assert.Equal([]Pet{nemo}, page.Pets, "Owner's pets should be listed")
+	}
+	assert.Equal(http.StatusNotFound, missingResp.Code, "Pets of a missing owner should not be found")
+}
+
+func TestMigrateOwners(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := NewMemStore()
+	pets := []Pet{pet1000(), pet1001(), modifiedPet1000(), {Name: "Stray"}}
+	pets[2].ID = 1002
+	for i := range pets {
+		require.NoError(t, store.CreatePet(context.Background(), &pets[i]), "Error in test code, could not add initial pets")
+	}
+
+	// when
+	migrated, err := MigrateOwners(context.Background(), store, store)
+	again, againErr := MigrateOwners(context.Background(), store, store)
+
+	// then
+	if assert.NoError(err, "Migration should succeed") {
+		assert.Equal(3, migrated, "Every pet with an owner name should be migrated")
+	}
+	if assert.NoError(againErr, "Repeated migration should succeed") {
+		assert.Zero(again, "Migrated pets should not be migrated again")
+	}
+	nemo, _ := store.ReadPet(context.Background(), 1000)
+	dory, _ := store.ReadPet(context.Background(), 1002)
+	scruff, _ := store.ReadPet(context.Background(), 1001)
+	assert.Empty(nemo.Owner, "Owner name should be cleared")
+	assert.Equal(nemo.OwnerID, dory.OwnerID, "Pets with the same owner name should share an owner")
+	assert.NotEqual(nemo.OwnerID, scruff.OwnerID, "Pets with different owner names should have different owners")
+	if owner, err := store.ReadOwner(context.Background(), nemo.OwnerID); assert.NoError(err) {
+		assert.Equal("Marlin", owner.Name, "Owner should be named after the pet's owner name")
+	}
+}
@@ -0,0 +1,313 @@
+package pet
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types accepted by PatchPet
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// petPatch modifies the generic JSON document of a pet, returning the modified document
+type petPatch func(doc interface{}) (interface{}, error)
+
+// newMergePatch parses a JSON Merge Patch as defined in RFC 7396
+func newMergePatch(data []byte) (petPatch, error) {
+	var patch interface{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Invalid merge patch")
+	}
+	return func(doc interface{}) (interface{}, error) {
+		return mergePatch(doc, patch), nil
+	}, nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+	return targetObject
+}
+
+// jsonPatchOperation is a single operation of a JSON Patch.
+// Value is kept raw so that a null value can be told apart from a missing one.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// newJSONPatch parses a JSON Patch as defined in RFC 6902
+func newJSONPatch(data []byte) (petPatch, error) {
+	var operations []jsonPatchOperation
+	if err := json.Unmarshal(data, &operations); err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Invalid JSON patch, it should be an array of operations")
+	}
+	for i, op := range operations {
+		if op.Path == nil {
+			return nil, Errorf(ErrInvalidInput, "Invalid JSON patch operation %d, path is missing", i)
+		}
+		switch op.Op {
+		case "add", "replace", "test":
+			if len(op.Value) == 0 {
+				return nil, Errorf(ErrInvalidInput, "Invalid JSON patch operation %d, %s requires a value", i, op.Op)
+			}
+		case "move", "copy":
+			if op.From == nil {
+				return nil, Errorf(ErrInvalidInput, "Invalid JSON patch operation %d, %s requires from", i, op.Op)
+			}
+		case "remove":
+		default:
+			return nil, Errorf(ErrInvalidInput, "Invalid JSON patch operation %d, unknown op %q", i, op.Op)
+		}
+	}
+	return func(doc interface{}) (interface{}, error) {
+		var err error
+		for i, op := range operations {
+			if doc, err = op.apply(doc); err != nil {
+				if patchErr, ok := err.(*Error); ok {
+					patchErr.Message = "JSON patch operation " + strconv.Itoa(i) + " failed. " + patchErr.Message
+				}
+				return nil, err
+			}
+		}
+		return doc, nil
+	}, nil
+}
+
+func (op *jsonPatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if len(op.Value) != 0 {
+		if err = json.Unmarshal(op.Value, &value); err != nil {
+			return nil, ErrorEf(ErrInvalidInput, err, "Invalid value")
+		}
+	}
+	switch op.Op {
+	case "add":
+		return addValue(doc, path, value)
+	case "remove":
+		return removeValue(doc, path)
+	case "replace":
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, err = removeValue(doc, path); err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+	case "test":
+		current, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, Errorf(ErrPatchTestFailed, "Value at %q does not match the tested value", *op.Path).
+				AddField(*op.Path, "does not match the tested value")
+		}
+		return doc, nil
+	}
+	// move and copy
+	from, err := parsePointer(*op.From)
+	if err != nil {
+		return nil, err
+	}
+	if value, err = getValue(doc, from); err != nil {
+		return nil, err
+	}
+	if op.Op == "move" {
+		if strings.HasPrefix(*op.Path+"/", *op.From+"/") && *op.Path != *op.From {
+			return nil, Errorf(ErrInvalidInput, "Cannot move %q into one of its children", *op.From)
+		}
+		if doc, err = removeValue(doc, from); err != nil {
+			return nil, err
+		}
+	} else {
+		value = copyValue(value)
+	}
+	return addValue(doc, path, value)
+}
+
+// parsePointer splits a JSON Pointer as defined in RFC 6901 into its reference tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, Errorf(ErrInvalidInput, "Invalid path %q, it should start with '/'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, Errorf(ErrInvalidInput, "No value at %q", token)
+			}
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = container[index]
+		default:
+			return nil, Errorf(ErrInvalidInput, "Cannot find %q in a value that is not an object or array", token)
+		}
+	}
+	return doc, nil
+}
+
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return modifyParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			container[token] = value
+			return container, nil
+		case []interface{}:
+			if token == "-" {
+				return append(container, value), nil
+			}
+			index, err := arrayIndex(token, len(container))
+			if err != nil {
+				return nil, err
+			}
+			container = append(container, nil)
+			copy(container[index+1:], container[index:])
+			container[index] = value
+			return container, nil
+		}
+		return nil, Errorf(ErrInvalidInput, "Cannot add %q to a value that is not an object or array", token)
+	})
+}
+
+func removeValue(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, Errorf(ErrInvalidInput, "Cannot remove the whole pet")
+	}
+	return modifyParent(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch container := parent.(type) {
+		case map[string]interface{}:
+			if _, ok := container[token]; !ok {
+				return nil, Errorf(ErrInvalidInput, "No value at %q", token)
+			}
+			delete(container, token)
+			return container, nil
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+			return append(container[:index], container[index+1:]...), nil
+		}
+		return nil, Errorf(ErrInvalidInput, "Cannot remove %q from a value that is not an object or array", token)
+	})
+}
+
+// modifyParent calls fn with the container holding the last token of path,
+// and replaces that container with the one fn returns
+func modifyParent(doc interface{}, path []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+	child, err := getValue(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = modifyParent(child, path[1:], fn); err != nil {
+		return nil, err
+	}
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		container[path[0]] = child
+	case []interface{}:
+		index, _ := arrayIndex(path[0], len(container)-1)
+		container[index] = child
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token, which may be at most max
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, Errorf(ErrInvalidInput, "Invalid array index %q", token)
+	}
+	return index, nil
+}
+
+// copyValue deep copies a generic JSON value
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			copied[key] = copyValue(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, child := range v {
+			copied[i] = copyValue(child)
+		}
+		return copied
+	}
+	return value
+}
+
+// applyPetPatch returns a patched copy of pet. The ID of the pet cannot be patched.
+func applyPetPatch(pet *Pet, patch petPatch) (*Pet, error) {
+	data, err := json.Marshal(pet)
+	if err != nil {
+		return nil, ErrorEf(ErrUnknown, err, "Could not encode pet with id %d", pet.ID)
+	}
+	var doc interface{}
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return nil, ErrorEf(ErrUnknown, err, "Could not decode pet with id %d", pet.ID)
+	}
+	if doc, err = patch(doc); err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(doc); err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Could not encode patched pet")
+	}
+	var patched Pet
+	if err = json.Unmarshal(data, &patched); err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Patched pet is not valid pet data")
+	}
+	if patched.ID != pet.ID {
+		return nil, Errorf(ErrInvalidInput, "Pet ID cannot be changed by a patch")
+	}
+	return &patched, nil
+}
@@ -0,0 +1,133 @@
+package pet
+
+import (
+	"encoding/json"
+	"testing"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func decodeJSON(data string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		panic("Error in test code, invalid JSON " + data)
+	}
+	return value
+}
+
+func TestMergePatch(t *testing.T) {
+	// examples from RFC 7396 appendix A
+	cases := []struct{ target, patch, result string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		// given
+		patch, err := newMergePatch([]byte(c.patch))
+		if !tassert.NoError(t, err, "Merge patch %s should parse", c.patch) {
+			continue
+		}
+
+		// when
+		result, err := patch(decodeJSON(c.target))
+
+		// then
+		if tassert.NoError(t, err, "Merge patch %s should apply to %s", c.patch, c.target) {
+			tassert.Equal(t, decodeJSON(c.result), result, "Merge patch %s of %s", c.patch, c.target)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	// mostly examples from RFC 6902 appendix A
+	cases := []struct{ doc, patch, result string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{`{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{`{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{`{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"copy","from":"/~1","path":"/a"}]`, `{"/":9,"~1":10,"a":9}`},
+		{`{"foo":null}`, `[{"op":"test","path":"/foo","value":null},{"op":"replace","path":"/foo","value":{"x":1}}]`, `{"foo":{"x":1}}`},
+	}
+	for _, c := range cases {
+		// given
+		patch, err := newJSONPatch([]byte(c.patch))
+		if !tassert.NoError(t, err, "JSON patch %s should parse", c.patch) {
+			continue
+		}
+
+		// when
+		result, err := patch(decodeJSON(c.doc))
+
+		// then
+		if tassert.NoError(t, err, "JSON patch %s should apply to %s", c.patch, c.doc) {
+			tassert.Equal(t, decodeJSON(c.result), result, "JSON patch %s of %s", c.patch, c.doc)
+		}
+	}
+}
+
+func TestJSONPatch_Errors(t *testing.T) {
+	cases := []struct {
+		doc, patch string
+		code       int
+	}{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrInvalidInput},
+		{`{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrInvalidInput},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`, ErrInvalidInput},
+		{`{"foo":["bar"]}`, `[{"op":"replace","path":"/foo/01","value":"qux"}]`, ErrInvalidInput},
+		{`{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, ErrInvalidInput},
+		{`{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrPatchTestFailed},
+		{`{"foo":"bar"}`, `[{"op":"add","path":"foo","value":"qux"}]`, ErrInvalidInput},
+	}
+	for _, c := range cases {
+		// given
+		patch, err := newJSONPatch([]byte(c.patch))
+		if !tassert.NoError(t, err, "JSON patch %s should parse", c.patch) {
+			continue
+		}
+
+		// when
+		_, err = patch(decodeJSON(c.doc))
+
+		// then
+		if tassert.IsType(t, &Error{}, err, "JSON patch %s of %s should fail", c.patch, c.doc) {
+			tassert.Equal(t, c.code, err.(*Error).Code, "Unexpected error code for JSON patch %s of %s", c.patch, c.doc)
+		}
+	}
+}
+
+func TestJSONPatch_InvalidOperations(t *testing.T) {
+	for _, patch := range []string{
+		`{"op":"add","path":"/a","value":1}`,
+		`[{"op":"add","path":"/a"}]`,
+		`[{"op":"copy","path":"/a"}]`,
+		`[{"op":"remove"}]`,
+		`[{"op":"frobnicate","path":"/a"}]`,
+	} {
+		// when
+		_, err := newJSONPatch([]byte(patch))
+
+		// then
+		if tassert.IsType(t, &Error{}, err, "JSON patch %s should not parse", patch) {
+			tassert.Equal(t, ErrInvalidInput, err.(*Error).Code, "Invalid JSON patch %s should be invalid input", patch)
+		}
+	}
+}
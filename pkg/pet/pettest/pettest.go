@@ -0,0 +1,721 @@
+// Package pettest provides a conformance test suite for implementations of pet.Storer.
+//
+// A Storer implementation proves compliance with a single call in its tests:
+//
+//	func TestMyStore(t *testing.T) {
+//		pettest.Run(t, func() pet.Storer { return NewMyStore() })
+//	}
+//
+// Stores that also implement pet.OwnerStorer are checked to keep pets and owners consistent.
+package pettest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+var (
+	pet10 = func() pet.Pet {
+		return pet.Pet{
+			ID:      10,
+			Name:    "Slinky",
+			Species: "Toy dog",
+			Owner:   "Andy",
+			Extra:   nil,
+		}
+	}
+	modifiedPet10 = func() pet.Pet {
+		return pet.Pet{
+			ID:      10,
+			Name:    "Mr Potato Head",
+			Species: "Potato Head",
+			Owner:   "Andy",
+			Extra:   map[string]interface{}{"temperament": "aggressive"},
+		}
+	}
+	pet11 = func() pet.Pet {
+		return pet.Pet{
+			ID:      11,
+			Name:    "Bo Peep",
+			Species: "Sheep herder",
+			Owner:   "Molly",
+			Extra:   map[string]interface{}{"likes": "Woody"},
+		}
+	}
+	pet12 = func() pet.Pet {
+		return pet.Pet{
+			ID:      12,
+			Name:    "Buzz",
+			Species: "Space ranger",
+			Owner:   "Andy",
+			Extra:   nil,
+		}
+	}
+	pet13 = func() pet.Pet {
+		return pet.Pet{
+			ID:      13,
+			Name:    "Rex",
+			Species: "Toy dinosaur",
+			Owner:   "Andy",
+			Extra:   nil,
+		}
+	}
+)
+
+// Factory creates a new, empty store. It is called before every test in the suite.
+type Factory func() pet.Storer
+
+// Run checks that the stores created by factory behave as the Storer interface requires
+func Run(t *testing.T, factory Factory) {
+	suite.Run(t, &storerSuite{factory: factory})
+}
+
+type storerSuite struct {
+	suite.Suite
+	factory Factory
+	store   pet.Storer
+	// pets holds the pets created by the test as they were stored, including their versions
+	pets map[uint32]pet.Pet
+}
+
+func (s *storerSuite) SetupTest() {
+	s.store = s.factory()
+	s.pets = map[uint32]pet.Pet{}
+	s.createPets(pet10())
+}
+
+// createPets adds initial data to the store for a test
+func (s *storerSuite) createPets(pets ...pet.Pet) {
+	for _, newPet := range pets {
+		if err := s.store.CreatePet(context.Background(), &newPet); err != nil {
+			s.T().Fatalf("Could not add initial data to the store. %v", err)
+		}
+		s.pets[newPet.ID] = newPet
+	}
+}
+
+// stored returns the pets created with the given IDs as they were stored
+func (s *storerSuite) stored(petIDs ...uint32) []pet.Pet {
+	pets := make([]pet.Pet, 0, len(petIDs))
+	for _, petID := range petIDs {
+		pets = append(pets, s.pets[petID])
+	}
+	return pets
+}
+
+func (s *storerSuite) TestReadPetSuccessful() {
+	// given
+	assert := tassert.New(s.T())
+
+	// when
+	readPet, err := s.store.ReadPet(context.Background(), 10)
+
+	// then
+	expectedPet := s.pets[10]
+	if assert.NoError(err, "Should be able to read pet10 from store") {
+		assert.Equal(&expectedPet, readPet, "store should return a pet identical to pet10")
+	}
+}
+
+func (s *storerSuite) TestReadPet_IDDoesNotExist() {
+	// given
+	assert := tassert.New(s.T())
+
+	// when
+	_, err := s.store.ReadPet(context.Background(), 11)
+
+	// then
+	assertErrorCode(assert, pet.ErrNotFound, err, "Should get a not found error when attempting to read an non-existing pet")
+}
+
+func (s *storerSuite) TestCreatePetSuccessful() {
+	// given
+	assert := tassert.New(s.T())
+	newPet := pet11()
+
+	// when
+	err := s.store.CreatePet(context.Background(), &newPet)
+
+	// then
+	assert.NoError(err, "Should not get an error creating a pet to a free ID")
+	createdPet, err := s.store.ReadPet(context.Background(), 11)
+	if assert.NoError(err, "Should be able to read an newly created pet") {
+		assert.Equal(&newPet, createdPet, "Created pet should be identical to the one passed to Create")
+	}
+}
+
+func (s *storerSuite) TestCreatePet_IDAlreadyTaken() {
+	// given
+	assert := tassert.New(s.T())
+	oldPet := s.pets[10]
+	newPet := modifiedPet10()
+
+	// when
+	err := s.store.CreatePet(context.Background(), &newPet)
+
+	// then
+	assertErrorCode(assert, pet.ErrDuplicate, err, "Create should return a duplicate error if attempting to create to an already existing ID")
+	currentPet, err := s.store.ReadPet(context.Background(), 10)
+	if assert.NoError(err, "Should be able to read the old pet after a failed overwrite attempt") {
+		assert.Equal(&oldPet, currentPet, "Stored pet should be identical to the old pet after a failed overwrite attempt")
+	}
+}
+
+func (s *storerSuite) TestUpdatePetSuccessful() {
+	// given
+	assert := tassert.New(s.T())
+	testModifiedPet := modifiedPet10()
+
+	// when
+	err := s.store.UpdatePet(context.Background(), 10, &testModifiedPet, pet.Precondition{})
+
+	// then
+	assert.NoError(err, "UpdatePet should successfully update a pet")
+	storedPet, err := s.store.ReadPet(context.Background(), 10)
+	if assert.NoError(err, "Should be able to read a modified pet") {
+		assert.Equal(&testModifiedPet, storedPet, "Stored pet should be equal to the modified pet")
+	}
+}
+
+func (s *storerSuite) TestUpdatePet_IDDoesNotExist() {
+	// given
+	assert := tassert.New(s.T())
+	testPet := pet11()
+
+	// when
+	err := s.store.UpdatePet(context.Background(), 11, &testPet, pet.Precondition{})
+
+	// then
+	assert.NoError(err, "Updating to non-existing pet ID is not an error")
+	newPet, err := s.store.ReadPet(context.Background(), 11)
+	if assert.NoError(err, "Should be able to read a newly added pet via update") {
+		assert.Equal(&testPet, newPet, "Newly added pet should be equal to test pet")
+	}
+}
+
+func (s *storerSuite) TestDeletePetSuccessful() {
+	// when
+	assert := tassert.New(s.T())
+	deleted, err := s.store.DeletePet(context.Background(), 10, pet.Precondition{})
+
+	// then
+	assert.NoError(err, "Delete should successfully delete a pet")
+	assert.True(deleted, "Delete should return true indicating a pet was deleted")
+	_, err = s.store.ReadPet(context.Background(), 10)
+	assert.Error(err, "Should not be able to read a deleted ID")
+}
+
+func (s *storerSuite) TestDeletePet_IDDoesNotExist() {
+	// when
+	assert := tassert.New(s.T())
+	deleted, err := s.store.DeletePet(context.Background(), 11, pet.Precondition{})
+
+	// then
+	assert.NoError(err, "Deleting a non-existing ID is not an error")
+	assert.False(deleted, "Delete should return false indicating no pet was deleted")
+}
+
+func (s *storerSuite) TestVersionsIncrease() {
+	// given
+	assert := tassert.New(s.T())
+	oldVersion := s.pets[10].Version
+	testModifiedPet := modifiedPet10()
+
+	// when
+	err := s.store.UpdatePet(context.Background(), 10, &testModifiedPet, pet.Precondition{})
+
+	// then
+	if assert.NoError(err, "UpdatePet should successfully update a pet") {
+		assert.True(testModifiedPet.Version > oldVersion, "Update should assign a newer version to the pet passed in")
+		storedPet, err := s.store.ReadPet(context.Background(), 10)
+		if assert.NoError(err, "Should be able to read an updated pet") {
+			assert.Equal(testModifiedPet.Version, storedPet.Version, "Stored version should be the one assigned by update")
+		}
+	}
+
+	// when the pet is deleted and created again
+	if _, err = s.store.DeletePet(context.Background(), 10, pet.Precondition{}); err != nil {
+		panic("Error in test code, could not delete pet")
+	}
+	recreatedPet := pet10()
+	err = s.store.CreatePet(context.Background(), &recreatedPet)
+
+	// then
+	if assert.NoError(err, "Should be able to create a deleted pet again") {
+		assert.True(recreatedPet.Version > testModifiedPet.Version, "Versions should not be reused after a delete")
+	}
+}
+
+func (s *storerSuite) TestUpdatePet_Preconditions() {
+	assert := tassert.New(s.T())
+	version := s.pets[10].Version
+	cases := []struct {
+		name   string
+		id     uint32
+		pre    pet.Precondition
+		passes bool
+	}{
+		{"matching version", 10, pet.Precondition{IfMatch: []uint64{version + 100, version}}, true},
+		{"stale version", 10, pet.Precondition{IfMatch: []uint64{version + 100}}, false},
+		{"version of missing pet", 11, pet.Precondition{IfMatch: []uint64{version}}, false},
+		{"any version of existing pet", 10, pet.Precondition{IfMatchAny: true}, true},
+		{"any version of missing pet", 11, pet.Precondition{IfMatchAny: true}, false},
+		{"excluded version", 10, pet.Precondition{IfNoneMatch: []uint64{version}}, false},
+		{"other excluded version", 10, pet.Precondition{IfNoneMatch: []uint64{version + 100}}, true},
+		{"no existing pet of existing pet", 10, pet.Precondition{IfNoneMatchAny: true}, false},
+		{"no existing pet of missing pet", 11, pet.Precondition{IfNoneMatchAny: true}, true},
+	}
+	for _, c := range cases {
+		// given
+		s.SetupTest()
+		testPet := pet11()
+		testPet.ID = c.id
+
+		// when
+		err := s.store.UpdatePet(context.Background(), c.id, &testPet, c.pre)
+
+		// then
+		if c.passes {
+			assert.NoError(err, "Update with %s should succeed", c.name)
+			continue
+		}
+		assertErrorCode(assert, pet.ErrConflict, err, "Update with "+c.name+" should fail with a conflict")
+		storedPet, _ := s.store.ReadPet(context.Background(), c.id)
+		if c.id == 10 {
+			assert.Equal(version, storedPet.Version, "Failed update with %s should not modify the pet", c.name)
+		} else {
+			assert.Nil(storedPet, "Failed update with %s should not create the pet", c.name)
+		}
+	}
+}
+
+func (s *storerSuite) TestDeletePet_Preconditions() {
+	// given
+	assert := tassert.New(s.T())
+	version := s.pets[10].Version
+
+	// when
+	deleted, err := s.store.DeletePet(context.Background(), 10, pet.Precondition{IfMatch: []uint64{version + 100}})
+
+	// then
+	assertErrorCode(assert, pet.ErrConflict, err, "Delete of a stale version should fail with a conflict")
+	assert.False(deleted, "Delete of a stale version should not delete the pet")
+	_, err = s.store.ReadPet(context.Background(), 10)
+	assert.NoError(err, "Pet should still exist after a failed delete")
+
+	// when
+	deleted, err = s.store.DeletePet(context.Background(), 10, pet.Precondition{IfMatch: []uint64{version}})
+
+	// then
+	assert.NoError(err, "Delete of the current version should succeed")
+	assert.True(deleted, "Delete of the current version should delete the pet")
+}
+
+func (s *storerSuite) TestCreatePet_AllocatesID() {
+	// given
+	assert := tassert.New(s.T())
+	firstPet, secondPet := pet11(), pet12()
+	firstPet.ID, secondPet.ID = 0, 0
+
+	// when
+	firstErr := s.store.CreatePet(context.Background(), &firstPet)
+	secondErr := s.store.CreatePet(context.Background(), &secondPet)
+
+	// then
+	if !assert.NoError(firstErr, "Creating a pet without an ID should succeed") || !assert.NoError(secondErr, "Creating a pet without an ID should succeed") {
+		return
+	}
+	assert.NotZero(firstPet.ID, "Created pet should be given an ID")
+	assert.NotEqual(firstPet.ID, secondPet.ID, "Created pets should be given different IDs")
+	assert.NotEqual(uint32(10), firstPet.ID, "Allocated IDs should not be in use")
+	storedPet, err := s.store.ReadPet(context.Background(), firstPet.ID)
+	if assert.NoError(err, "Should be able to read a pet by its allocated ID") {
+		assert.Equal(&firstPet, storedPet, "Stored pet should be identical to the created pet")
+	}
+}
+
+func (s *storerSuite) TestCreatePet_ConcurrentCreatesOfSameID() {
+	// given
+	assert := tassert.New(s.T())
+	const racers = 20
+	errs := make(chan error, racers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// when
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			newPet := pet11()
+			newPet.Name = fmt.Sprintf("Bo Peep %d", i)
+			<-start
+			errs <- s.store.CreatePet(context.Background(), &newPet)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	// then
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assertErrorCode(assert, pet.ErrDuplicate, err, "Losing a create race should return a duplicate error")
+	}
+	assert.Equal(1, created, "Exactly one concurrent create of the same ID should succeed")
+}
+
+func (s *storerSuite) TestCreatePet_ConcurrentCreatesOfDifferentIDs() {
+	// given
+	assert := tassert.New(s.T())
+	const racers = 20
+	var wg sync.WaitGroup
+
+	// when
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			newPet := pet11()
+			newPet.ID = uint32(100 + i)
+			assert.NoError(s.store.CreatePet(context.Background(), &newPet), "Concurrent creates of different IDs should all succeed")
+		}(i)
+	}
+	wg.Wait()
+
+	// then
+	page, err := s.store.ListPets(context.Background(), pet.ListQuery{Limit: pet.MaxListLimit})
+	if assert.NoError(err, "Should be able to list pets after concurrent creates") {
+		assert.Len(page.Pets, racers+1, "Every concurrently created pet should be stored")
+	}
+}
+
+func (s *storerSuite) TestExtraRoundTrip() {
+	// given
+	assert := tassert.New(s.T())
+	extras := map[string]map[string]interface{}{
+		"nil":   nil,
+		"empty": {},
+		"nested": {
+			"string": "Woody",
+			"number": 3.5,
+			"bool":   true,
+			"null":   nil,
+			"list":   []interface{}{"hat", 2.0, false, map[string]interface{}{"colour": "brown"}},
+			"object": map[string]interface{}{"toys": map[string]interface{}{"count": 12.0}},
+		},
+	}
+
+	for name, extra := range extras {
+		// when
+		newPet := pet11()
+		newPet.Extra = extra
+		err := s.store.UpdatePet(context.Background(), 11, &newPet, pet.Precondition{})
+
+		// then
+		if assert.NoError(err, "Should be able to store %s extra data", name) {
+			storedPet, err := s.store.ReadPet(context.Background(), 11)
+			if assert.NoError(err, "Should be able to read back %s extra data", name) {
+				assert.Equal(extra, storedPet.Extra, "Stored %s extra data should be identical to the original", name)
+			}
+		}
+	}
+}
+
+func (s *storerSuite) TestListPetsFiltersAndSorts() {
+	// given
+	assert := tassert.New(s.T())
+	s.createPets(pet11(), pet12(), pet13())
+
+	// when
+	page, err := s.store.ListPets(context.Background(), pet.ListQuery{Owner: "Andy", Sort: "-name"})
+
+	// then
+	if assert.NoError(err, "Should be able to list pets") {
+		assert.Equal(s.stored(10, 13, 12), page.Pets, "Andy's pets should be listed by descending name")
+		assert.Empty(page.NextCursor, "A single page should not have a next cursor")
+	}
+
+	// when
+	page, err = s.store.ListPets(context.Background(), pet.ListQuery{Species: "Toy dog", NamePrefix: "S"})
+
+	// then
+	if assert.NoError(err, "Should be able to list pets") {
+		assert.Equal(s.stored(10), page.Pets, "Only Slinky should match species and name prefix")
+	}
+}
+
+func (s *storerSuite) TestListPetsPagination() {
+	// given
+	assert := tassert.New(s.T())
+	s.createPets(pet11(), pet12(), pet13())
+
+	// when
+	first, err := s.store.ListPets(context.Background(), pet.ListQuery{Sort: "name", Limit: 2})
+
+	// then
+	if !assert.NoError(err, "Should be able to list the first page") {
+		return
+	}
+	assert.Equal(s.stored(11, 12), first.Pets, "First page should hold the first two pets by name")
+	assert.NotEmpty(first.NextCursor, "First page should have a next cursor")
+
+	// when a pet is added before the cursor and the last page pet is removed
+	s.createPets(pet.Pet{ID: 14, Name: "Al", Species: "Toy collector", Owner: "Al"})
+	if _, err = s.store.DeletePet(context.Background(), 12, pet.Precondition{}); err != nil {
+		panic("Error in test code, could not delete data between pages")
+	}
+	second, err := s.store.ListPets(context.Background(), pet.ListQuery{Sort: "name", Limit: 2, Cursor: first.NextCursor})
+
+	// then
+	if assert.NoError(err, "Should be able to list the second page") {
+		assert.Equal(s.stored(13, 10), second.Pets, "Second page should continue after the cursor regardless of writes")
+		assert.Empty(second.NextCursor, "Last page should not have a next cursor")
+	}
+}
+
+func (s *storerSuite) TestListPets_InvalidQuery() {
+	// given
+	assert := tassert.New(s.T())
+	s.createPets(pet11())
+	page, err := s.store.ListPets(context.Background(), pet.ListQuery{Sort: "name", Limit: 1})
+	if err != nil {
+		panic("Error in test code, could not list first page")
+	}
+
+	// when
+	_, sortErr := s.store.ListPets(context.Background(), pet.ListQuery{Sort: "age"})
+	_, limitErr := s.store.ListPets(context.Background(), pet.ListQuery{Limit: pet.MaxListLimit + 1})
+	_, cursorErr := s.store.ListPets(context.Background(), pet.ListQuery{Cursor: "not a cursor"})
+	_, mismatchErr := s.store.ListPets(context.Background(), pet.ListQuery{Sort: "owner", Cursor: page.NextCursor})
+	_, filtersErr := s.store.ListPets(context.Background(), pet.ListQuery{Sort: "name", NamePrefix: "Z", Cursor: page.NextCursor})
+
+	// then
+	assert.Error(sortErr, "Listing with an unknown sort field should fail")
+	assert.Error(limitErr, "Listing with a limit above the maximum should fail")
+	assert.Error(cursorErr, "Listing with a malformed cursor should fail")
+	assert.Error(mismatchErr, "Listing with a cursor from a different sort should fail")
+	assertErrorCode(assert, pet.ErrInvalidInput, filtersErr, "Listing with a cursor from different filters should fail")
+}
+
+func (s *storerSuite) TestCanceledContext() {
+	// given
+	assert := tassert.New(s.T())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	newPet := pet11()
+	modifiedPet := modifiedPet10()
+
+	// when
+	createErr := s.store.CreatePet(ctx, &newPet)
+	_, readErr := s.store.ReadPet(ctx, 10)
+	updateErr := s.store.UpdatePet(ctx, 10, &modifiedPet, pet.Precondition{})
+	_, deleteErr := s.store.DeletePet(ctx, 10, pet.Precondition{})
+	_, listErr := s.store.ListPets(ctx, pet.ListQuery{})
+
+	// then
+	assertErrorCode(assert, pet.ErrCanceled, createErr, "Create with a canceled context should fail")
+	assertErrorCode(assert, pet.ErrCanceled, readErr, "Read with a canceled context should fail")
+	assertErrorCode(assert, pet.ErrCanceled, updateErr, "Update with a canceled context should fail")
+	assertErrorCode(assert, pet.ErrCanceled, deleteErr, "Delete with a canceled context should fail")
+	assertErrorCode(assert, pet.ErrCanceled, listErr, "List with a canceled context should fail")
+	_, err := s.store.ReadPet(context.Background(), 11)
+	assertErrorCode(assert, pet.ErrNotFound, err, "Canceled create should not store the pet")
+	storedPet, err := s.store.ReadPet(context.Background(), 10)
+	if assert.NoError(err, "Canceled delete should not delete the pet") {
+		assert.Equal(s.pets[10], *storedPet, "Canceled update should not modify the pet")
+	}
+}
+
+func (s *storerSuite) TestExpiredDeadline() {
+	// given
+	assert := tassert.New(s.T())
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	newPet := pet11()
+
+	// when
+	createErr := s.store.CreatePet(ctx, &newPet)
+	_, readErr := s.store.ReadPet(ctx, 10)
+	_, listErr := s.store.ListPets(ctx, pet.ListQuery{})
+
+	// then
+	assertErrorCode(assert, pet.ErrTimeout, createErr, "Create after the deadline should time out")
+	assertErrorCode(assert, pet.ErrTimeout, readErr, "Read after the deadline should time out")
+	assertErrorCode(assert, pet.ErrTimeout, listErr, "List after the deadline should time out")
+}
+
+func (s *storerSuite) TestBatchAtomic() {
+	// given
+	assert := tassert.New(s.T())
+	newPet, modifiedPet := pet11(), modifiedPet10()
+	ops := []pet.BatchOperation{
+		{Op: pet.BatchCreate, Pet: &newPet},
+		{Op: pet.BatchUpsert, ID: 10, Pet: &modifiedPet},
+		{Op: pet.BatchCreate, Pet: &newPet},
+	}
+
+	// when
+	_, err := s.store.Batch(context.Background(), ops, true)
+
+	// then
+	if errors.Is(err, pet.ErrUnsupportedSentinel) {
+		s.T().Skip("Store does not support atomic batches")
+	}
+	assertErrorCode(assert, pet.ErrDuplicate, err, "Batch should fail with the error of the failed operation")
+	_, err = s.store.ReadPet(context.Background(), 11)
+	assertErrorCode(assert, pet.ErrNotFound, err, "Failed batch should not create pets")
+	storedPet, err := s.store.ReadPet(context.Background(), 10)
+	if assert.NoError(err, "Failed batch should not delete pets") {
+		assert.Equal(s.pets[10], *storedPet, "Failed batch should not modify pets")
+	}
+
+	// when
+	results, err := s.store.Batch(context.Background(), ops[:2], true)
+
+	// then
+	if assert.NoError(err, "Should be able to apply a batch") && assert.Len(results, 2, "Every operation should have a result") {
+		for i, petID := range []uint32{11, 10} {
+			storedPet, err := s.store.ReadPet(context.Background(), petID)
+			if assert.NoError(err, "Batch should write pet %d", petID) {
+				assert.Equal(storedPet, results[i].Pet, "Result should hold pet %d as stored", petID)
+			}
+		}
+	}
+}
+
+func (s *storerSuite) TestBatchBestEffort() {
+	// given
+	assert := tassert.New(s.T())
+	newPet, duplicatePet := pet11(), pet10()
+	ops := []pet.BatchOperation{
+		{Op: pet.BatchCreate, Pet: &newPet},
+		{Op: pet.BatchCreate, Pet: &duplicatePet},
+		{Op: pet.BatchDelete, ID: 10},
+		{Op: pet.BatchDelete, ID: 12},
+		{Op: "replace", ID: 10},
+	}
+
+	// when
+	results, err := s.store.Batch(context.Background(), ops, false)
+
+	// then
+	if !assert.NoError(err, "Best effort batch should not fail as a whole") || !assert.Len(results, len(ops)) {
+		return
+	}
+	if assert.NoError(results[0].Err, "Create should succeed") {
+		storedPet, err := s.store.ReadPet(context.Background(), 11)
+		if assert.NoError(err, "Created pet should be stored") {
+			assert.Equal(storedPet, results[0].Pet, "Result should hold the created pet as stored")
+		}
+	}
+	assertErrorCode(assert, pet.ErrDuplicate, results[1].Err, "Create of a taken ID should fail")
+	assert.True(results[2].Deleted, "Existing pet should be deleted")
+	assert.NoError(results[3].Err, "Delete of a missing pet should succeed")
+	assert.False(results[3].Deleted, "Delete of a missing pet should report that nothing was deleted")
+	assertErrorCode(assert, pet.ErrInvalidInput, results[4].Err, "Unknown operation should fail")
+	_, err = s.store.ReadPet(context.Background(), 10)
+	assertErrorCode(assert, pet.ErrNotFound, err, "Deleted pet should not be found")
+}
+
+// owners returns the store as an OwnerStorer, skipping the test if it does not store owners
+func (s *storerSuite) owners() pet.OwnerStorer {
+	owners, ok := pet.OwnerStore(s.store)
+	if !ok {
+		s.T().Skip("Store does not implement pet.OwnerStorer")
+	}
+	return owners
+}
+
+func (s *storerSuite) TestOwnerCRUD() {
+	// given
+	assert := tassert.New(s.T())
+	owners := s.owners()
+	andy := &pet.Owner{Name: "Andy"}
+
+	// when
+	createErr := owners.CreateOwner(context.Background(), andy)
+	duplicateErr := owners.CreateOwner(context.Background(), &pet.Owner{ID: andy.ID, Name: "Sid"})
+	updateErr := owners.UpdateOwner(context.Background(), andy.ID, &pet.Owner{Name: "Andy Davis"})
+	readOwner, readErr := owners.ReadOwner(context.Background(), andy.ID)
+
+	// then
+	if assert.NoError(createErr, "Should be able to create an owner") {
+		assert.NotZero(andy.ID, "Created owner should be given an ID")
+	}
+	assertErrorCode(assert, pet.ErrDuplicate, duplicateErr, "Creating an owner with a taken ID should fail")
+	assert.NoError(updateErr, "Should be able to update an owner")
+	if assert.NoError(readErr, "Should be able to read an owner") {
+		assert.Equal(&pet.Owner{ID: andy.ID, Name: "Andy Davis"}, readOwner, "Owner should be updated")
+	}
+
+	// when
+	deleted, deleteErr := owners.DeleteOwner(context.Background(), andy.ID)
+	deletedAgain, _ := owners.DeleteOwner(context.Background(), andy.ID)
+	_, readErr = owners.ReadOwner(context.Background(), andy.ID)
+
+	// then
+	if assert.NoError(deleteErr, "Should be able to delete an owner") {
+		assert.True(deleted, "Existing owner should be deleted")
+	}
+	assert.False(deletedAgain, "Deleting a missing owner should report that nothing was deleted")
+	assertErrorCode(assert, pet.ErrNotFound, readErr, "Deleted owner should not be found")
+}
+
+func (s *storerSuite) TestOwnerReferences() {
+	// given
+	assert := tassert.New(s.T())
+	owners := s.owners()
+	andy := &pet.Owner{Name: "Andy"}
+	if err := owners.CreateOwner(context.Background(), andy); err != nil {
+		s.T().Fatalf("Could not add initial owner to the store. %v", err)
+	}
+	orphan := pet11()
+	orphan.OwnerID = andy.ID + 1
+	owned := pet12()
+	owned.OwnerID = andy.ID
+	modified := modifiedPet10()
+	modified.OwnerID = andy.ID + 1
+
+	// when
+	orphanErr := s.store.CreatePet(context.Background(), &orphan)
+	modifiedErr := s.store.UpdatePet(context.Background(), 10, &modified, pet.Precondition{})
+	ownedErr := s.store.CreatePet(context.Background(), &owned)
+	page, listErr := s.store.ListPets(context.Background(), pet.ListQuery{OwnerID: andy.ID})
+	_, deleteErr := owners.DeleteOwner(context.Background(), andy.ID)
+
+	// then
+	assertErrorCode(assert, pet.ErrReference, orphanErr, "Creating a pet of a missing owner should fail")
+	assertErrorCode(assert, pet.ErrReference, modifiedErr, "Updating a pet to a missing owner should fail")
+	_, err := s.store.ReadPet(context.Background(), 11)
+	assertErrorCode(assert, pet.ErrNotFound, err, "Pet of a missing owner should not be stored")
+	assert.NoError(ownedErr, "Should be able to create a pet of an existing owner")
+	if assert.NoError(listErr, "Should be able to list the pets of an owner") {
+		assert.Equal([]pet.Pet{owned}, page.Pets, "Only the pets of the owner should be listed")
+	}
+	assertErrorCode(assert, pet.ErrReference, deleteErr, "Deleting an owner with pets should fail")
+	_, err = owners.ReadOwner(context.Background(), andy.ID)
+	assert.NoError(err, "Owner with pets should not be deleted")
+}
+
+// assertErrorCode asserts that err is a *pet.Error with the given code
+func assertErrorCode(assert *tassert.Assertions, code int, err error, msg string) bool {
+	var petErr *pet.Error
+	if !errors.As(err, &petErr) {
+		return assert.Fail(fmt.Sprintf("Expected a *pet.Error but got %T: %v", err, err), msg)
+	}
+	return assert.Equal(code, petErr.Code, msg)
+}
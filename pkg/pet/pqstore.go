@@ -0,0 +1,599 @@
+package pet
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pqSchema creates the pets and owners tables, upgrading tables created before pets
+// were versioned or referenced their owners
+var pqSchema = []string{
+	`CREATE TABLE IF NOT EXISTS pets (
+	id      BIGINT PRIMARY KEY,
+	name    TEXT NOT NULL,
+	species TEXT NOT NULL,
+	owner   TEXT NOT NULL,
+	extra   JSONB
+)`,
+	`CREATE SEQUENCE IF NOT EXISTS pet_versions`,
+	`CREATE SEQUENCE IF NOT EXISTS pet_ids MAXVALUE 4294967295`,
+	`ALTER TABLE pets ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT nextval('pet_versions')`,
+	`CREATE TABLE IF NOT EXISTS owners (
+	id   BIGINT PRIMARY KEY,
+	name TEXT NOT NULL
+)`,
+	`CREATE SEQUENCE IF NOT EXISTS owner_ids MAXVALUE 4294967295`,
+	`ALTER TABLE pets ADD COLUMN IF NOT EXISTS owner_id BIGINT REFERENCES owners (id)`,
+}
+
+// SQL statements used by PQStore, written for postgres positional parameters.
+// Every write draws a new version from the pet_versions sequence.
+const (
+	pqInsertPet = `INSERT INTO pets (id, name, species, owner, extra, owner_id, version)
+VALUES ($1, $2, $3, $4, $5, $6, nextval('pet_versions')) RETURNING version`
+	pqUpsertPet = `INSERT INTO pets (id, name, species, owner, extra, owner_id, version)
+VALUES ($1, $2, $3, $4, $5, $6, nextval('pet_versions'))
+ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, species = EXCLUDED.species, owner = EXCLUDED.owner, extra = EXCLUDED.extra, owner_id = EXCLUDED.owner_id, version = EXCLUDED.version
+RETURNING version`
+	pqUpdatePet = `UPDATE pets SET name = $2, species = $3, owner = $4, extra = $5, owner_id = $6, version = nextval('pet_versions')
+WHERE id = $1 RETURNING version`
+	pqLockPet    = `SELECT version FROM pets WHERE id = $1 FOR UPDATE`
+	pqNextID     = `SELECT nextval('pet_ids')`
+	pqSelectPets = `SELECT id, name, species, owner, owner_id, extra, version FROM pets`
+	pqSelectPet  = pqSelectPets + ` WHERE id = $1`
+	pqListFilter = ` WHERE ($1::text = '' OR owner = $1) AND ($2::text = '' OR species = $2) AND name LIKE $3 ESCAPE '\'` +
+		` AND ($4::bigint = 0 OR owner_id = $4)`
+	pqDeletePet = `DELETE FROM pets WHERE id = $1`
+
+	pqInsertOwner = `INSERT INTO owners (id, name) VALUES ($1, $2)`
+	pqUpsertOwner = `INSERT INTO owners (id, name) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name`
+	pqSelectOwner = `SELECT id, name FROM owners WHERE id = $1`
+	pqDeleteOwner = `DELETE FROM owners WHERE id = $1`
+	pqNextOwnerID = `SELECT nextval('owner_ids')`
+)
+
+// Postgres SQLSTATE codes raised when a unique or foreign key constraint is violated
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+// PQConfig holds the connection settings for a postgres backed store
+type PQConfig struct {
+	// Driver is the database/sql driver name, defaults to "postgres"
+	Driver string
+	// ConnString is passed to the driver as the data source name
+	ConnString string
+	// MaxOpenConns limits the number of open connections, 0 means unlimited
+	MaxOpenConns int
+	// MaxIdleConns limits the number of idle connections kept in the pool
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections older than this, 0 means connections are reused forever
+	ConnMaxLifetime time.Duration
+	// IDs allocates the IDs of pets created without one, defaults to the pet_ids sequence
+	IDs IDGenerator
+}
+
+// PQStore is a postgres implementation of Storer
+type PQStore struct {
+	db *sql.DB
+	// IDs allocates the IDs of pets created without one
+	IDs IDGenerator
+	// ownerIDs allocates the IDs of owners created without one
+	ownerIDs IDGenerator
+}
+
+// NewPQStore creates a store on top of an already opened database,
+// allocating IDs from the pet_ids and owner_ids sequences.
+// The schema is not created, see CreateSchema.
+func NewPQStore(db *sql.DB) *PQStore {
+	return &PQStore{
+		db:       db,
+		IDs:      &pqSequenceIDs{db: db, statement: pqNextID, resource: "pet"},
+		ownerIDs: &pqSequenceIDs{db: db, statement: pqNextOwnerID, resource: "owner"},
+	}
+}
+
+// OpenPQStore connects to the database described by cfg, verifies the connection
+// and creates the pets and owners tables if they do not already exist
+func OpenPQStore(cfg PQConfig) (*PQStore, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = "postgres"
+	}
+	db, err := sql.Open(driver, cfg.ConnString)
+	if err != nil {
+		return nil, ErrorEf(ErrUnknown, err, "Could not open database")
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, ErrorEf(ErrUnknown, err, "Could not connect to database")
+	}
+	store := NewPQStore(db)
+	if cfg.IDs != nil {
+		store.IDs = cfg.IDs
+	}
+	if err = store.CreateSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// CreateSchema creates the pets and owners tables if they do not already exist
+func (s *PQStore) CreateSchema() error {
+	for _, statement := range pqSchema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return ErrorEf(ErrUnknown, err, "Could not create pets and owners tables")
+		}
+	}
+	return nil
+}
+
+// Close closes the database connections, waiting for queries in progress to finish
+func (s *PQStore) Close() error {
+	if err := s.db.Close(); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not close database")
+	}
+	return nil
+}
+
+// CheckHealth verifies the database can be reached
+func (s *PQStore) CheckHealth(ctx context.Context) error {
+	if err := s.db.PingContext(ctx); err != nil {
+		return ErrorEf(ErrUnknown, err, "Could not connect to database")
+	}
+	return nil
+}
+
+// CreatePet adds a new pet to the store, allocating an ID if the pet's ID is 0
+func (s *PQStore) CreatePet(ctx context.Context, pet *Pet) error {
+	extra, err := marshalExtra(pet.Extra)
+	if err != nil {
+		return err
+	}
+	if pet.ID != 0 {
+		return s.insertPet(ctx, s.db, pet.ID, pet, extra)
+	}
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.IDs.NextID(ctx)
+		if err != nil {
+			return err
+		}
+		err = s.insertPet(ctx, s.db, id, pet, extra)
+		if !errors.Is(err, ErrDuplicateSentinel) {
+			return err
+		}
+	}
+	return Errorf(ErrUnknown, "Could not allocate a free pet ID")
+}
+
+// insertPet inserts a pet with the given ID, setting the ID and version of the pet on success
+func (s *PQStore) insertPet(ctx context.Context, q pqQuerier, id uint32, pet *Pet, extra interface{}) error {
+	var version uint64
+	err := q.QueryRowContext(ctx, pqInsertPet, int64(id), pet.Name, pet.Species, pet.Owner, extra, pqOwnerID(pet)).Scan(&version)
+	if err != nil {
+		if isSQLState(err, pqUniqueViolation) {
+			return ErrorEf(ErrDuplicate, err, "Pet with id %d already exists", id)
+		}
+		if isSQLState(err, pqForeignKeyViolation) {
+			return ErrorEf(ErrReference, err, "No owner exists with id %d", pet.OwnerID)
+		}
+		return pqFailure(ctx, err, "Could not create pet with id %d", id)
+	}
+	pet.ID, pet.Version = id, version
+	return nil
+}
+
+// ReadPet gets a pet from the store given an ID
+func (s *PQStore) ReadPet(ctx context.Context, petID uint32) (*Pet, error) {
+	pet, err := scanPet(s.db.QueryRowContext(ctx, pqSelectPet, int64(petID)))
+	if err == sql.ErrNoRows {
+		return nil, Errorf(ErrNotFound, "No pet exists with id %d", petID)
+	}
+	if err != nil {
+		return nil, pqFailure(ctx, err, "Could not read pet with id %d", petID)
+	}
+	return pet, nil
+}
+
+// UpdatePet puts new pet data to the store, either creating a new one or overriding an old
+func (s *PQStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) error {
+	extra, err := marshalExtra(pet.Extra)
+	if err != nil {
+		return err
+	}
+	args := []interface{}{int64(petID), pet.Name, pet.Species, pet.Owner, extra, pqOwnerID(pet)}
+	var version uint64
+	if pre.empty() {
+		err = s.db.QueryRowContext(ctx, pqUpsertPet, args...).Scan(&version)
+	} else {
+		err = s.inTx(ctx, func(tx *sql.Tx) error {
+			stored, err := lockPet(ctx, tx, petID)
+			if err != nil {
+				return err
+			}
+			if err = pre.Check(petID, stored); err != nil {
+				return err
+			}
+			statement := pqUpdatePet
+			if stored == nil {
+				statement = pqInsertPet
+			}
+			err = tx.QueryRowContext(ctx, statement, args...).Scan(&version)
+			if isSQLState(err, pqUniqueViolation) {
+				// the pet was created after it was found to be missing
+				return ErrorEf(ErrConflict, err, "Pet with id %d was created concurrently", petID)
+			}
+			return err
+		})
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	if isSQLState(err, pqForeignKeyViolation) {
+		return ErrorEf(ErrReference, err, "No owner exists with id %d", pet.OwnerID)
+	}
+	if err != nil {
+		return pqFailure(ctx, err, "Could not update pet with id %d", petID)
+	}
+	pet.Version = version
+	return nil
+}
+
+// DeletePet deletes a pet from the store
+func (s *PQStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (bool, error) {
+	var result sql.Result
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if !pre.empty() {
+			stored, err := lockPet(ctx, tx, petID)
+			if err != nil {
+				return err
+			}
+			if err = pre.Check(petID, stored); err != nil {
+				return err
+			}
+		}
+		var err error
+		result, err = tx.ExecContext(ctx, pqDeletePet, int64(petID))
+		return err
+	})
+	if _, ok := err.(*Error); ok {
+		return false, err
+	}
+	if err != nil {
+		return false, pqFailure(ctx, err, "Could not delete pet with id %d", petID)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, pqFailure(ctx, err, "Could not delete pet with id %d", petID)
+	}
+	return deleted > 0, nil
+}
+
+// ListPets returns a page of pets matching the query
+func (s *PQStore) ListPets(ctx context.Context, query ListQuery) (*PetPage, error) {
+	plan, err := planList(query)
+	if err != nil {
+		return nil, err
+	}
+	statement, args := pqListPets(plan)
+	rows, err := s.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, pqFailure(ctx, err, "Could not list pets")
+	}
+	defer rows.Close()
+	var pets []Pet
+	for rows.Next() {
+		pet, err := scanPet(rows)
+		if err != nil {
+			return nil, pqFailure(ctx, err, "Could not list pets")
+		}
+		pets = append(pets, *pet)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, pqFailure(ctx, err, "Could not list pets")
+	}
+	return plan.page(pets), nil
+}
+
+// Batch applies a batch of operations. An atomic batch runs in a single transaction,
+// in which a create without an ID gets a single attempt at a generated ID.
+func (s *PQStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ops))
+	if !atomic {
+		for i, op := range ops {
+			results[i] = applyBatchOp(ctx, s, op)
+		}
+		return results, nil
+	}
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		for i, op := range ops {
+			if results[i] = s.applyBatchOpTx(ctx, tx, op); results[i].Err != nil {
+				return batchFailure(i, results[i].Err)
+			}
+		}
+		return nil
+	})
+	if _, ok := err.(*Error); ok {
+		return nil, err
+	}
+	if err != nil {
+		return nil, pqFailure(ctx, err, "Could not apply batch")
+	}
+	return results, nil
+}
+
+// applyBatchOpTx applies a single operation of an atomic batch in tx
+func (s *PQStore) applyBatchOpTx(ctx context.Context, tx *sql.Tx, op BatchOperation) BatchResult {
+	if err := op.validate(); err != nil {
+		return BatchResult{Err: err}
+	}
+	if op.Op == BatchDelete {
+		result, err := tx.ExecContext(ctx, pqDeletePet, int64(op.ID))
+		if err != nil {
+			return BatchResult{Err: pqFailure(ctx, err, "Could not delete pet with id %d", op.ID)}
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return BatchResult{Err: pqFailure(ctx, err, "Could not delete pet with id %d", op.ID)}
+		}
+		return BatchResult{Deleted: deleted > 0}
+	}
+	pet := *op.Pet
+	extra, err := marshalExtra(pet.Extra)
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+	if op.Op == BatchUpsert {
+		pet.ID = op.ID
+		err = tx.QueryRowContext(ctx, pqUpsertPet, int64(pet.ID), pet.Name, pet.Species, pet.Owner, extra, pqOwnerID(&pet)).Scan(&pet.Version)
+		if isSQLState(err, pqForeignKeyViolation) {
+			return BatchResult{Err: ErrorEf(ErrReference, err, "No owner exists with id %d", pet.OwnerID)}
+		}
+		if err != nil {
+			return BatchResult{Err: pqFailure(ctx, err, "Could not update pet with id %d", pet.ID)}
+		}
+		return BatchResult{Pet: &pet}
+	}
+	id := pet.ID
+	if id == 0 {
+		if id, err = s.IDs.NextID(ctx); err != nil {
+			return BatchResult{Err: err}
+		}
+	}
+	if err = s.insertPet(ctx, tx, id, &pet, extra); err != nil {
+		return BatchResult{Err: err}
+	}
+	return BatchResult{Pet: &pet}
+}
+
+// pqListPets builds the statement for a listing. One row more than the page size is
+// selected to find out whether there is a next page.
+func pqListPets(plan *listPlan) (string, []interface{}) {
+	column, direction, comparison := "id", "ASC", ">"
+	if plan.field != "" {
+		column = plan.field
+	}
+	if plan.desc {
+		direction, comparison = "DESC", "<"
+	}
+	statement := pqSelectPets + pqListFilter
+	args := []interface{}{plan.Owner, plan.Species, pqLikePrefix(plan.NamePrefix), int64(plan.OwnerID)}
+	if plan.after != nil && plan.field == "" {
+		statement += fmt.Sprintf(" AND id %s $5", comparison)
+		args = append(args, int64(plan.after.ID))
+	} else if plan.after != nil {
+		statement += fmt.Sprintf(" AND (%s, id) %s ($5, $6)", column, comparison)
+		args = append(args, plan.after.Key, int64(plan.after.ID))
+	}
+	if plan.field == "" {
+		statement += fmt.Sprintf(" ORDER BY id %s", direction)
+	} else {
+		statement += fmt.Sprintf(" ORDER BY %s %s, id %s", column, direction, direction)
+	}
+	statement += fmt.Sprintf(" LIMIT %d", plan.Limit+1)
+	return statement, args
+}
+
+// pqLikePrefix escapes LIKE wildcards in a prefix and turns it into a pattern
+func pqLikePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix) + "%"
+}
+
+// scanPet reads a pet from a row selected with pqSelectPets
+func scanPet(row interface{ Scan(...interface{}) error }) (*Pet, error) {
+	var (
+		id      int64
+		pet     Pet
+		ownerID sql.NullInt64
+		extra   []byte
+	)
+	if err := row.Scan(&id, &pet.Name, &pet.Species, &pet.Owner, &ownerID, &extra, &pet.Version); err != nil {
+		return nil, err
+	}
+	decoded, err := unmarshalExtra(extra)
+	if err != nil {
+		return nil, err
+	}
+	pet.ID = uint32(id)
+	pet.OwnerID = uint32(ownerID.Int64)
+	pet.Extra = decoded
+	return &pet, nil
+}
+
+// pqOwnerID converts the OwnerID of a pet to a column value, storing NULL for no owner
+func pqOwnerID(pet *Pet) interface{} {
+	if pet.OwnerID == 0 {
+		return nil
+	}
+	return int64(pet.OwnerID)
+}
+
+// pqSequenceIDs allocates IDs of a resource by querying a sequence with statement
+type pqSequenceIDs struct {
+	db        *sql.DB
+	statement string
+	resource  string
+}
+
+func (p *pqSequenceIDs) NextID(ctx context.Context) (uint32, error) {
+	var id uint32
+	if err := p.db.QueryRowContext(ctx, p.statement).Scan(&id); err != nil {
+		return 0, pqFailure(ctx, err, "Could not allocate a %s ID", p.resource)
+	}
+	return id, nil
+}
+
+// CreateOwner adds a new owner to the store, allocating an ID if the owner's ID is 0
+func (s *PQStore) CreateOwner(ctx context.Context, owner *Owner) error {
+	if owner.ID != 0 {
+		return s.insertOwner(ctx, owner.ID, owner)
+	}
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id, err := s.ownerIDs.NextID(ctx)
+		if err != nil {
+			return err
+		}
+		err = s.insertOwner(ctx, id, owner)
+		if !errors.Is(err, ErrDuplicateSentinel) {
+			return err
+		}
+	}
+	return Errorf(ErrUnknown, "Could not allocate a free owner ID")
+}
+
+// insertOwner inserts an owner with the given ID, setting the ID of the owner on success
+func (s *PQStore) insertOwner(ctx context.Context, id uint32, owner *Owner) error {
+	if _, err := s.db.ExecContext(ctx, pqInsertOwner, int64(id), owner.Name); err != nil {
+		if isSQLState(err, pqUniqueViolation) {
+			return ErrorEf(ErrDuplicate, err, "Owner with id %d already exists", id)
+		}
+		return pqFailure(ctx, err, "Could not create owner with id %d", id)
+	}
+	owner.ID = id
+	return nil
+}
+
+// ReadOwner gets an owner from the store given an ID
+func (s *PQStore) ReadOwner(ctx context.Context, ownerID uint32) (*Owner, error) {
+	var (
+		id    int64
+		owner Owner
+	)
+	err := s.db.QueryRowContext(ctx, pqSelectOwner, int64(ownerID)).Scan(&id, &owner.Name)
+	if err == sql.ErrNoRows {
+		return nil, Errorf(ErrNotFound, "No owner exists with id %d", ownerID)
+	}
+	if err != nil {
+		return nil, pqFailure(ctx, err, "Could not read owner with id %d", ownerID)
+	}
+	owner.ID = uint32(id)
+	return &owner, nil
+}
+
+// UpdateOwner puts new owner data to the store, either creating a new one or overriding an old
+func (s *PQStore) UpdateOwner(ctx context.Context, ownerID uint32, owner *Owner) error {
+	if _, err := s.db.ExecContext(ctx, pqUpsertOwner, int64(ownerID), owner.Name); err != nil {
+		return pqFailure(ctx, err, "Could not update owner with id %d", ownerID)
+	}
+	owner.ID = ownerID
+	return nil
+}
+
+// DeleteOwner deletes an owner from the store, failing with ErrReference while pets reference it
+func (s *PQStore) DeleteOwner(ctx context.Context, ownerID uint32) (bool, error) {
+	result, err := s.db.ExecContext(ctx, pqDeleteOwner, int64(ownerID))
+	if isSQLState(err, pqForeignKeyViolation) {
+		return false, ErrorEf(ErrReference, err, "Owner with id %d still has pets", ownerID)
+	}
+	if err != nil {
+		return false, pqFailure(ctx, err, "Could not delete owner with id %d", ownerID)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, pqFailure(ctx, err, "Could not delete owner with id %d", ownerID)
+	}
+	return deleted > 0, nil
+}
+
+// pqQuerier runs statements either directly on the database or in a transaction
+type pqQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction, committing only if fn succeeds
+func (s *PQStore) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockPet locks the row of a pet for the rest of the transaction and returns
+// the pet with only its ID and version set, or nil if there is no such pet
+func lockPet(ctx context.Context, tx *sql.Tx, petID uint32) (*Pet, error) {
+	pet := &Pet{ID: petID}
+	err := tx.QueryRowContext(ctx, pqLockPet, int64(petID)).Scan(&pet.Version)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pet, nil
+}
+
+// pqFailure describes a failed database operation, as ErrTimeout or ErrCanceled if it
+// failed because ctx is done and as ErrUnknown otherwise
+func pqFailure(ctx context.Context, cause error, format string, args ...interface{}) *Error {
+	if err := ContextError(ctx); err != nil {
+		return err
+	}
+	return ErrorEf(ErrUnknown, cause, format, args...)
+}
+
+// isSQLState reports whether err, or an error it wraps, carries the given postgres
+// SQLSTATE code. Both lib/pq and pgx errors expose the code through a SQLState method.
+func isSQLState(err error, code string) bool {
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && stateErr.SQLState() == code
+}
+
+// marshalExtra converts Extra to a JSONB column value, storing NULL for a nil map
+func marshalExtra(extra map[string]interface{}) (interface{}, error) {
+	if extra == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(extra)
+	if err != nil {
+		return nil, ErrorEf(ErrInvalidInput, err, "Could not encode extra pet data")
+	}
+	return data, nil
+}
+
+// unmarshalExtra converts a JSONB column value back to Extra
+func unmarshalExtra(data []byte) (map[string]interface{}, error) {
+	if data == nil {
+		return nil, nil
+	}
+	var extra map[string]interface{}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return nil, ErrorEf(ErrUnknown, err, "Could not decode extra pet data")
+	}
+	return extra, nil
+}
@@ -0,0 +1,414 @@
+package pet
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lib/pq"
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePQ is a database/sql driver that understands exactly the statements issued by
+// PQStore and keeps rows in memory, so PQStore can be tested without a live database
+type fakePQ struct {
+	sync.Mutex
+	dbs map[string]*fakePQDB
+}
+
+type fakePQDB struct {
+	sync.Mutex
+	// tx is held for the duration of a transaction, standing in for row locks
+	tx      sync.Mutex
+	schema  bool
+	id      int64
+	ownerID int64
+	version int64
+	pets    map[int64]fakePQRow
+	owners  map[int64]string
+}
+
+type fakePQRow struct {
+	name, species, owner string
+	ownerID              interface{}
+	extra                []byte
+	version              int64
+}
+
+var (
+	fakePQDriver       = &fakePQ{dbs: map[string]*fakePQDB{}}
+	fakePQCount        int64
+	fakePQColumns      = []string{"id", "name", "species", "owner", "owner_id", "extra", "version"}
+	fakePQMissingTable = &pq.Error{Code: "42P01", Message: `relation "pets" does not exist`}
+	fakePQForeignKey   = &pq.Error{Code: pqForeignKeyViolation, Message: "violates foreign key constraint"}
+)
+
+func init() {
+	sql.Register("fakepq", fakePQDriver)
+}
+
+// newFakePQStore creates a PQStore on a fresh, empty fake database
+func newFakePQStore() *PQStore {
+	dsn := fmt.Sprintf("fake-%d", atomic.AddInt64(&fakePQCount, 1))
+	store, err := OpenPQStore(PQConfig{Driver: "fakepq", ConnString: dsn})
+	if err != nil {
+		panic(fmt.Errorf("Error in test code, could not open fake database. %v", err))
+	}
+	return store
+}
+
+func (d *fakePQ) Open(dsn string) (driver.Conn, error) {
+	d.Lock()
+	defer d.Unlock()
+	db, ok := d.dbs[dsn]
+	if !ok {
+		db = &fakePQDB{pets: map[int64]fakePQRow{}, owners: map[int64]string{}}
+		d.dbs[dsn] = db
+	}
+	return &fakePQConn{db: db}, nil
+}
+
+type fakePQConn struct {
+	db *fakePQDB
+	// tx is the transaction in progress on the connection, if any
+	tx *fakePQTx
+}
+
+func (c *fakePQConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakePQStmt{db: c.db, conn: c, query: query}, nil
+}
+
+func (c *fakePQConn) Close() error { return nil }
+
+func (c *fakePQConn) Begin() (driver.Tx, error) {
+	c.db.tx.Lock()
+	c.tx = &fakePQTx{conn: c, undo: map[int64]*fakePQRow{}}
+	return c.tx, nil
+}
+
+// fakePQTx undoes the pet writes of a transaction that is rolled back
+type fakePQTx struct {
+	conn *fakePQConn
+	// undo holds the pets written in the transaction as they were before, nil if absent
+	undo map[int64]*fakePQRow
+}
+
+func (t *fakePQTx) Commit() error {
+	t.conn.tx = nil
+	t.conn.db.tx.Unlock()
+	return nil
+}
+
+func (t *fakePQTx) Rollback() error {
+	db := t.conn.db
+	db.Lock()
+	for id, row := range t.undo {
+		if row == nil {
+			delete(db.pets, id)
+		} else {
+			db.pets[id] = *row
+		}
+	}
+	db.Unlock()
+	t.conn.tx = nil
+	db.tx.Unlock()
+	return nil
+}
+
+type fakePQStmt struct {
+	db    *fakePQDB
+	conn  *fakePQConn
+	query string
+}
+
+// touch records the pet with the given ID before it is written, so that a
+// transaction in progress can undo the write. The database must be locked.
+func (s *fakePQStmt) touch(id int64) {
+	tx := s.conn.tx
+	if tx == nil {
+		return
+	}
+	if _, ok := tx.undo[id]; ok {
+		return
+	}
+	if row, ok := s.db.pets[id]; ok {
+		tx.undo[id] = &row
+	} else {
+		tx.undo[id] = nil
+	}
+}
+
+func (s *fakePQStmt) Close() error  { return nil }
+func (s *fakePQStmt) NumInput() int { return -1 }
+
+func (s *fakePQStmt) Exec(args []driver.Value) (driver.Result, error) {
+	db := s.db
+	db.Lock()
+	defer db.Unlock()
+	for _, statement := range pqSchema {
+		if s.query == statement {
+			db.schema = true
+			return driver.RowsAffected(0), nil
+		}
+	}
+	if !db.schema {
+		return nil, fakePQMissingTable
+	}
+	switch s.query {
+	case pqDeletePet:
+		id := args[0].(int64)
+		if _, ok := db.pets[id]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		s.touch(id)
+		delete(db.pets, id)
+		return driver.RowsAffected(1), nil
+	case pqInsertOwner, pqUpsertOwner:
+		id := args[0].(int64)
+		if _, exists := db.owners[id]; exists && s.query == pqInsertOwner {
+			return nil, &pq.Error{Code: pqUniqueViolation, Message: "duplicate key value violates unique constraint"}
+		}
+		db.owners[id] = args[1].(string)
+		return driver.RowsAffected(1), nil
+	case pqDeleteOwner:
+		id := args[0].(int64)
+		if _, ok := db.owners[id]; !ok {
+			return driver.RowsAffected(0), nil
+		}
+		for _, row := range db.pets {
+			if row.ownerID == id {
+				return nil, fakePQForeignKey
+			}
+		}
+		delete(db.owners, id)
+		return driver.RowsAffected(1), nil
+	}
+	return nil, fmt.Errorf("fakepq: unsupported exec %q", s.query)
+}
+
+func (s *fakePQStmt) Query(args []driver.Value) (driver.Rows, error) {
+	db := s.db
+	db.Lock()
+	defer db.Unlock()
+	if !db.schema {
+		return nil, fakePQMissingTable
+	}
+	if strings.HasPrefix(s.query, pqSelectPets+pqListFilter) {
+		return db.list(s.query, args), nil
+	}
+	switch s.query {
+	case pqNextID:
+		db.id++
+		return &fakePQRows{columns: []string{"nextval"}, values: [][]driver.Value{{db.id}}}, nil
+	case pqNextOwnerID:
+		db.ownerID++
+		return &fakePQRows{columns: []string{"nextval"}, values: [][]driver.Value{{db.ownerID}}}, nil
+	case pqSelectOwner:
+		id := args[0].(int64)
+		rows := &fakePQRows{columns: []string{"id", "name"}}
+		if name, ok := db.owners[id]; ok {
+			rows.values = append(rows.values, []driver.Value{id, name})
+		}
+		return rows, nil
+	case pqInsertPet, pqUpsertPet, pqUpdatePet:
+		id := args[0].(int64)
+		_, exists := db.pets[id]
+		if exists && s.query == pqInsertPet {
+			return nil, &pq.Error{Code: pqUniqueViolation, Message: "duplicate key value violates unique constraint"}
+		}
+		row := fakePQRowFromArgs(args[1:])
+		if ownerID, ok := row.ownerID.(int64); ok {
+			if _, ok = db.owners[ownerID]; !ok {
+				return nil, fakePQForeignKey
+			}
+		}
+		rows := &fakePQRows{columns: []string{"version"}}
+		if exists || s.query != pqUpdatePet {
+			s.touch(id)
+			db.version++
+			row.version = db.version
+			db.pets[id] = row
+			rows.values = append(rows.values, []driver.Value{row.version})
+		}
+		return rows, nil
+	case pqLockPet:
+		rows := &fakePQRows{columns: []string{"version"}}
+		if row, ok := db.pets[args[0].(int64)]; ok {
+			rows.values = append(rows.values, []driver.Value{row.version})
+		}
+		return rows, nil
+	case pqSelectPet:
+		id := args[0].(int64)
+		rows := &fakePQRows{columns: fakePQColumns}
+		if row, ok := db.pets[id]; ok {
+			rows.values = append(rows.values, row.values(id))
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("fakepq: unsupported query %q", s.query)
+}
+
+var fakePQListPattern = regexp.MustCompile(`ORDER BY (\w+) (ASC|DESC).* LIMIT (\d+)$`)
+
+// list evaluates a statement built by pqListPets
+func (db *fakePQDB) list(query string, args []driver.Value) driver.Rows {
+	match := fakePQListPattern.FindStringSubmatch(query)
+	column, desc := match[1], match[2] == "DESC"
+	limit, _ := strconv.Atoi(match[3])
+	key := func(id int64) string {
+		row := db.pets[id]
+		return map[string]string{"name": row.name, "species": row.species, "owner": row.owner}[column]
+	}
+	before := func(keyA string, idA int64, keyB string, idB int64) bool {
+		if keyA == keyB {
+			return (idA < idB) != desc
+		}
+		return (keyA < keyB) != desc
+	}
+	owner, species, ownerID := args[0].(string), args[1].(string), args[3].(int64)
+	prefix := strings.NewReplacer(`\%`, "%", `\_`, "_", `\\`, `\`).Replace(strings.TrimSuffix(args[2].(string), "%"))
+	var ids []int64
+	for id, row := range db.pets {
+		if (owner != "" && row.owner != owner) || (species != "" && row.species != species) || !strings.HasPrefix(row.name, prefix) {
+			continue
+		}
+		if ownerID != 0 && row.ownerID != ownerID {
+			continue
+		}
+		if len(args) == 5 && !before(key(args[4].(int64)), args[4].(int64), key(id), id) {
+			continue
+		}
+		if len(args) == 6 && !before(args[4].(string), args[5].(int64), key(id), id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return before(key(ids[i]), ids[i], key(ids[j]), ids[j])
+	})
+	rows := &fakePQRows{columns: fakePQColumns}
+	for i, id := range ids {
+		if i == limit {
+			break
+		}
+		rows.values = append(rows.values, db.pets[id].values(id))
+	}
+	return rows
+}
+
+func fakePQRowFromArgs(args []driver.Value) fakePQRow {
+	row := fakePQRow{
+		name:    args[0].(string),
+		species: args[1].(string),
+		owner:   args[2].(string),
+		ownerID: args[4],
+	}
+	if args[3] != nil {
+		row.extra = args[3].([]byte)
+	}
+	return row
+}
+
+// values returns the row as selected by pqSelectPets
+func (row fakePQRow) values(id int64) []driver.Value {
+	return []driver.Value{id, row.name, row.species, row.owner, row.ownerID, row.extra, row.version}
+}
+
+type fakePQRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakePQRows) Columns() []string { return r.columns }
+func (r *fakePQRows) Close() error      { return nil }
+
+func (r *fakePQRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestPQStoreErrorCodes(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := newFakePQStore()
+	testPet := pet1000()
+	require.NoError(t, store.CreatePet(context.Background(), &testPet), "Error in test code, could not add initial data to test")
+
+	// when
+	duplicateErr := store.CreatePet(context.Background(), &testPet)
+	_, notFoundErr := store.ReadPet(context.Background(), 1001)
+
+	// then
+	if assert.IsType(&Error{}, duplicateErr, "Duplicate create should return a pet Error") {
+		assert.Equal(ErrDuplicate, duplicateErr.(*Error).Code, "Unique violation should map to ErrDuplicate")
+	}
+	if assert.IsType(&Error{}, notFoundErr, "Missing pet should return a pet Error") {
+		assert.Equal(ErrNotFound, notFoundErr.(*Error).Code, "No rows should map to ErrNotFound")
+	}
+}
+
+func TestPQStoreRequiresSchema(t *testing.T) {
+	// given
+	db, err := sql.Open("fakepq", "no-schema")
+	require.NoError(t, err, "Error in test code, could not open fake database")
+	store := NewPQStore(db)
+	testPet := pet1000()
+
+	// when
+	err = store.CreatePet(context.Background(), &testPet)
+
+	// then
+	if tassert.IsType(t, &Error{}, err, "Writing without a schema should return a pet Error") {
+		tassert.Equal(t, ErrUnknown, err.(*Error).Code, "Unexpected database errors should map to ErrUnknown")
+	}
+}
+
+func TestPQStoreCreateHonoursCanceledContext(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := newFakePQStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	canceled := &Pet{Name: "Nemo", Species: "Clownfish"}
+	created := &Pet{Name: "Dory", Species: "Blue tang"}
+
+	// when
+	canceledErr := store.CreatePet(ctx, canceled)
+	createdErr := store.CreatePet(context.Background(), created)
+
+	// then
+	var petErr *Error
+	if assert.True(errors.As(canceledErr, &petErr), "%v should be a pet Error", canceledErr) {
+		assert.Equal(ErrCanceled, petErr.Code)
+	}
+	assert.NoError(createdErr)
+	assert.Equal(uint32(1), created.ID, "Canceled create should not allocate an ID from the sequence")
+}
+
+func TestIsSQLState_Wrapped(t *testing.T) {
+	// given
+	wrapped := fmt.Errorf("insert failed: %w", &pq.Error{Code: pqUniqueViolation})
+
+	// when
+	unique := isSQLState(wrapped, pqUniqueViolation)
+	foreignKey := isSQLState(wrapped, pqForeignKeyViolation)
+
+	// then
+	tassert.True(t, unique, "SQLSTATE of a wrapped driver error should be recognised")
+	tassert.False(t, foreignKey, "Other SQLSTATE codes should not match")
+}
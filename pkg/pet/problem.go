@@ -0,0 +1,70 @@
+package pet
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// problemType is the media type of RFC 7807 problem details
+const problemType = "application/problem+json"
+
+// problemTypeBase prefixes the error code name to form the problem type URI
+const problemTypeBase = "urn:samplerest:problem:"
+
+// Problem is the body of an error response, following RFC 7807
+type Problem struct {
+	// Type identifies the kind of problem, a URN ending in Code
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	// Detail is the public message of the error
+	Detail string `json:"detail,omitempty"`
+	// Instance is the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+	// Code is the machine readable name of the pet Error code
+	Code string `json:"code"`
+	// Errors lists the invalid fields of an invalid input problem
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// newProblem describes an error that occurred while handling a request.
+// Only the message of a pet Error is disclosed, other errors may hold internal details.
+func newProblem(r *http.Request, err error) *Problem {
+	problem := &Problem{
+		Status:   http.StatusInternalServerError,
+		Detail:   "Internal server error",
+		Instance: r.URL.Path,
+		Code:     ErrorCodeName(ErrUnknown),
+	}
+	// pet service Errors store more specific response information
+	var specificError *Error
+	if errors.As(err, &specificError) {
+		problem.Detail = specificError.Message
+		problem.Code = ErrorCodeName(specificError.Code)
+		problem.Errors = specificError.Fields
+		// Attempt to get a more specific status code
+		if status, ok := errStatusMap[specificError.Code]; ok {
+			problem.Status = status
+		}
+	}
+	problem.Type = problemTypeBase + problem.Code
+	problem.Title = http.StatusText(problem.Status)
+	if problem.Status == statusClientClosedRequest {
+		problem.Title = "Client Closed Request"
+	}
+	return problem
+}
+
+// renderProblem writes problem details with the given content type
+func renderProblem(w http.ResponseWriter, contentType string, problem *Problem) {
+	body, err := json.Marshal(problem)
+	if err != nil {
+		http.Error(w, problem.Detail, problem.Status)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	w.Write(body)
+}
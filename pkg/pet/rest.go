@@ -2,9 +2,10 @@ package pet
 
 import (
 	"context"
-	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"mime"
 	"net/http"
+	"path"
 	"strconv"
 
 	"github.com/go-chi/chi"
@@ -15,29 +16,48 @@ type contextKey int
 
 const (
 	idKey contextKey = iota
+	// loggerKey holds the logger of a request, see RequestLogger
+	loggerKey
 )
 
 // maps from internal errors to response status codes
 // renderErrorResponse defaults to internal server error
 // if a specific error code is not defined.
 var errStatusMap = map[int]int{
-	ErrInvalidInput: http.StatusBadRequest,
-	ErrNotFound:     http.StatusNotFound,
-}
-
-// renderErrorResponse handles http responses in the case of an error
-func renderErrorResponse(w http.ResponseWriter, err error) {
-	message := err.Error()
-	responseStatus := http.StatusInternalServerError
-	// pet service Errors store more specific response information
-	if specificError, ok := err.(*Error); ok {
-		message = specificError.Message
-		// Attempt to get a more specific status code
-		if status, ok := errStatusMap[specificError.Code]; ok {
-			responseStatus = status
-		}
+	ErrUnknown:              http.StatusInternalServerError,
+	ErrInvalidInput:         http.StatusBadRequest,
+	ErrDuplicate:            http.StatusConflict,
+	ErrNotFound:             http.StatusNotFound,
+	ErrConflict:             http.StatusPreconditionFailed,
+	ErrUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	ErrCanceled:             statusClientClosedRequest,
+	ErrTimeout:              http.StatusGatewayTimeout,
+	ErrReference:            http.StatusUnprocessableEntity,
+	ErrUnsupported:          http.StatusNotImplemented,
+	ErrTooLarge:             http.StatusRequestEntityTooLarge,
+	ErrNotAcceptable:        http.StatusNotAcceptable,
+	ErrPatchTestFailed:      http.StatusConflict,
+}
+
+// statusClientClosedRequest is the non-standard status logged for requests abandoned by the client
+const statusClientClosedRequest = 499
+
+// renderErrorResponse handles http responses in the case of an error.
+// The error is rendered as RFC 7807 problem details, or as plain text
+// if the client prefers it according to the Accept header, so the response varies with Accept.
+// The error and its causes are logged, only the public message is returned.
+func renderErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	problem := newProblem(r, err)
+	logError(r, problem, err)
+	w.Header().Add("Vary", "Accept")
+	switch negotiate(r.Header.Get("Accept"), problemType, "application/json", "text/plain") {
+	case "text/plain":
+		http.Error(w, problem.Detail, problem.Status)
+	case "application/json":
+		renderProblem(w, "application/json", problem)
+	default:
+		renderProblem(w, problemType, problem)
 	}
-	http.Error(w, message, responseStatus)
 }
 
 // urlParamContextSaverMiddleware is a middleware that extracts a url parameter on an path
@@ -54,12 +74,15 @@ func urlParamContextSaverMiddleware(urlParam string, id contextKey) func(http.Ha
 
 // SetupRoutes sets up pet service routes for the given router
 func SetupRoutes(r chi.Router, s *Service) {
+	r.Post("/api/pet:batch", s.BatchPets)
 	r.Route("/api/pet", func(r chi.Router) {
+		r.Get("/", s.ListPets)
 		r.Post("/", s.PostPet)
 		r.Route("/{id}", func(r chi.Router) {
 			r.Use(urlParamContextSaverMiddleware("id", idKey))
 			r.Get("/", s.GetPet)
 			r.Put("/", s.PutPet)
+			r.Patch("/", s.PatchPet)
 			r.Delete("/", s.DeletePet)
 		})
 	})
@@ -68,6 +91,12 @@ func SetupRoutes(r chi.Router, s *Service) {
 // Service defines a rest api for interaction with a PetStorer
 type Service struct {
 	store Storer
+	// Validator checks pets before they are written, nil applies the default rules
+	Validator *Validator
+	// Body controls how request bodies are read
+	Body BodyConfig
+	// Codecs reads and writes pets in the media types of requests, nil uses DefaultCodecs
+	Codecs *Codecs
 }
 
 // NewPetService creates a new pet service with an in-memory store
@@ -80,67 +109,170 @@ func NewPetService(storer Storer) *Service {
 // These functions take a request and return the appropriate response and status code
 // In the case these return an error, the error will be passed
 
-// GetPet handles a GET request to retrieve a pet
+// GetPet handles a GET request to retrieve a pet, in the media type negotiated with
+// the Accept header
 func (ps *Service) GetPet(w http.ResponseWriter, r *http.Request) {
 	petID, err := readPetID(r)
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
+		return
+	}
+	pet, err := ps.store.ReadPet(r.Context(), petID)
+	if err != nil {
+		renderErrorResponse(w, r, err)
 		return
 	}
-	pet, err := ps.store.ReadPet(petID)
+	codec, err := ps.Codecs.orDefault().forAccept(r)
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
 		return
 	}
+	w.Header().Set("ETag", formatETag(pet.Version))
 	render.Status(r, http.StatusOK)
-	render.JSON(w, r, pet)
+	renderPet(w, r, codec, pet)
 }
 
-// PostPet handles a POST request to add a new pet
+// PostPet handles a POST request to add a new pet.
+// The store allocates an ID if the pet has none, and the created pet is returned.
+// The media type of the response is negotiated before the pet is written.
 func (ps *Service) PostPet(w http.ResponseWriter, r *http.Request) {
-	newPet, err := readPetBody(r)
+	codec, err := ps.Codecs.orDefault().forAccept(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	newPet, err := ps.readPetBody(r)
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if err = ps.Validator.Validate(newPet); err != nil {
+		renderErrorResponse(w, r, err)
 		return
 	}
 
-	if err = ps.store.CreatePet(newPet); err != nil {
-		renderErrorResponse(w, err)
+	if err = ps.store.CreatePet(r.Context(), newPet); err != nil {
+		renderErrorResponse(w, r, err)
 		return
 	}
+	w.Header().Set("Location", path.Join(r.URL.Path, strconv.FormatUint(uint64(newPet.ID), 10)))
+	w.Header().Set("ETag", formatETag(newPet.Version))
 	render.Status(r, http.StatusCreated)
-	render.JSON(w, r, nil)
+	renderPet(w, r, codec, newPet)
 }
 
-// PutPet handles a PUT request to create or modify a pet
+// PutPet handles a PUT request to create or modify a pet, returning the stored pet.
+// The write is made conditional with the If-Match and If-None-Match headers.
+// A pet ID in the body must match the ID in the URL. The body may be in any media type
+// of the codecs, and the media type of the response is negotiated before the pet is written.
 func (ps *Service) PutPet(w http.ResponseWriter, r *http.Request) {
 	petID, err := readPetID(r)
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
 		return
 	}
-	pet, err := readPetBody(r)
+	codec, err := ps.Codecs.orDefault().forAccept(r)
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
+		return
+	}
+	pet, err := ps.readPetBody(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if err = matchBodyID(petID, &pet.ID, "pet"); err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	if err = ps.Validator.Validate(pet); err != nil {
+		renderErrorResponse(w, r, err)
 		return
 	}
-	if err = ps.store.UpdatePet(petID, pet); err != nil {
-		renderErrorResponse(w, err)
+	if err = ps.store.UpdatePet(r.Context(), petID, pet, readPrecondition(r)); err != nil {
+		renderErrorResponse(w, r, err)
+		return
 	}
+	w.Header().Set("ETag", formatETag(pet.Version))
 	render.Status(r, http.StatusCreated)
-	render.JSON(w, r, nil)
+	renderPet(w, r, codec, pet)
 }
 
-// DeletePet handles a DELETE request to delete a pet
+// PatchPet handles a PATCH request to modify part of a pet, with the request body
+// either a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902).
+// The patch is applied to the latest version of the pet and written back only if the
+// pet was not modified in between, retrying a limited number of times otherwise.
+func (ps *Service) PatchPet(w http.ResponseWriter, r *http.Request) {
+	petID, err := readPetID(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	codec, err := ps.Codecs.orDefault().forAccept(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	patch, err := ps.readPetPatch(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	pre := readPrecondition(r)
+	var (
+		pet   *Pet
+		raced = true
+	)
+	for attempt := 0; raced && attempt < maxPatchAttempts; attempt++ {
+		pet, raced, err = ps.patchPet(r.Context(), petID, patch, pre)
+	}
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", formatETag(pet.Version))
+	render.Status(r, http.StatusOK)
+	renderPet(w, r, codec, pet)
+}
+
+// maxPatchAttempts limits how often PatchPet retries when a pet is concurrently modified
+const maxPatchAttempts = 5
+
+// patchPet applies a patch to the current version of a pet, reporting whether
+// it failed because the pet was modified between reading and writing it
+func (ps *Service) patchPet(ctx context.Context, petID uint32, patch petPatch, pre Precondition) (*Pet, bool, error) {
+	current, err := ps.store.ReadPet(ctx, petID)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = pre.Check(petID, current); err != nil {
+		return nil, false, err
+	}
+	patched, err := applyPetPatch(current, patch)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = ps.Validator.Validate(patched); err != nil {
+		return nil, false, err
+	}
+	err = ps.store.UpdatePet(ctx, petID, patched, Precondition{IfMatch: []uint64{current.Version}})
+	if err != nil {
+		return nil, errors.Is(err, ErrConflictSentinel), err
+	}
+	return patched, false, nil
+}
+
+// DeletePet handles a DELETE request to delete a pet.
+// The delete is made conditional with the If-Match and If-None-Match headers.
 func (ps *Service) DeletePet(w http.ResponseWriter, r *http.Request) {
 	petID, err := readPetID(r)
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
 		return
 	}
-	petDeleted, err := ps.store.DeletePet(petID)
+	petDeleted, err := ps.store.DeletePet(r.Context(), petID, readPrecondition(r))
 	if err != nil {
-		renderErrorResponse(w, err)
+		renderErrorResponse(w, r, err)
 		return
 	}
 	if petDeleted {
@@ -151,30 +283,100 @@ func (ps *Service) DeletePet(w http.ResponseWriter, r *http.Request) {
 	render.JSON(w, r, nil)
 }
 
+// ListPets handles a GET request to list pets, filtered by the owner, owner_id, species
+// and name_prefix query parameters and paged using the limit and cursor parameters
+func (ps *Service) ListPets(w http.ResponseWriter, r *http.Request) {
+	query, err := readListQuery(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	page, err := ps.store.ListPets(r.Context(), query)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	codec, err := ps.Codecs.orDefault().forAccept(r)
+	if err != nil {
+		renderErrorResponse(w, r, err)
+		return
+	}
+	render.Status(r, http.StatusOK)
+	renderPage(w, r, codec, page)
+}
+
 func readPetID(r *http.Request) (uint32, error) {
 	petID := r.Context().Value(idKey)
 	if petID == nil {
 		// Reaching this indicates a bug. At this point, request context should contain an id
 		return uint32(ErrUnknown), Errorf(ErrUnknown, "pet ID was lost somewhere")
 	}
-	intID, err := strconv.ParseInt(petID.(string), 10, 32)
+	// IDs are unsigned 32 bit, random and snowflake IDs are often 2^31 or more
+	intID, err := strconv.ParseUint(petID.(string), 10, 32)
 	if err != nil {
-		return uint32(ErrInvalidInput), Errorf(ErrInvalidInput, "Invalid pet ID %v. ID should be a number", petID)
+		return uint32(ErrInvalidInput), Errorf(ErrInvalidInput, "Invalid pet ID %v. ID should be a number", petID).
+			AddField("id", "should be a number")
 	}
 	return uint32(intID), nil
 }
 
-func readPetBody(r *http.Request) (*Pet, error) {
+func (ps *Service) readPetBody(r *http.Request) (*Pet, error) {
+	return ps.Body.decodePet(r, ps.Codecs.orDefault())
+}
+
+// matchBodyID checks that an ID given in the body of a request matches the ID in its
+// URL, setting the body ID to the URL ID if the body has none
+func matchBodyID(urlID uint32, bodyID *uint32, kind string) error {
+	if *bodyID != 0 && *bodyID != urlID {
+		return Errorf(ErrInvalidInput, "The %s ID %d in the body does not match the ID %d in the URL", kind, *bodyID, urlID).
+			AddField("id", "should match the ID in the URL")
+	}
+	*bodyID = urlID
+	return nil
+}
+
+func (ps *Service) readPetPatch(r *http.Request) (petPatch, error) {
 	if r.Body == nil {
 		return nil, Errorf(ErrInvalidInput, "No request body")
 	}
-	petData, err := ioutil.ReadAll(r.Body)
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchType && mediaType != jsonPatchType) {
+		return nil, Errorf(ErrUnsupportedMediaType, "Patch Content-Type should be either %s or %s", mergePatchType, jsonPatchType)
+	}
+	patchData, err := ps.Body.read(r)
 	if err != nil {
-		return nil, ErrorEf(ErrInvalidInput, err, "Bad request body")
+		return nil, err
+	}
+	if mediaType == mergePatchType {
+		return newMergePatch(patchData)
 	}
-	var pet Pet
-	if err = json.Unmarshal(petData, &pet); err != nil {
-		return nil, ErrorEf(ErrInvalidInput, err, "Invalid pet data")
+	return newJSONPatch(patchData)
+}
+
+func readListQuery(r *http.Request) (ListQuery, error) {
+	params := r.URL.Query()
+	query := ListQuery{
+		Owner:      params.Get("owner"),
+		Species:    params.Get("species"),
+		NamePrefix: params.Get("name_prefix"),
+		Sort:       params.Get("sort"),
+		Cursor:     params.Get("cursor"),
+	}
+	if limit := params.Get("limit"); limit != "" {
+		intLimit, err := strconv.Atoi(limit)
+		if err != nil {
+			return query, Errorf(ErrInvalidInput, "Invalid limit %v. Limit should be a number", limit).
+				AddField("limit", "should be a number")
+		}
+		query.Limit = intLimit
+	}
+	if ownerID := params.Get("owner_id"); ownerID != "" {
+		intOwnerID, err := strconv.ParseUint(ownerID, 10, 32)
+		if err != nil {
+			return query, Errorf(ErrInvalidInput, "Invalid owner_id %v. Owner ID should be a number", ownerID).
+				AddField("owner_id", "should be a number")
+		}
+		query.OwnerID = uint32(intOwnerID)
 	}
-	return &pet, nil
+	return query, nil
 }
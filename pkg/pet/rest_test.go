@@ -2,12 +2,16 @@ package pet
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi"
 
@@ -60,7 +64,7 @@ func (p *petServiceConfig) TestGetPetSuccessful() {
 	// given
 	assert := tassert.New(p.T())
 	testPet := pet1000()
-	err := p.service.store.CreatePet(&testPet)
+	err := p.service.store.CreatePet(context.Background(), &testPet)
 	assert.NoError(err, "Error initializing store. Check memstore errors")
 
 	req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
@@ -112,6 +116,93 @@ func (p *petServiceConfig) TestGetPet_InvalidID() {
 	assert.NotEmpty(body, "Body should be empty")
 }
 
+func (p *petServiceConfig) TestGetPet_LargeID() {
+	// given
+	assert := tassert.New(p.T())
+	testPet := pet1000()
+	testPet.ID = 3000000000
+	err := p.service.store.CreatePet(context.Background(), &testPet)
+	assert.NoError(err, "Error initializing store. Check memstore errors")
+	req, _ := http.NewRequest("GET", "/api/pet/3000000000", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "IDs of 2^31 or more should be readable")
+	var retrievedPet Pet
+	assert.NoError(json.Unmarshal(resp.Body.Bytes(), &retrievedPet), "Body should be able to unmarshal to a pet struct")
+	assert.Equal(uint32(3000000000), retrievedPet.ID)
+}
+
+func (p *petServiceConfig) TestGetPet_OutOfRangeID() {
+	assert := tassert.New(p.T())
+	for _, id := range []string{"-1", "4294967296"} {
+		// given
+		req, _ := http.NewRequest("GET", "/api/pet/"+id, nil)
+		resp := httptest.NewRecorder()
+
+		// when
+		p.router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(http.StatusBadRequest, resp.Code, "Pet ID %s should be rejected with 400 Bad Request", id)
+		assert.Contains(resp.Body.String(), `"field":"id"`, "Pet ID %s should be named as the invalid field", id)
+	}
+}
+
+func (p *petServiceConfig) TestErrorResponse_ProblemDetails() {
+	// given
+	assert := tassert.New(p.T())
+	req, _ := http.NewRequest("GET", "/api/pet/111x", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusBadRequest, resp.Code, "Response status should be 400 Bad Request")
+	assert.Equal("application/problem+json", resp.Header().Get("Content-Type"), "Errors should be problem details by default")
+	var problem Problem
+	if assert.NoError(json.Unmarshal(resp.Body.Bytes(), &problem), "Body should unmarshal to problem details") {
+		assert.Equal(Problem{
+			Type:     "urn:samplerest:problem:invalid_input",
+			Title:    "Bad Request",
+			Status:   http.StatusBadRequest,
+			Detail:   "Invalid pet ID 111x. ID should be a number",
+			Instance: "/api/pet/111x",
+			Code:     "invalid_input",
+			Errors:   []FieldError{{Field: "id", Message: "should be a number"}},
+		}, problem, "Problem details should describe the invalid ID")
+	}
+}
+
+func (p *petServiceConfig) TestErrorResponse_Negotiation() {
+	assert := tassert.New(p.T())
+	cases := []struct{ accept, contentType string }{
+		{"text/plain", "text/plain; charset=utf-8"},
+		{"text/*;q=0.9, application/json;q=0.5", "text/plain; charset=utf-8"},
+		{"application/json", "application/json"},
+		{"application/*", "application/problem+json"},
+		{"image/png", "application/problem+json"},
+	}
+	for _, c := range cases {
+		// given
+		req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+		req.Header.Set("Accept", c.accept)
+		resp := httptest.NewRecorder()
+
+		// when
+		p.router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(http.StatusNotFound, resp.Code, "Response status should be 404 Not Found for Accept %s", c.accept)
+		assert.Equal(c.contentType, resp.Header().Get("Content-Type"), "Unexpected error content type for Accept %s", c.accept)
+		assert.Contains(resp.Body.String(), "No pet exists with id 1000", "Error body should hold the message for Accept %s", c.accept)
+	}
+}
+
 func (p *petServiceConfig) TestPostPetSuccessful() {
 	// given
 	assert := tassert.New(p.T())
@@ -135,11 +226,40 @@ func (p *petServiceConfig) TestPostPetSuccessful() {
 	assert.NotEmpty(responseBody, "Body should be empty")
 }
 
+func (p *petServiceConfig) TestPostPet_AllocatesID() {
+	// given
+	assert := tassert.New(p.T())
+	testPet := pet1000()
+	testPet.ID = 0
+	requestBody, err := json.Marshal(testPet)
+	if err != nil {
+		panic(fmt.Errorf("Error in test code, could not marshal testPet to json. %v", err))
+	}
+	req, _ := http.NewRequest("POST", "/api/pet", bytes.NewBuffer(requestBody))
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusCreated, resp.Code, "Response status should be 201 Created")
+	var createdPet Pet
+	if !assert.NoError(json.Unmarshal(resp.Body.Bytes(), &createdPet), "Body should hold the created pet") {
+		return
+	}
+	assert.NotZero(createdPet.ID, "Created pet should be given an ID")
+	assert.Equal(fmt.Sprintf("/api/pet/%d", createdPet.ID), resp.Header().Get("Location"), "Location should point to the created pet")
+	storedPet, err := p.service.store.ReadPet(context.Background(), createdPet.ID)
+	if assert.NoError(err, "Created pet should be retrievable") {
+		assert.Equal(&createdPet, storedPet, "Body should hold the stored pet")
+	}
+}
+
 func (p *petServiceConfig) TestPostPet_PetWithIDAlreadyExists() {
 	// given
 	assert := tassert.New(p.T())
 	testPet := pet1000()
-	if err := p.service.store.CreatePet(&testPet); err != nil {
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
 		panic("Erro in test code, could not add initial data to test.")
 	}
 
@@ -155,18 +275,97 @@ func (p *petServiceConfig) TestPostPet_PetWithIDAlreadyExists() {
 	p.router.ServeHTTP(resp, req)
 
 	// then
-	assert.Equal(http.StatusInternalServerError, resp.Code, "Response status should be 500 Internal Server Error")
+	assert.Equal(http.StatusConflict, resp.Code, "Response status should be 409 Conflict")
 	responseBody, err := ioutil.ReadAll(resp.Body)
 	assert.NoError(err, "Body should be readable")
 	assert.NotEmpty(responseBody, "Body should be empty")
 }
 
+func (p *petServiceConfig) TestErrorResponse_WrappedAndInternalErrors() {
+	assert := tassert.New(p.T())
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"wrapped pet error", fmt.Errorf("reading pet: %w", ErrorEf(ErrNotFound, errors.New("secret cause"), "No pet exists with id 1000")),
+			http.StatusNotFound, "No pet exists with id 1000"},
+		{"pet error with a cause", ErrorEf(ErrUnknown, errors.New("dial tcp 10.0.0.1:5432: secret cause"), "Could not read pet"),
+			http.StatusInternalServerError, "Could not read pet"},
+		{"internal error", errors.New("pq: password authentication failed, secret cause"),
+			http.StatusInternalServerError, "Internal server error"},
+	}
+	for _, c := range cases {
+		// given
+		p.service.store = &failingStore{MemStore: NewMemStore(), err: c.err}
+		req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+		resp := httptest.NewRecorder()
+
+		// when
+		p.router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(c.status, resp.Code, "Unexpected response status for %s", c.name)
+		var problem Problem
+		if assert.NoError(json.Unmarshal(resp.Body.Bytes(), &problem), "Body should unmarshal to problem details for %s", c.name) {
+			assert.Equal(c.detail, problem.Detail, "Unexpected problem detail for %s", c.name)
+		}
+		assert.NotContains(resp.Body.String(), "secret cause", "Error causes should not be disclosed for %s", c.name)
+	}
+}
+
+func (p *petServiceConfig) TestErrorResponse_RequestContextDone() {
+	assert := tassert.New(p.T())
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		code   string
+		title  string
+	}{
+		{"expired deadline", expired, http.StatusGatewayTimeout, "timeout", "Gateway Timeout"},
+		{"canceled request", canceled, statusClientClosedRequest, "canceled", "Client Closed Request"},
+	}
+	for _, c := range cases {
+		// given
+		req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+		req = req.WithContext(c.ctx)
+		resp := httptest.NewRecorder()
+
+		// when
+		p.router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(c.status, resp.Code, "Unexpected response status for %s", c.name)
+		var problem Problem
+		if assert.NoError(json.Unmarshal(resp.Body.Bytes(), &problem), "Body should unmarshal to problem details for %s", c.name) {
+			assert.Equal(c.code, problem.Code, "Unexpected problem code for %s", c.name)
+			assert.Equal(c.title, problem.Title, "Unexpected problem title for %s", c.name)
+		}
+	}
+}
+
+// failingStore is a Storer whose reads fail with a fixed error
+type failingStore struct {
+	*MemStore
+	err error
+}
+
+func (f *failingStore) ReadPet(context.Context, uint32) (*Pet, error) {
+	return nil, f.err
+}
+
 func (p *petServiceConfig) TestPutPetSuccessful() {
 	// given
 	assert := tassert.New(p.T())
 	testPet := pet1000()
 	testModifiedPet := modifiedPet1000()
-	if err := p.service.store.CreatePet(&testPet); err != nil {
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
 		panic("Error in test code, could not add initial data to test.")
 	}
 
@@ -187,8 +386,9 @@ func (p *petServiceConfig) TestPutPetSuccessful() {
 	assert.NoError(err, "Response body should be readable")
 	assert.NotEmpty(responseBody, "response body should not be empty")
 
-	newPet, err := p.service.store.ReadPet(1000)
+	newPet, err := p.service.store.ReadPet(context.Background(), 1000)
 	assert.NoError(err, "Pet with id 1000 should be retrievable")
+	testModifiedPet.Version = 2
 	assert.Equal(&testModifiedPet, newPet, "Pet with ID 1000 should be modified to be identical to testModifiedPet")
 }
 
@@ -197,7 +397,7 @@ func (p *petServiceConfig) TestPutPet_CreatesNewPetIfNoneExist() {
 	assert := tassert.New(p.T())
 	testPet := pet1000()
 	testNewPet := pet1001()
-	if err := p.service.store.CreatePet(&testPet); err != nil {
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
 		panic("Error in test code, could not add initial data to test")
 	}
 
@@ -206,7 +406,7 @@ func (p *petServiceConfig) TestPutPet_CreatesNewPetIfNoneExist() {
 		panic(fmt.Sprintf("Error in test code, could not marshal pet1001 to json. %v", err))
 	}
 	// Check no pet currently exists at id 1001
-	if pet, _ := p.service.store.ReadPet(1001); pet != nil {
+	if pet, _ := p.service.store.ReadPet(context.Background(), 1001); pet != nil {
 		panic("Error in test code, No pet with ID 1001 should exist")
 	}
 
@@ -222,16 +422,131 @@ func (p *petServiceConfig) TestPutPet_CreatesNewPetIfNoneExist() {
 	assert.NoError(err, "Response body should be readable")
 	assert.NotEmpty(responseBody, "response body should not be empty")
 
-	newPet, err := p.service.store.ReadPet(1001)
+	newPet, err := p.service.store.ReadPet(context.Background(), 1001)
 	assert.NoError(err, "Pet with ID 1001 should be retrievable")
+	testNewPet.Version = 2
 	assert.Equal(&testNewPet, newPet, "Pet with ID 1001 should be identical to pet in request payload")
 }
 
+func (p *petServiceConfig) TestPatchPet() {
+	assert := tassert.New(p.T())
+	cases := []struct {
+		name        string
+		contentType string
+		patch       string
+		status      int
+		expected    func() Pet
+	}{
+		{"merge patch of owner and extra", "application/merge-patch+json",
+			`{"owner":"Penny","extra":{"food":null,"walks":{"daily":2}}}`, http.StatusOK,
+			func() Pet {
+				pet := pet1001()
+				pet.Owner = "Penny"
+				pet.Extra = map[string]interface{}{"walks": map[string]interface{}{"daily": 2.0}}
+				return pet
+			}},
+		{"JSON patch deep into extra", "application/json-patch+json",
+			`[{"op":"test","path":"/extra/food","value":"meat"},{"op":"add","path":"/extra/toys","value":["ball"]},{"op":"add","path":"/extra/toys/0","value":"stick"},{"op":"move","from":"/extra/food","path":"/extra/diet"}]`,
+			http.StatusOK,
+			func() Pet {
+				pet := pet1001()
+				pet.Extra = map[string]interface{}{"diet": "meat", "toys": []interface{}{"stick", "ball"}}
+				return pet
+			}},
+		{"failed JSON patch test", "application/json-patch+json",
+			`[{"op":"replace","path":"/name","value":"Rex"},{"op":"test","path":"/extra/food","value":"fish"}]`, http.StatusConflict, pet1001},
+		{"JSON patch of a missing path", "application/json-patch+json",
+			`[{"op":"remove","path":"/extra/bones"}]`, http.StatusBadRequest, pet1001},
+		{"patch of the ID", "application/merge-patch+json", `{"id":1002}`, http.StatusBadRequest, pet1001},
+		{"patch with invalid JSON", "application/merge-patch+json", `{"owner":`, http.StatusBadRequest, pet1001},
+		{"plain JSON", "application/json", `{"owner":"Penny"}`, http.StatusUnsupportedMediaType, pet1001},
+	}
+	for _, c := range cases {
+		// given
+		p.SetupTest()
+		testPet := pet1001()
+		if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
+			panic("Error in test code, could not add initial data to test")
+		}
+		req, _ := http.NewRequest("PATCH", "/api/pet/1001", bytes.NewBufferString(c.patch))
+		req.Header.Set("Content-Type", c.contentType)
+		resp := httptest.NewRecorder()
+
+		// when
+		p.router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(c.status, resp.Code, "Unexpected response status for %s", c.name)
+		storedPet, err := p.service.store.ReadPet(context.Background(), 1001)
+		if !assert.NoError(err, "Pet should be retrievable after %s", c.name) {
+			continue
+		}
+		expectedPet := c.expected()
+		expectedPet.Version = storedPet.Version
+		assert.Equal(&expectedPet, storedPet, "Unexpected stored pet after %s", c.name)
+		if c.status == http.StatusOK {
+			var patchedPet Pet
+			assert.NoError(json.Unmarshal(resp.Body.Bytes(), &patchedPet), "Body should hold the patched pet for %s", c.name)
+			assert.Equal(expectedPet, patchedPet, "Body should hold the patched pet for %s", c.name)
+			assert.Equal(fmt.Sprintf(`"%d"`, storedPet.Version), resp.Header().Get("ETag"), "ETag should hold the patched version for %s", c.name)
+		} else {
+			assert.Equal(testPet.Version, storedPet.Version, "Pet should not be written by %s", c.name)
+		}
+	}
+}
+
+func (p *petServiceConfig) TestPatchPet_NoPetExistsWithGivenID() {
+	// given
+	assert := tassert.New(p.T())
+	req, _ := http.NewRequest("PATCH", "/api/pet/1000", bytes.NewBufferString(`{"owner":"Penny"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusNotFound, resp.Code, "Response status should be 404 Not Found")
+}
+
+func (p *petServiceConfig) TestPatchPet_ConcurrentPatchesAreAtomic() {
+	// given
+	assert := tassert.New(p.T())
+	testPet := pet1000()
+	testPet.Extra = map[string]interface{}{}
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
+		panic("Error in test code, could not add initial data to test")
+	}
+	const patches = 4
+	var wg sync.WaitGroup
+
+	// when
+	for i := 0; i < patches; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			patch := fmt.Sprintf(`[{"op":"add","path":"/extra/key%d","value":%d}]`, i, i)
+			req, _ := http.NewRequest("PATCH", "/api/pet/1000", bytes.NewBufferString(patch))
+			req.Header.Set("Content-Type", "application/json-patch+json")
+			resp := httptest.NewRecorder()
+			p.router.ServeHTTP(resp, req)
+			assert.Equal(http.StatusOK, resp.Code, "Concurrent patch %d should succeed", i)
+		}(i)
+	}
+	wg.Wait()
+
+	// then
+	storedPet, err := p.service.store.ReadPet(context.Background(), 1000)
+	if assert.NoError(err, "Pet with id 1000 should be retrievable") {
+		assert.Len(storedPet.Extra, patches, "Every concurrent patch should be applied")
+	}
+}
+
 func (p *petServiceConfig) TestDeletePetSuccessful() {
 	// given
 	assert := tassert.New(p.T())
 	testPet := pet1000()
-	if err := p.service.store.CreatePet(&testPet); err != nil {
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
 		panic("Error in test code, Could not add initial data to test")
 	}
 	req, _ := http.NewRequest("DELETE", "/api/pet/1000", nil)
@@ -279,6 +594,141 @@ func (p *petServiceConfig) TestDeletePet_InvalidID() {
 	assert.NotEmpty(body, "Body should be empty")
 }
 
+func (p *petServiceConfig) TestGetPet_ETag() {
+	// given
+	assert := tassert.New(p.T())
+	testPet := pet1000()
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
+		panic("Error in test code, could not add initial data to test")
+	}
+	req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	assert.Equal(fmt.Sprintf(`"%d"`, testPet.Version), resp.Header().Get("ETag"), "ETag should hold the pet version")
+}
+
+func (p *petServiceConfig) TestPutPet_Preconditions() {
+	assert := tassert.New(p.T())
+	cases := []struct {
+		name   string
+		header string
+		value  string
+		status int
+	}{
+		{"If-Match of the current version", "If-Match", `"1"`, http.StatusCreated},
+		{"If-Match of a stale version", "If-Match", `"7", "8"`, http.StatusPreconditionFailed},
+		{"weak If-Match", "If-Match", `W/"1"`, http.StatusPreconditionFailed},
+		{"If-Match of a foreign tag", "If-Match", `"abc"`, http.StatusPreconditionFailed},
+		{"If-None-Match of any version", "If-None-Match", "*", http.StatusPreconditionFailed},
+		{"If-None-Match of the current version", "If-None-Match", `W/"1"`, http.StatusPreconditionFailed},
+	}
+	for _, c := range cases {
+		// given
+		p.SetupTest()
+		testPet := pet1000()
+		if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
+			panic("Error in test code, could not add initial data to test")
+		}
+		requestBody, err := json.Marshal(modifiedPet1000())
+		if err != nil {
+			panic(fmt.Errorf("Error in test code, could not marshal modifiedPet1000 to json. %v", err))
+		}
+		req, _ := http.NewRequest("PUT", "/api/pet/1000", bytes.NewBuffer(requestBody))
+		req.Header.Set(c.header, c.value)
+		resp := httptest.NewRecorder()
+
+		// when
+		p.router.ServeHTTP(resp, req)
+
+		// then
+		assert.Equal(c.status, resp.Code, "Unexpected response status for PUT with %s", c.name)
+		storedPet, err := p.service.store.ReadPet(context.Background(), 1000)
+		assert.NoError(err, "Pet with id 1000 should be retrievable")
+		if c.status == http.StatusCreated {
+			assert.Equal(fmt.Sprintf(`"%d"`, storedPet.Version), resp.Header().Get("ETag"), "ETag should hold the new version for PUT with %s", c.name)
+		} else {
+			assert.Equal(&testPet, storedPet, "Pet should not be modified by PUT with %s", c.name)
+		}
+	}
+}
+
+func (p *petServiceConfig) TestDeletePet_PreconditionFailed() {
+	// given
+	assert := tassert.New(p.T())
+	testPet := pet1000()
+	if err := p.service.store.CreatePet(context.Background(), &testPet); err != nil {
+		panic("Error in test code, could not add initial data to test")
+	}
+	req, _ := http.NewRequest("DELETE", "/api/pet/1000", nil)
+	req.Header.Set("If-Match", fmt.Sprintf(`"%d"`, testPet.Version+1))
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusPreconditionFailed, resp.Code, "Response status should be 412 Precondition Failed")
+	_, err := p.service.store.ReadPet(context.Background(), 1000)
+	assert.NoError(err, "Pet should not be deleted")
+}
+
+func (p *petServiceConfig) TestListPetsSuccessful() {
+	// given
+	assert := tassert.New(p.T())
+	testPets := []Pet{pet1000(), pet1001()}
+	for i := range testPets {
+		if err := p.service.store.CreatePet(context.Background(), &testPets[i]); err != nil {
+			panic("Error in test code, could not add initial data to test")
+		}
+	}
+	req, _ := http.NewRequest("GET", "/api/pet?sort=-name&limit=1", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	var page PetPage
+	err := json.Unmarshal(resp.Body.Bytes(), &page)
+	if assert.NoError(err, "Body should be able to unmarshal to a pet page") {
+		assert.Equal(testPets[1:], page.Pets, "First page should hold Scruff")
+		assert.NotEmpty(page.NextCursor, "First page should have a next cursor")
+	}
+
+	// when
+	req, _ = http.NewRequest("GET", "/api/pet?sort=-name&limit=1&cursor="+page.NextCursor, nil)
+	resp = httptest.NewRecorder()
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusOK, resp.Code, "Response status should be 200 OK")
+	page = PetPage{}
+	err = json.Unmarshal(resp.Body.Bytes(), &page)
+	if assert.NoError(err, "Body should be able to unmarshal to a pet page") {
+		assert.Equal(testPets[:1], page.Pets, "Second page should hold Nemo")
+		assert.Empty(page.NextCursor, "Last page should not have a next cursor")
+	}
+}
+
+func (p *petServiceConfig) TestListPets_InvalidLimit() {
+	// given
+	assert := tassert.New(p.T())
+	req, _ := http.NewRequest("GET", "/api/pet?limit=lots", nil)
+	resp := httptest.NewRecorder()
+
+	// when
+	p.router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusBadRequest, resp.Code, "Response status should be 400 Bad Request")
+}
+
 // Initialises config, run the suite
 func TestPetService(t *testing.T) {
 	store := NewMemStore()
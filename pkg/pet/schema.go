@@ -0,0 +1,250 @@
+package pet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Schema is the subset of JSON Schema used to constrain the Extra data of pets.
+// It supports type, enum, properties, required, additionalProperties, items,
+// minLength, maxLength, pattern, minimum, maximum, minItems and maxItems, as well as
+// the boolean schemas true and false. Schemas using other keywords are rejected.
+type Schema struct {
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+
+	// Annotations, which do not constrain values
+	SchemaURI   string `json:"$schema,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// never is set by the boolean schema false, which no value matches
+	never   bool
+	pattern *regexp.Regexp
+}
+
+// schemaTypes holds the type keyword, either a single type name or an array of them
+type schemaTypes []string
+
+// schemaTypeNames are the JSON types a schema can require
+var schemaTypeNames = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+// LoadSchema reads a schema from a JSON file
+func LoadSchema(path string) (*Schema, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema %s: %w", path, err)
+	}
+	schema, err := ParseSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %w", path, err)
+	}
+	return schema, nil
+}
+
+// ParseSchema parses a schema from JSON
+func ParseSchema(data []byte) (*Schema, error) {
+	var schema Schema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// UnmarshalJSON parses a schema object or boolean schema, rejecting unsupported keywords
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{never: true}
+		return nil
+	}
+	type plainSchema Schema
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var plain plainSchema
+	if err := decoder.Decode(&plain); err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	*s = Schema(plain)
+	for _, name := range s.Type {
+		if !schemaTypeNames[name] {
+			return fmt.Errorf("invalid schema: unknown type %q", name)
+		}
+	}
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid schema: %w", err)
+		}
+		s.pattern = pattern
+	}
+	return nil
+}
+
+// UnmarshalJSON parses either a single type name or an array of them
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = schemaTypes{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return fmt.Errorf("type should be a string or an array of strings")
+	}
+	*t = names
+	return nil
+}
+
+// validate records every way in which value, found at field, does not match the schema
+func (s *Schema) validate(field string, value interface{}, errs *Error) {
+	if s.never {
+		errs.AddField(field, "is not allowed")
+		return
+	}
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		errs.AddField(field, "should be of type %s", joinOr(s.Type))
+		return
+	}
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		errs.AddField(field, "should be one of the allowed values")
+	}
+	switch typed := value.(type) {
+	case string:
+		s.validateString(field, typed, errs)
+	case float64:
+		s.validateNumber(field, typed, errs)
+	case []interface{}:
+		s.validateArray(field, typed, errs)
+	case map[string]interface{}:
+		s.validateObject(field, typed, errs)
+	}
+}
+
+func (s *Schema) matchesType(value interface{}) bool {
+	for _, name := range s.Type {
+		switch typed := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case float64:
+			if name == "number" || (name == "integer" && typed == math.Trunc(typed)) {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, allowed := range s.Enum {
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) validateString(field, value string, errs *Error) {
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		errs.AddField(field, "should be at least %d characters long", *s.MinLength)
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		errs.AddField(field, "should be at most %d characters long", *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		errs.AddField(field, "should match the pattern %s", s.Pattern)
+	}
+}
+
+func (s *Schema) validateNumber(field string, value float64, errs *Error) {
+	if s.Minimum != nil && value < *s.Minimum {
+		errs.AddField(field, "should be at least %v", *s.Minimum)
+	}
+	if s.Maximum != nil && value > *s.Maximum {
+		errs.AddField(field, "should be at most %v", *s.Maximum)
+	}
+}
+
+func (s *Schema) validateArray(field string, value []interface{}, errs *Error) {
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		errs.AddField(field, "should have at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		errs.AddField(field, "should have at most %d items", *s.MaxItems)
+	}
+	if s.Items != nil {
+		for i, item := range value {
+			s.Items.validate(fmt.Sprintf("%s[%d]", field, i), item, errs)
+		}
+	}
+}
+
+func (s *Schema) validateObject(field string, value map[string]interface{}, errs *Error) {
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			errs.AddField(field+"."+name, "is required")
+		}
+	}
+	for _, key := range sortedKeys(value) {
+		if property, ok := s.Properties[key]; ok {
+			property.validate(field+"."+key, value[key], errs)
+		} else if s.AdditionalProperties != nil {
+			s.AdditionalProperties.validate(field+"."+key, value[key], errs)
+		}
+	}
+}
+
+// joinOr joins names as "a", "a or b" or "a, b or c"
+func joinOr(names []string) string {
+	switch len(names) {
+	case 1:
+		return names[0]
+	default:
+		joined := names[0]
+		for _, name := range names[1 : len(names)-1] {
+			joined += ", " + name
+		}
+		return joined + " or " + names[len(names)-1]
+	}
+}
@@ -0,0 +1,96 @@
+package pet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"type": "object",
+	"required": ["food"],
+	"properties": {
+		"food": {"type": "string", "enum": ["meat", "flakes"]},
+		"age": {"type": "integer", "minimum": 0, "maximum": 100},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string", "pattern": "^[a-z]+$"}},
+		"vet": {"type": ["string", "null"], "minLength": 2}
+	},
+	"additionalProperties": false
+}`
+
+func TestSchemaValidation(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err, "Error in test code, could not parse schema")
+	validator := &Validator{ExtraSchema: schema}
+	validPet := &Pet{Name: "Scruff", Species: "Dog", Extra: map[string]interface{}{
+		"food": "meat", "age": 3.0, "tags": []interface{}{"good"}, "vet": nil,
+	}}
+	invalidPet := &Pet{Name: "Scruff", Species: "Dog", Extra: map[string]interface{}{
+		"age": 3.5, "tags": []interface{}{"Good", "boy", "!"}, "vet": "x", "colour": "golden",
+	}}
+
+	// when
+	validErr := validator.Validate(validPet)
+	err = validator.Validate(invalidPet)
+	missingErr := validator.Validate(&Pet{Name: "Scruff", Species: "Dog"})
+
+	// then
+	assert.NoError(validErr, "Extra matching the schema should pass validation")
+	if assertErrorCode(assert, ErrInvalidInput, err) {
+		assert.Equal([]FieldError{
+			{Field: "extra.food", Message: "is required"},
+			{Field: "extra.age", Message: "should be of type integer"},
+			{Field: "extra.colour", Message: "is not allowed"},
+			{Field: "extra.tags", Message: "should have at most 2 items"},
+			{Field: "extra.tags[0]", Message: "should match the pattern ^[a-z]+$"},
+			{Field: "extra.tags[2]", Message: "should match the pattern ^[a-z]+$"},
+			{Field: "extra.vet", Message: "should be at least 2 characters long"},
+		}, err.(*Error).Fields, "Every invalid Extra value should be reported")
+	}
+	if assertErrorCode(assert, ErrInvalidInput, missingErr) {
+		assert.Equal([]FieldError{{Field: "extra.food", Message: "is required"}}, missingErr.(*Error).Fields,
+			"Missing Extra should be validated as an empty object")
+	}
+}
+
+func TestParseSchema_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"unsupported keyword": `{"type": "object", "patternProperties": {}}`,
+		"unknown type":        `{"type": "decimal"}`,
+		"invalid pattern":     `{"properties": {"name": {"pattern": "("}}}`,
+		"invalid type":        `{"type": 1}`,
+	}
+	for name, data := range cases {
+		// when
+		_, err := ParseSchema([]byte(data))
+
+		// then
+		tassert.Error(t, err, "Schema with %s should be rejected", name)
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	// given
+	dir, err := ioutil.TempDir("", "schema")
+	require.NoError(t, err, "Error in test code, could not create directory")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "extra.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testSchema), 0600), "Error in test code, could not write schema")
+
+	// when
+	schema, err := LoadSchema(path)
+	_, missingErr := LoadSchema(filepath.Join(dir, "missing.json"))
+
+	// then
+	if tassert.NoError(t, err, "Schema file should load") {
+		tassert.Equal(t, []string{"food"}, schema.Required)
+	}
+	tassert.Error(t, missingErr, "Missing schema file should fail to load")
+}
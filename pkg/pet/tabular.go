@@ -0,0 +1,494 @@
+package pet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Extra data is flattened for the CSV and XML codecs, which have no nested values.
+// Every leaf value of the extra data is named by its dotted path, with array elements
+// named by their index, so {"tags": ["a"], "size": {"cm": 3}} flattens to the fields
+// tags.0 and size.cm. Empty objects and arrays are kept as leaves. Unflattening turns
+// objects whose keys are the indexes 0 to n-1 back into arrays. Keys holding dots
+// cannot be told apart from nesting, and are unflattened as nested objects.
+
+// flattenExtra adds the leaf values of the extra data of a pet to fields, keyed by path
+func flattenExtra(prefix string, value interface{}, fields map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			fields[prefix] = v
+		}
+		for key, child := range v {
+			flattenExtra(joinPath(prefix, key), child, fields)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[prefix] = v
+		}
+		for i, child := range v {
+			flattenExtra(joinPath(prefix, strconv.Itoa(i)), child, fields)
+		}
+	default:
+		fields[prefix] = v
+	}
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// unflattenExtra rebuilds the extra data of a pet from its flattened fields
+func unflattenExtra(fields map[string]interface{}) (map[string]interface{}, error) {
+	extra := map[string]interface{}{}
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	// sorted, so the same conflict is reported for the same fields
+	sort.Strings(paths)
+	for _, path := range paths {
+		object := extra
+		keys := strings.Split(path, ".")
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := object[key]
+			if !ok {
+				child = map[string]interface{}{}
+				object[key] = child
+			}
+			if object, ok = child.(map[string]interface{}); !ok {
+				return nil, Errorf(ErrInvalidInput, "Invalid pet data, extra field %s is nested in a value", path).
+					AddField("extra."+path, "should not be nested in a value")
+			}
+		}
+		last := keys[len(keys)-1]
+		if _, ok := object[last]; ok {
+			return nil, Errorf(ErrInvalidInput, "Invalid pet data, extra field %s holds nested fields", path).
+				AddField("extra."+path, "should not hold nested fields")
+		}
+		object[last] = fields[path]
+	}
+	// the top level stays an object even if its keys are indexes
+	for key, child := range extra {
+		extra[key] = restoreArrays(child)
+	}
+	return extra, nil
+}
+
+// restoreArrays converts the nested objects of a value keyed by the indexes 0 to n-1 to arrays
+func restoreArrays(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	for key, child := range object {
+		object[key] = restoreArrays(child)
+	}
+	if len(object) == 0 {
+		return object
+	}
+	array := make([]interface{}, len(object))
+	for key, child := range object {
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(array) || strconv.Itoa(i) != key {
+			return object
+		}
+		array[i] = child
+	}
+	return array
+}
+
+// csvColumns are the columns of the pet fields in CSV data, followed by the sorted
+// extra.* columns of the flattened extra data
+var csvColumns = []string{"id", "name", "species", "owner", "owner_id", "version"}
+
+// CSVCodec reads and writes pets as CSV with a header row, one row per pet. Extra data
+// is flattened to extra.* columns, see flattenExtra. Cells of extra columns holding a
+// JSON number, boolean, null, {} or [] are read as that value, other cells as strings,
+// and empty cells are left out.
+type CSVCodec struct{}
+
+// ContentType returns text/csv
+func (CSVCodec) ContentType() string {
+	return "text/csv; charset=utf-8; header=present"
+}
+
+// EncodePet writes a pet as a header row and a single row
+func (c CSVCodec) EncodePet(w io.Writer, pet *Pet) error {
+	return c.encode(w, []Pet{*pet})
+}
+
+// EncodePage writes the pets of a page, leaving out the next cursor
+func (c CSVCodec) EncodePage(w io.Writer, page *PetPage) error {
+	return c.encode(w, page.Pets)
+}
+
+func (CSVCodec) encode(w io.Writer, pets []Pet) error {
+	rows := make([]map[string]interface{}, len(pets))
+	extraColumns := map[string]bool{}
+	for i := range pets {
+		rows[i] = map[string]interface{}{}
+		flattenExtra("", pets[i].Extra, rows[i])
+		for path := range rows[i] {
+			extraColumns[path] = true
+		}
+	}
+	header := append([]string{}, csvColumns...)
+	paths := make([]string, 0, len(extraColumns))
+	for path := range extraColumns {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		header = append(header, "extra."+path)
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for i, pet := range pets {
+		record := []string{
+			strconv.FormatUint(uint64(pet.ID), 10),
+			pet.Name,
+			pet.Species,
+			pet.Owner,
+			formatOptionalUint(uint64(pet.OwnerID)),
+			formatOptionalUint(pet.Version),
+		}
+		for _, path := range paths {
+			value, ok := rows[i][path]
+			if !ok {
+				record = append(record, "")
+				continue
+			}
+			cell, err := formatCell(value)
+			if err != nil {
+				return err
+			}
+			record = append(record, cell)
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// DecodePet reads a pet from a header row and a single row. Columns are matched by
+// name and may be in any order. Unknown columns are rejected whether or not strict,
+// since a column outside the extra data has nowhere to be kept.
+func (CSVCodec) DecodePet(data []byte, pet *Pet, strict bool) error {
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(records) != 2 {
+		return Errorf(ErrInvalidInput, "Invalid pet data, CSV should hold a header row and a single pet row")
+	}
+	header, record := records[0], records[1]
+	extraFields := map[string]interface{}{}
+	for i, column := range header {
+		cell := record[i]
+		switch column {
+		case "id":
+			pet.ID, err = parseCSVUint32(column, cell)
+		case "name":
+			pet.Name = cell
+		case "species":
+			pet.Species = cell
+		case "owner":
+			pet.Owner = cell
+		case "owner_id":
+			pet.OwnerID, err = parseCSVUint32(column, cell)
+		case "version":
+			// versions are assigned by the store
+		default:
+			path := strings.TrimPrefix(column, "extra.")
+			if path == column || path == "" {
+				return Errorf(ErrInvalidInput, "Invalid pet data, unknown CSV column %s", column).
+					AddField(column, "is not a known field")
+			}
+			if cell != "" {
+				extraFields[path] = parseCell(cell)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(extraFields) > 0 {
+		pet.Extra, err = unflattenExtra(extraFields)
+	}
+	return err
+}
+
+func formatOptionalUint(n uint64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+func parseCSVUint32(column, cell string) (uint32, error) {
+	if cell == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(cell, 10, 32)
+	if err != nil {
+		return 0, Errorf(ErrInvalidInput, "Invalid pet data, %s %q should be a number", column, cell).
+			AddField(column, "should be a number")
+	}
+	return uint32(n), nil
+}
+
+// formatCell formats a leaf value of the extra data as a CSV cell
+func formatCell(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(value)
+	return string(data), err
+}
+
+// parseCell reads a CSV cell of the extra data, as a JSON scalar, {} or [] if it holds
+// one and as a string otherwise
+func parseCell(cell string) interface{} {
+	switch cell {
+	case "{}":
+		return map[string]interface{}{}
+	case "[]":
+		return []interface{}{}
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(cell), &value); err == nil {
+		switch value.(type) {
+		case nil, bool, float64:
+			return value
+		}
+	}
+	return cell
+}
+
+// Types of the extra fields of XML pets
+const (
+	xmlString  = "string"
+	xmlNumber  = "number"
+	xmlBoolean = "boolean"
+	xmlNull    = "null"
+	xmlObject  = "object"
+	xmlArray   = "array"
+)
+
+// xmlPet is the XML form of a pet. Extra data is flattened to typed fields, see flattenExtra.
+type xmlPet struct {
+	XMLName xml.Name  `xml:"pet"`
+	ID      uint32    `xml:"id"`
+	Name    string    `xml:"name"`
+	Species string    `xml:"species"`
+	Owner   string    `xml:"owner"`
+	OwnerID uint32    `xml:"owner_id,omitempty"`
+	Extra   *xmlExtra `xml:"extra"`
+	Version uint64    `xml:"version,omitempty"`
+	// Unknown collects the elements a pet does not have, rejected when decoding strictly
+	Unknown []xmlUnknown `xml:",any"`
+}
+
+type xmlExtra struct {
+	Fields  []xmlField   `xml:"field"`
+	Unknown []xmlUnknown `xml:",any"`
+}
+
+// xmlUnknown is an element the XML form of a pet does not have
+type xmlUnknown struct {
+	XMLName xml.Name
+}
+
+// xmlField is a leaf value of the extra data of a pet, with the type of its JSON value
+type xmlField struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlPage is the XML form of a page of pets
+type xmlPage struct {
+	XMLName    xml.Name `xml:"pets"`
+	NextCursor string   `xml:"next_cursor,attr,omitempty"`
+	Pets       []xmlPet `xml:"pet"`
+}
+
+// XMLCodec reads and writes pets as XML, a <pet> element holding an element per field.
+// Extra data is flattened to typed <field> elements of an <extra> element. A page is a
+// <pets> element with the next cursor as an attribute.
+type XMLCodec struct{}
+
+// ContentType returns application/xml
+func (XMLCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+// EncodePet writes a pet as a <pet> element
+func (XMLCodec) EncodePet(w io.Writer, pet *Pet) error {
+	x, err := toXMLPet(pet)
+	if err != nil {
+		return err
+	}
+	return encodeXML(w, x)
+}
+
+// EncodePage writes a page as a <pets> element
+func (XMLCodec) EncodePage(w io.Writer, page *PetPage) error {
+	x := xmlPage{NextCursor: page.NextCursor, Pets: make([]xmlPet, len(page.Pets))}
+	for i := range page.Pets {
+		pet, err := toXMLPet(&page.Pets[i])
+		if err != nil {
+			return err
+		}
+		x.Pets[i] = *pet
+	}
+	return encodeXML(w, x)
+}
+
+// DecodePet reads a pet from a <pet> element, rejecting unknown elements if strict
+func (XMLCodec) DecodePet(data []byte, pet *Pet, strict bool) error {
+	var x xmlPet
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	if strict {
+		if err := checkXMLUnknown(x); err != nil {
+			return err
+		}
+	}
+	*pet = Pet{ID: x.ID, Name: x.Name, Species: x.Species, Owner: x.Owner, OwnerID: x.OwnerID}
+	if x.Extra == nil {
+		return nil
+	}
+	fields := make(map[string]interface{}, len(x.Extra.Fields))
+	for _, field := range x.Extra.Fields {
+		value, err := parseXMLField(field)
+		if err != nil {
+			return err
+		}
+		fields[field.Name] = value
+	}
+	var err error
+	pet.Extra, err = unflattenExtra(fields)
+	return err
+}
+
+// checkXMLUnknown returns an ErrInvalidInput error naming the first unknown element of a pet
+func checkXMLUnknown(x xmlPet) error {
+	unknown, prefix := x.Unknown, ""
+	if len(unknown) == 0 && x.Extra != nil {
+		unknown, prefix = x.Extra.Unknown, "extra."
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	field := prefix + unknown[0].XMLName.Local
+	return Errorf(ErrInvalidInput, "Invalid pet data, unknown XML element %s", field).
+		AddField(field, "is not a known field")
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func toXMLPet(pet *Pet) (*xmlPet, error) {
+	x := &xmlPet{ID: pet.ID, Name: pet.Name, Species: pet.Species, Owner: pet.Owner, OwnerID: pet.OwnerID, Version: pet.Version}
+	if pet.Extra == nil {
+		return x, nil
+	}
+	fields := map[string]interface{}{}
+	flattenExtra("", pet.Extra, fields)
+	paths := make([]string, 0, len(fields))
+	for path := range fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	x.Extra = &xmlExtra{Fields: make([]xmlField, len(paths))}
+	for i, path := range paths {
+		field, err := formatXMLField(path, fields[path])
+		if err != nil {
+			return nil, err
+		}
+		x.Extra.Fields[i] = field
+	}
+	return x, nil
+}
+
+func formatXMLField(path string, value interface{}) (xmlField, error) {
+	field := xmlField{Name: path}
+	switch v := value.(type) {
+	case string:
+		field.Type, field.Value = xmlString, v
+	case bool:
+		field.Type, field.Value = xmlBoolean, strconv.FormatBool(v)
+	case nil:
+		field.Type = xmlNull
+	case map[string]interface{}:
+		field.Type = xmlObject
+	case []interface{}:
+		field.Type = xmlArray
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return field, err
+		}
+		field.Type, field.Value = xmlNumber, string(data)
+	}
+	return field, nil
+}
+
+func parseXMLField(field xmlField) (interface{}, error) {
+	invalid := func() error {
+		return Errorf(ErrInvalidInput, "Invalid pet data, extra field %s is not a valid %s", field.Name, field.Type).
+			AddField("extra."+field.Name, "should be a %s", field.Type)
+	}
+	if field.Name == "" {
+		return nil, Errorf(ErrInvalidInput, "Invalid pet data, extra fields should be named").
+			AddField("extra", "should have named fields")
+	}
+	switch field.Type {
+	case xmlString:
+		return field.Value, nil
+	case xmlNumber:
+		n, err := strconv.ParseFloat(strings.TrimSpace(field.Value), 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return n, nil
+	case xmlBoolean:
+		b, err := strconv.ParseBool(strings.TrimSpace(field.Value))
+		if err != nil {
+			return nil, invalid()
+		}
+		return b, nil
+	case xmlNull:
+		return nil, nil
+	case xmlObject:
+		return map[string]interface{}{}, nil
+	case xmlArray:
+		return []interface{}{}, nil
+	}
+	return nil, Errorf(ErrInvalidInput, "Invalid pet data, extra field %s has unknown type %q", field.Name, field.Type).
+		AddField("extra."+field.Name, "should have a type of %s", strings.Join([]string{xmlString, xmlNumber, xmlBoolean, xmlNull, xmlObject, xmlArray}, ", "))
+}
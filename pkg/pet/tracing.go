@@ -0,0 +1,135 @@
+package pet
+
+import (
+	"context"
+	"errors"
+
+	"github.service.anz/go/samplerest/pkg/trace"
+)
+
+// TracingStore is a Storer decorator that records a span for every operation of the
+// store it wraps, as a child of the span in the operation's context
+type TracingStore struct {
+	wrappedStore
+	tracer *trace.Tracer
+}
+
+// NewTracingStore wraps store, starting spans with tracer
+func NewTracingStore(store Storer, tracer *trace.Tracer) *TracingStore {
+	return &TracingStore{wrappedStore: wrappedStore{store}, tracer: tracer}
+}
+
+// start starts the span of an operation on the pet with the given ID, 0 if there is none
+func (t *TracingStore) start(ctx context.Context, operation string, petID uint32) (context.Context, *trace.Span) {
+	ctx, span := t.tracer.Start(ctx, "pet.Storer/"+operation, trace.KindInternal)
+	if petID != 0 {
+		span.SetAttribute("pet.id", petID)
+	}
+	return ctx, span
+}
+
+// endSpan ends the span of an operation, recording its error code if it failed
+func endSpan(span *trace.Span, err error) {
+	if err != nil {
+		code := ErrUnknown
+		var petErr *Error
+		if errors.As(err, &petErr) {
+			code = petErr.Code
+		}
+		span.SetAttribute("pet.error_code", ErrorCodeName(code))
+		span.SetError(err)
+	}
+	span.End()
+}
+
+// CreatePet creates a pet in the wrapped store
+func (t *TracingStore) CreatePet(ctx context.Context, pet *Pet) (err error) {
+	ctx, span := t.start(ctx, "CreatePet", 0)
+	defer func() {
+		span.SetAttribute("pet.id", pet.ID)
+		endSpan(span, err)
+	}()
+	return t.store.CreatePet(ctx, pet)
+}
+
+// ReadPet reads a pet from the wrapped store
+func (t *TracingStore) ReadPet(ctx context.Context, petID uint32) (pet *Pet, err error) {
+	ctx, span := t.start(ctx, "ReadPet", petID)
+	defer func() { endSpan(span, err) }()
+	return t.store.ReadPet(ctx, petID)
+}
+
+// UpdatePet updates a pet in the wrapped store
+func (t *TracingStore) UpdatePet(ctx context.Context, petID uint32, pet *Pet, pre Precondition) (err error) {
+	ctx, span := t.start(ctx, "UpdatePet", petID)
+	defer func() { endSpan(span, err) }()
+	return t.store.UpdatePet(ctx, petID, pet, pre)
+}
+
+// DeletePet deletes a pet from the wrapped store
+func (t *TracingStore) DeletePet(ctx context.Context, petID uint32, pre Precondition) (deleted bool, err error) {
+	ctx, span := t.start(ctx, "DeletePet", petID)
+	defer func() { endSpan(span, err) }()
+	return t.store.DeletePet(ctx, petID, pre)
+}
+
+// ListPets lists pets in the wrapped store
+func (t *TracingStore) ListPets(ctx context.Context, query ListQuery) (page *PetPage, err error) {
+	ctx, span := t.start(ctx, "ListPets", 0)
+	defer func() {
+		if page != nil {
+			span.SetAttribute("pet.count", len(page.Pets))
+		}
+		endSpan(span, err)
+	}()
+	return t.store.ListPets(ctx, query)
+}
+
+// Batch applies a batch of operations to the wrapped store
+func (t *TracingStore) Batch(ctx context.Context, ops []BatchOperation, atomic bool) (results []BatchResult, err error) {
+	ctx, span := t.start(ctx, "Batch", 0)
+	span.SetAttribute("pet.count", len(ops))
+	span.SetAttribute("pet.batch_atomic", atomic)
+	defer func() { endSpan(span, err) }()
+	return t.store.Batch(ctx, ops, atomic)
+}
+
+// startOwner starts the span of an operation on the owner with the given ID, 0 if there is none
+func (t *TracingStore) startOwner(ctx context.Context, operation string, ownerID uint32) (context.Context, *trace.Span) {
+	ctx, span := t.tracer.Start(ctx, "pet.OwnerStorer/"+operation, trace.KindInternal)
+	if ownerID != 0 {
+		span.SetAttribute("owner.id", ownerID)
+	}
+	return ctx, span
+}
+
+// CreateOwner creates an owner in the wrapped store
+func (t *TracingStore) CreateOwner(ctx context.Context, owner *Owner) (err error) {
+	ctx, span := t.startOwner(ctx, "CreateOwner", 0)
+	defer func() {
+		span.SetAttribute("owner.id", owner.ID)
+		endSpan(span, err)
+	}()
+	return t.owners().CreateOwner(ctx, owner)
+}
+
+// ReadOwner reads an owner from the wrapped store
+func (t *TracingStore) ReadOwner(ctx context.Context, ownerID uint32) (owner *Owner, err error) {
+	ctx, span := t.startOwner(ctx, "ReadOwner", ownerID)
+	defer func() { endSpan(span, err) }()
+	return t.owners().ReadOwner(ctx, ownerID)
+}
+
+// UpdateOwner updates an owner in the wrapped store
+func (t *TracingStore) UpdateOwner(ctx context.Context, ownerID uint32, owner *Owner) (err error) {
+	ctx, span := t.startOwner(ctx, "UpdateOwner", ownerID)
+	defer func() { endSpan(span, err) }()
+	return t.owners().UpdateOwner(ctx, ownerID, owner)
+}
+
+// DeleteOwner deletes an owner from the wrapped store
+func (t *TracingStore) DeleteOwner(ctx context.Context, ownerID uint32) (deleted bool, err error) {
+	ctx, span := t.startOwner(ctx, "DeleteOwner", ownerID)
+	defer func() { endSpan(span, err) }()
+	return t.owners().DeleteOwner(ctx, ownerID)
+}
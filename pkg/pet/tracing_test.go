@@ -0,0 +1,61 @@
+package pet
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.service.anz/go/samplerest/pkg/trace"
+
+	"github.com/go-chi/chi"
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func TestTracingStoreSpansAreChildrenOfRequests(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	exporter := &trace.MemoryExporter{}
+	tracer := trace.NewTracer(exporter)
+	router := chi.NewRouter()
+	router.Use(trace.Middleware(tracer))
+	SetupRoutes(router, NewPetService(NewTracingStore(NewMemStore(), tracer)))
+	req, _ := http.NewRequest("GET", "/api/pet/1000", nil)
+	req.Header.Set(trace.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// when
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	spans := exporter.Spans()
+	if assert.Len(spans, 2, "Request and store operation should each have a span") {
+		storeSpan, requestSpan := spans[0], spans[1]
+		assert.Equal("pet.Storer/ReadPet", storeSpan.Name)
+		assert.Equal("GET /api/pet/{id}", requestSpan.Name)
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", storeSpan.TraceID.String(), "Store span should continue the request trace")
+		assert.Equal(requestSpan.SpanID, storeSpan.ParentSpanID, "Store span should be a child of the request span")
+		assert.Equal(uint32(1000), storeSpan.Attributes["pet.id"])
+		assert.Equal("not_found", storeSpan.Attributes["pet.error_code"], "Failed operations should record their error code")
+		assert.NotEmpty(storeSpan.Error)
+		assert.Empty(requestSpan.Error, "Client errors should not fail the request span")
+	}
+}
+
+func TestTracingStoreSpansOwnerOperations(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	exporter := &trace.MemoryExporter{}
+	store := NewTracingStore(NewMemStore(), trace.NewTracer(exporter))
+	owner := &Owner{Name: "Andy"}
+
+	// when
+	err := store.CreateOwner(context.Background(), owner)
+
+	// then
+	assert.NoError(err)
+	spans := exporter.Spans()
+	if assert.Len(spans, 1) {
+		assert.Equal("pet.OwnerStorer/CreateOwner", spans[0].Name)
+		assert.Equal(owner.ID, spans[0].Attributes["owner.id"])
+	}
+}
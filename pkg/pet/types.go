@@ -1,18 +1,131 @@
 package pet
 
+import "context"
+
 // Pet defines the data structure corresponding to a pet
 type Pet struct {
-	ID      uint32                 `json:"id"`
-	Name    string                 `json:"name"`
-	Species string                 `json:"species"`
-	Owner   string                 `json:"owner"`
+	ID      uint32 `json:"id"`
+	Name    string `json:"name"`
+	Species string `json:"species"`
+	// Owner is the free text owner name pets had before owners were stored separately,
+	// see MigrateOwners. New pets should reference their owner by OwnerID instead.
+	Owner string `json:"owner"`
+	// OwnerID references the Owner of the pet, 0 if the pet has none
+	OwnerID uint32                 `json:"owner_id,omitempty"`
 	Extra   map[string]interface{} `json:"extra"`
+	// Version is assigned by the store on every write and increases monotonically.
+	// It is ignored when writing a pet.
+	Version uint64 `json:"version,omitempty"`
 }
 
-// Storer defines standard CRUD operations for Pets
+// Storer defines standard CRUD operations for Pets.
+// Every operation takes the context of the request it serves, carrying its trace and
+// deadline. Operations fail with ErrCanceled or ErrTimeout once the context is done.
+// CreatePet and UpdatePet set the Version of the pet passed in to the version it was stored with.
+// CreatePet allocates an ID for a pet with ID 0 and sets it on the pet passed in.
+// Batch applies operations in order, returning a result for each. An atomic batch
+// either applies every operation or none, failing with the error of the first failed
+// operation; stores that cannot apply batches atomically fail with ErrUnsupported.
+// Other batches apply every operation they can, reporting failures in the results.
+// Storers holding resources that need flushing or releasing may also implement
+// io.Closer, which is called once the server has stopped serving requests.
 type Storer interface {
-	CreatePet(*Pet) error
-	ReadPet(ID uint32) (*Pet, error)
-	UpdatePet(ID uint32, pet *Pet) error
-	DeletePet(ID uint32) (bool, error)
+	CreatePet(ctx context.Context, pet *Pet) error
+	ReadPet(ctx context.Context, ID uint32) (*Pet, error)
+	UpdatePet(ctx context.Context, ID uint32, pet *Pet, pre Precondition) error
+	DeletePet(ctx context.Context, ID uint32, pre Precondition) (bool, error)
+	ListPets(ctx context.Context, query ListQuery) (*PetPage, error)
+	Batch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+}
+
+// ListQuery selects, orders and pages the pets returned by ListPets.
+// Empty filter fields match every pet.
+type ListQuery struct {
+	Owner      string
+	OwnerID    uint32
+	Species    string
+	NamePrefix string
+	// Sort is one of id, name, species or owner, prefixed with "-" for descending order.
+	// Pets with equal sort values are ordered by ID. Defaults to id.
+	Sort string
+	// Limit is the maximum number of pets in the page, defaults to DefaultListLimit
+	Limit int
+	// Cursor continues a listing from the NextCursor of a previous page
+	Cursor string
+}
+
+// PetPage is a single page of pets returned by ListPets
+type PetPage struct {
+	Pets []Pet `json:"pets"`
+	// NextCursor is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Precondition makes a write conditional on the version of the currently stored pet,
+// mirroring the HTTP If-Match and If-None-Match headers.
+// The zero value places no condition on a write.
+type Precondition struct {
+	// IfMatch requires the stored pet to have one of these versions
+	IfMatch []uint64
+	// IfMatchAny requires a pet to be stored
+	IfMatchAny bool
+	// IfNoneMatch requires the stored pet to have none of these versions
+	IfNoneMatch []uint64
+	// IfNoneMatchAny requires no pet to be stored
+	IfNoneMatchAny bool
+}
+
+// Check returns an ErrConflict error if the stored pet does not satisfy the precondition.
+// stored is nil if no pet is stored under petID.
+func (p Precondition) Check(petID uint32, stored *Pet) error {
+	switch {
+	case p.IfMatchAny && stored == nil:
+		return Errorf(ErrConflict, "No pet exists with id %d", petID)
+	case len(p.IfMatch) > 0 && (stored == nil || !containsVersion(p.IfMatch, stored.Version)):
+		return Errorf(ErrConflict, "Pet with id %d does not match the expected version", petID)
+	case p.IfNoneMatchAny && stored != nil:
+		return Errorf(ErrConflict, "Pet with id %d already exists", petID)
+	case stored != nil && containsVersion(p.IfNoneMatch, stored.Version):
+		return Errorf(ErrConflict, "Pet with id %d matches an excluded version", petID)
+	}
+	return nil
+}
+
+// empty reports whether the precondition places no condition on a write
+func (p Precondition) empty() bool {
+	return len(p.IfMatch) == 0 && !p.IfMatchAny && len(p.IfNoneMatch) == 0 && !p.IfNoneMatchAny
+}
+
+func containsVersion(versions []uint64, version uint64) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Operations of a BatchOperation
+const (
+	BatchCreate = "create"
+	BatchUpsert = "upsert"
+	BatchDelete = "delete"
+)
+
+// BatchOperation is a single write of a batch. Creates and upserts write Pet, creates
+// allocating an ID if its ID is 0. Upserts and deletes address the pet with ID.
+type BatchOperation struct {
+	Op  string `json:"op"`
+	ID  uint32 `json:"id,omitempty"`
+	Pet *Pet   `json:"pet,omitempty"`
+}
+
+// BatchResult is the outcome of a single operation of a batch
+type BatchResult struct {
+	// Pet is the pet written by a create or upsert, with its ID and version set
+	Pet *Pet
+	// Deleted reports whether a delete removed a pet
+	Deleted bool
+	// Err is nil if the operation succeeded
+	Err error
 }
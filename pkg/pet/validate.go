@@ -0,0 +1,112 @@
+package pet
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// Limits on pet fields, in characters
+const (
+	MaxNameLength    = 100
+	MaxSpeciesLength = 100
+	MaxOwnerLength   = 100
+)
+
+// DefaultMaxExtraDepth is how deeply Extra values may nest objects and arrays
+const DefaultMaxExtraDepth = 5
+
+// Validator checks pets before they are written. The zero value requires a name and
+// species, limits field lengths and the nesting of Extra, and accepts any species.
+type Validator struct {
+	// Species lists the allowed species, any species is allowed if it is empty
+	Species []string
+	// ExtraSchema constrains Extra, which is unconstrained if it is nil
+	ExtraSchema *Schema
+	// MaxExtraDepth limits the nesting of Extra values, defaults to DefaultMaxExtraDepth
+	MaxExtraDepth int
+}
+
+// Validate returns an ErrInvalidInput error listing every invalid field of pet, or nil
+// if pet is valid. A nil Validator applies the rules of the zero value.
+func (v *Validator) Validate(pet *Pet) error {
+	if v == nil {
+		v = &Validator{}
+	}
+	errs := Errorf(ErrInvalidInput, "Invalid pet data")
+	validateText(errs, "name", pet.Name, true, MaxNameLength)
+	validateText(errs, "species", pet.Species, true, MaxSpeciesLength)
+	validateText(errs, "owner", pet.Owner, false, MaxOwnerLength)
+	if pet.Species != "" && len(v.Species) > 0 && !containsString(v.Species, pet.Species) {
+		errs.AddField("species", "should be one of %s", joinOr(v.Species))
+	}
+	maxDepth := v.MaxExtraDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxExtraDepth
+	}
+	for _, key := range sortedKeys(pet.Extra) {
+		if extraDepth(pet.Extra[key]) > maxDepth {
+			errs.AddField("extra."+key, "should not be nested more than %d levels deep", maxDepth)
+		}
+	}
+	if v.ExtraSchema != nil {
+		var extra interface{} = map[string]interface{}(pet.Extra)
+		if pet.Extra == nil {
+			extra = map[string]interface{}{}
+		}
+		v.ExtraSchema.validate("extra", extra, errs)
+	}
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateText(errs *Error, field, value string, required bool, maxLength int) {
+	if required && value == "" {
+		errs.AddField(field, "is required")
+	}
+	if utf8.RuneCountInString(value) > maxLength {
+		errs.AddField(field, "should be at most %d characters long", maxLength)
+	}
+}
+
+// extraDepth returns how many levels of objects and arrays value nests
+func extraDepth(value interface{}) int {
+	depth := 0
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for _, item := range typed {
+			if d := extraDepth(item); d > depth {
+				depth = d
+			}
+		}
+		return depth + 1
+	case []interface{}:
+		for _, item := range typed {
+			if d := extraDepth(item); d > depth {
+				depth = d
+			}
+		}
+		return depth + 1
+	}
+	return depth
+}
+
+// sortedKeys returns the keys of an object in order, so that errors are reported in a stable order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
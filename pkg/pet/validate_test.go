@@ -0,0 +1,103 @@
+package pet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	validator := &Validator{Species: []string{"Goldfish", "Golden Retriever"}, MaxExtraDepth: 2}
+	invalidPet := &Pet{
+		Species: "Clownfish",
+		Owner:   strings.Repeat("ü", MaxOwnerLength+1),
+		Extra: map[string]interface{}{
+			"flat":   "fine",
+			"nested": map[string]interface{}{"a": []interface{}{map[string]interface{}{}}},
+		},
+	}
+
+	// when
+	validErr := validator.Validate(&Pet{Name: "Nemo", Species: "Goldfish", Owner: strings.Repeat("ü", MaxOwnerLength)})
+	err := validator.Validate(invalidPet)
+
+	// then
+	assert.NoError(validErr, "Valid pet should pass validation")
+	if assertErrorCode(assert, ErrInvalidInput, err) {
+		assert.Equal([]FieldError{
+			{Field: "name", Message: "is required"},
+			{Field: "owner", Message: "should be at most 100 characters long"},
+			{Field: "species", Message: "should be one of Goldfish or Golden Retriever"},
+			{Field: "extra.nested", Message: "should not be nested more than 2 levels deep"},
+		}, err.(*Error).Fields, "Every invalid field should be reported")
+	}
+}
+
+func TestValidate_NilValidator(t *testing.T) {
+	// given
+	var validator *Validator
+
+	// when
+	err := validator.Validate(&Pet{Name: "Nemo"})
+
+	// then
+	if assertErrorCode(tassert.New(t), ErrInvalidInput, err) {
+		tassert.Equal(t, []FieldError{{Field: "species", Message: "is required"}}, err.(*Error).Fields)
+	}
+}
+
+func TestPostPet_Validation(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	store := NewMemStore()
+	service := NewPetService(store)
+	service.Validator = &Validator{Species: []string{"Goldfish"}}
+	router := chi.NewRouter()
+	SetupRoutes(router, service)
+	req, _ := http.NewRequest("POST", "/api/pet", strings.NewReader(`{"id": 1000, "name": "", "species": "Clownfish"}`))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusBadRequest, resp.Code, "Response status should be 400 Bad Request")
+	assert.Contains(resp.Body.String(), `{"field":"name","message":"is required"}`)
+	assert.Contains(resp.Body.String(), `{"field":"species","message":"should be one of Goldfish"}`)
+	_, err := store.ReadPet(req.Context(), 1000)
+	assert.Error(err, "Invalid pet should not be stored")
+}
+
+func TestBatchPets_Validation(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	router, store := batchRouter(t)
+	body := `[{"op": "create", "pet": {"id": 1001, "name": "Scruff", "species": "Dog"}}, {"op": "upsert", "id": 1000, "pet": {"name": "Dory"}}]`
+	req, _ := http.NewRequest("POST", "/api/pet:batch", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+
+	// when
+	router.ServeHTTP(resp, req)
+
+	// then
+	assert.Equal(http.StatusBadRequest, resp.Code, "Batch with an invalid pet should be rejected")
+	assert.Contains(resp.Body.String(), `{"field":"[1].pet.species","message":"is required"}`, "Invalid field should be named by operation")
+	_, err := store.ReadPet(req.Context(), 1001)
+	require.Error(t, err, "No operation of an invalid batch should be applied")
+}
+
+// assertErrorCode asserts that err is an *Error with the given code
+func assertErrorCode(assert *tassert.Assertions, code int, err error) bool {
+	if !assert.IsType(&Error{}, err) {
+		return false
+	}
+	return assert.Equal(code, err.(*Error).Code)
+}
@@ -0,0 +1,74 @@
+package pet
+
+import (
+	"context"
+	"io"
+)
+
+// wrappedStore is embedded by Storer decorators to pass the optional HealthChecker and
+// io.Closer interfaces on to the store they wrap. Decorators implement OwnerStorer
+// whatever they wrap, OwnerStore tells whether they store owners.
+type wrappedStore struct {
+	store Storer
+}
+
+// CheckHealth checks the wrapped store if it is a HealthChecker, and succeeds otherwise
+func (w wrappedStore) CheckHealth(ctx context.Context) error {
+	if checker, ok := w.store.(HealthChecker); ok {
+		return checker.CheckHealth(ctx)
+	}
+	return nil
+}
+
+// Close closes the wrapped store if it is an io.Closer
+func (w wrappedStore) Close() error {
+	if closer, ok := w.store.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// owners returns the wrapped store as an OwnerStorer, or one failing every operation
+// with ErrUnsupported if it stores no owners
+func (w wrappedStore) owners() OwnerStorer {
+	if owners, ok := OwnerStore(w.store); ok {
+		return owners
+	}
+	return unsupportedOwners{}
+}
+
+// storesOwners reports whether the wrapped store stores owners
+func (w wrappedStore) storesOwners() bool {
+	_, ok := OwnerStore(w.store)
+	return ok
+}
+
+// OwnerStore returns store as an OwnerStorer, and false if it does not store owners.
+// Unlike a type assertion, it sees through decorators such as MetricsStore, which
+// implement OwnerStorer whether or not the store they wrap does.
+func OwnerStore(store Storer) (OwnerStorer, bool) {
+	owners, ok := store.(OwnerStorer)
+	if wrapper, isWrapper := store.(interface{ storesOwners() bool }); ok && isWrapper {
+		ok = wrapper.storesOwners()
+	}
+	return owners, ok
+}
+
+// unsupportedOwners is the OwnerStorer of decorators wrapping a store without owners
+type unsupportedOwners struct{}
+
+func (unsupportedOwners) CreateOwner(context.Context, *Owner) error {
+	return Errorf(ErrUnsupported, "Owners are not supported by this store")
+}
+
+func (unsupportedOwners) ReadOwner(context.Context, uint32) (*Owner, error) {
+	return nil, Errorf(ErrUnsupported, "Owners are not supported by this store")
+}
+
+func (unsupportedOwners) UpdateOwner(context.Context, uint32, *Owner) error {
+	return Errorf(ErrUnsupported, "Owners are not supported by this store")
+}
+
+func (unsupportedOwners) DeleteOwner(context.Context, uint32) (bool, error) {
+	return false, Errorf(ErrUnsupported, "Owners are not supported by this store")
+}
@@ -0,0 +1,397 @@
+// Package petclient is a client of the pet REST API served by petserver.
+//
+// A Client implements pet.Storer, so that code written against a store works unchanged
+// against a remote server:
+//
+//	client, err := petclient.New(petclient.Config{BaseURL: "http://localhost:4852"})
+//	...
+//	err = client.CreatePet(ctx, &pet.Pet{Name: "Nemo", Species: "Clownfish"})
+//
+// Errors returned by the server are decoded back into *pet.Error values with the
+// code the server failed with.
+package petclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+)
+
+// Defaults of a Config
+const (
+	DefaultBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff = 2 * time.Second
+)
+
+// maxErrorBody limits how much of an error response is read
+const maxErrorBody = 64 << 10
+
+// Config holds the settings of a Client
+type Config struct {
+	// BaseURL is the URL the server is reached at, such as http://localhost:4852
+	BaseURL string
+	// Timeout bounds every attempt of a request, 0 leaves it to the context
+	Timeout time.Duration
+	// Retries is how often a request is retried after a network error or a 502, 503
+	// or 504 response. Only requests that are safe to repeat are retried: reads, and
+	// updates and deletes without a precondition.
+	Retries int
+	// Backoff is the delay before the first retry, doubling with every further retry up
+	// to MaxBackoff. Delays are jittered. Defaults to DefaultBackoff and DefaultMaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Transport makes the HTTP requests, defaults to http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// Client is a client of the pet REST API, implementing pet.Storer
+type Client struct {
+	baseURL *url.URL
+	http    *http.Client
+	cfg     Config
+}
+
+// New creates a client of the server at cfg.BaseURL
+func New(cfg Config) (*Client, error) {
+	baseURL, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, pet.Errorf(pet.ErrInvalidInput, "Invalid base URL %q, should be an absolute URL", cfg.BaseURL)
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Client{
+		baseURL: baseURL,
+		http:    &http.Client{Transport: transport, Timeout: cfg.Timeout},
+		cfg:     cfg,
+	}, nil
+}
+
+// request describes a request to the API
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   interface{}
+	// retry is set if the request is safe to repeat
+	retry bool
+}
+
+// do sends a request, retrying it as configured, and decodes a successful response into
+// out unless it is nil. Error responses are returned as *pet.Error.
+func (c *Client) do(ctx context.Context, req request, out interface{}) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, pet.ErrorEf(pet.ErrInvalidInput, err, "Could not encode request body")
+		}
+	}
+	retries := 0
+	if req.retry {
+		retries = c.cfg.Retries
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, req, body)
+		if err == nil && !retryableStatus(resp.StatusCode) || attempt >= retries {
+			if err != nil {
+				return nil, c.transportError(ctx, err)
+			}
+			return resp, decodeResponse(resp, out)
+		}
+		if err == nil {
+			drain(resp)
+		}
+		if err = c.sleep(ctx, attempt); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// send makes a single attempt of a request
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequest(req.method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	httpReq = httpReq.WithContext(ctx)
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	httpReq.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(httpReq)
+}
+
+// retryableStatus reports whether a response status is worth retrying, as the server
+// or a proxy in front of it was briefly unable to serve the request
+func retryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// sleep waits before the retry following attempt, giving up when ctx is done
+func (c *Client) sleep(ctx context.Context, attempt int) error {
+	backoff := c.cfg.Backoff << uint(attempt)
+	if backoff > c.cfg.MaxBackoff || backoff <= 0 {
+		backoff = c.cfg.MaxBackoff
+	}
+	// full jitter, so that clients failing together do not retry together
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff)) + 1))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return pet.ContextError(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transportError describes a request that got no response
+func (c *Client) transportError(ctx context.Context, err error) error {
+	if ctxErr := pet.ContextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return pet.ErrorEf(pet.ErrTimeout, err, "Request to %s timed out", c.baseURL.Host)
+	}
+	return pet.ErrorEf(pet.ErrUnknown, err, "Request to %s failed", c.baseURL.Host)
+}
+
+// decodeResponse decodes a successful response into out, or the error of a failed response
+func decodeResponse(resp *http.Response, out interface{}) error {
+	defer drain(resp)
+	if resp.StatusCode >= 400 {
+		return decodeError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return pet.ErrorEf(pet.ErrUnknown, err, "Could not decode response")
+	}
+	return nil
+}
+
+// statusCodes maps response statuses to error codes, for error responses without problem details
+var statusCodes = map[int]int{
+	http.StatusBadRequest:            pet.ErrInvalidInput,
+	http.StatusNotFound:              pet.ErrNotFound,
+	http.StatusNotAcceptable:         pet.ErrNotAcceptable,
+	http.StatusConflict:              pet.ErrDuplicate,
+	http.StatusPreconditionFailed:    pet.ErrConflict,
+	http.StatusRequestEntityTooLarge: pet.ErrTooLarge,
+	http.StatusUnsupportedMediaType:  pet.ErrUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   pet.ErrReference,
+	http.StatusNotImplemented:        pet.ErrUnsupported,
+	http.StatusGatewayTimeout:        pet.ErrTimeout,
+}
+
+// decodeError decodes the problem details of an error response into a pet Error.
+// Responses without problem details, such as those of proxies, are described by their status.
+func decodeError(resp *http.Response) error {
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" || mediaType == "application/json" {
+		var problem pet.Problem
+		if err := json.Unmarshal(data, &problem); err == nil && problem.Code != "" {
+			code, _ := pet.ParseErrorCode(problem.Code)
+			petErr := pet.Errorf(code, "%s", problem.Detail)
+			petErr.Fields = problem.Errors
+			return petErr
+		}
+	}
+	code, ok := statusCodes[resp.StatusCode]
+	if !ok {
+		code = pet.ErrUnknown
+	}
+	return pet.Errorf(code, "Server responded %s", resp.Status)
+}
+
+// drain reads the rest of a response body and closes it, so that its connection is reused
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxErrorBody))
+	resp.Body.Close()
+}
+
+func petPath(petID uint32) string {
+	return "/api/pet/" + strconv.FormatUint(uint64(petID), 10)
+}
+
+// CreatePet creates a pet, setting the ID the server allocated and the version it
+// stored the pet with on the pet passed in
+func (c *Client) CreatePet(ctx context.Context, p *pet.Pet) error {
+	var created pet.Pet
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: "/api/pet", body: p}, &created); err != nil {
+		return err
+	}
+	p.ID, p.Version = created.ID, created.Version
+	return nil
+}
+
+// ReadPet reads the pet with the given ID
+func (c *Client) ReadPet(ctx context.Context, petID uint32) (*pet.Pet, error) {
+	var read pet.Pet
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: petPath(petID), retry: true}, &read); err != nil {
+		return nil, err
+	}
+	return &read, nil
+}
+
+// UpdatePet creates or replaces the pet with the given ID if pre holds, setting the
+// version the server stored the pet with on the pet passed in
+func (c *Client) UpdatePet(ctx context.Context, petID uint32, p *pet.Pet, pre pet.Precondition) error {
+	body := *p
+	body.ID = petID
+	header, conditional := preconditionHeader(pre)
+	resp, err := c.do(ctx, request{
+		method: http.MethodPut,
+		path:   petPath(petID),
+		header: header,
+		body:   &body,
+		retry:  !conditional,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	p.Version = parseETag(resp.Header.Get("ETag"))
+	return nil
+}
+
+// DeletePet deletes the pet with the given ID if pre holds, reporting whether there was one
+func (c *Client) DeletePet(ctx context.Context, petID uint32, pre pet.Precondition) (bool, error) {
+	header, conditional := preconditionHeader(pre)
+	resp, err := c.do(ctx, request{method: http.MethodDelete, path: petPath(petID), header: header, retry: !conditional}, nil)
+	if err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// ListPets returns a page of the pets matching query
+func (c *Client) ListPets(ctx context.Context, query pet.ListQuery) (*pet.PetPage, error) {
+	params := url.Values{}
+	setParam(params, "owner", query.Owner)
+	setParam(params, "species", query.Species)
+	setParam(params, "name_prefix", query.NamePrefix)
+	setParam(params, "sort", query.Sort)
+	setParam(params, "cursor", query.Cursor)
+	if query.OwnerID != 0 {
+		params.Set("owner_id", strconv.FormatUint(uint64(query.OwnerID), 10))
+	}
+	if query.Limit != 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+	var page pet.PetPage
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: "/api/pet", query: params, retry: true}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+func setParam(params url.Values, name, value string) {
+	if value != "" {
+		params.Set(name, value)
+	}
+}
+
+// Batch applies a batch of operations, atomically if atomic is set. The failures of
+// the operations of a best effort batch are reported as *pet.Error values in the results.
+func (c *Client) Batch(ctx context.Context, ops []pet.BatchOperation, atomic bool) ([]pet.BatchResult, error) {
+	mode := "atomic"
+	if !atomic {
+		mode = "best_effort"
+	}
+	if ops == nil {
+		ops = []pet.BatchOperation{}
+	}
+	var response pet.BatchResponse
+	_, err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/api/pet:batch",
+		query:  url.Values{"mode": {mode}},
+		body:   ops,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]pet.BatchResult, len(response.Results))
+	for i, item := range response.Results {
+		if item.Status >= 400 {
+			code, _ := pet.ParseErrorCode(item.Code)
+			itemErr := pet.Errorf(code, "%s", item.Detail)
+			itemErr.Fields = item.Errors
+			results[i].Err = itemErr
+			continue
+		}
+		results[i].Pet = item.Pet
+		results[i].Deleted = item.Status == http.StatusOK
+	}
+	return results, nil
+}
+
+// CheckHealth checks that the server is ready to serve requests
+func (c *Client) CheckHealth(ctx context.Context) error {
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/readyz"}, nil)
+	return err
+}
+
+// preconditionHeader returns the If-Match and If-None-Match headers of a precondition,
+// and whether it places any condition on the request
+func preconditionHeader(pre pet.Precondition) (http.Header, bool) {
+	header := http.Header{}
+	if ifMatch := formatETags(pre.IfMatch, pre.IfMatchAny); ifMatch != "" {
+		header.Set("If-Match", ifMatch)
+	}
+	if ifNoneMatch := formatETags(pre.IfNoneMatch, pre.IfNoneMatchAny); ifNoneMatch != "" {
+		header.Set("If-None-Match", ifNoneMatch)
+	}
+	return header, len(header) > 0
+}
+
+// formatETags formats pet versions as a list of entity tags, or "*" if any is set
+func formatETags(versions []uint64, any bool) string {
+	if any {
+		return "*"
+	}
+	tags := make([]string, len(versions))
+	for i, version := range versions {
+		tags[i] = `"` + strconv.FormatUint(version, 10) + `"`
+	}
+	return strings.Join(tags, ", ")
+}
+
+// parseETag returns the pet version of an entity tag, 0 if it is not one
+func parseETag(tag string) uint64 {
+	version, _ := strconv.ParseUint(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), 10, 64)
+	return version
+}
@@ -0,0 +1,189 @@
+package petclient_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+	"github.service.anz/go/samplerest/pkg/pet/pettest"
+	"github.service.anz/go/samplerest/pkg/petclient"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newServer serves the pet API on top of a new in-memory store
+func newServer() *httptest.Server {
+	router := chi.NewRouter()
+	store := pet.NewMemStore()
+	pet.SetupRoutes(router, pet.NewPetService(store))
+	pet.SetupHealthRoutes(router, pet.NewHealth(store))
+	return httptest.NewServer(router)
+}
+
+func newClient(t *testing.T, cfg petclient.Config) *petclient.Client {
+	client, err := petclient.New(cfg)
+	require.NoError(t, err)
+	return client
+}
+
+func TestClient(t *testing.T) {
+	var servers []*httptest.Server
+	defer func() {
+		for _, server := range servers {
+			server.Close()
+		}
+	}()
+	pettest.Run(t, func() pet.Storer {
+		server := newServer()
+		servers = append(servers, server)
+		return newClient(t, petclient.Config{BaseURL: server.URL})
+	})
+}
+
+func TestClient_DecodesErrors(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	server := newServer()
+	defer server.Close()
+	client := newClient(t, petclient.Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	// when
+	_, readErr := client.ReadPet(ctx, 404)
+	createErr := client.CreatePet(ctx, &pet.Pet{Name: "Nemo"})
+
+	// then
+	assert.True(errors.Is(readErr, pet.ErrNotFoundSentinel), "Missing pet should be an ErrNotFound error")
+	var petErr *pet.Error
+	if assert.True(errors.As(createErr, &petErr), "Invalid pet should be a pet Error") {
+		assert.Equal(pet.ErrInvalidInput, petErr.Code)
+		assert.Equal([]pet.FieldError{{Field: "species", Message: "is required"}}, petErr.Fields)
+	}
+	assert.NoError(client.CheckHealth(ctx), "Server should be ready")
+}
+
+func TestClient_ErrorWithoutProblem(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such route", http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := newClient(t, petclient.Config{BaseURL: server.URL})
+
+	// when
+	_, err := client.ReadPet(context.Background(), 1)
+
+	// then
+	tassert.True(t, errors.Is(err, pet.ErrNotFoundSentinel), "Status should be mapped to an error code")
+}
+
+func TestClient_DuplicateAndConflict(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	server := newServer()
+	defer server.Close()
+	client := newClient(t, petclient.Config{BaseURL: server.URL})
+	ctx := context.Background()
+	require.NoError(t, client.CreatePet(ctx, &pet.Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+
+	// when
+	duplicateErr := client.CreatePet(ctx, &pet.Pet{ID: 1, Name: "Nemo", Species: "Clownfish"})
+	conflictErr := client.UpdatePet(ctx, 1, &pet.Pet{ID: 1, Name: "Dory", Species: "Blue tang"}, pet.Precondition{IfMatch: []uint64{7}})
+
+	// then
+	assert.True(errors.Is(duplicateErr, pet.ErrDuplicateSentinel), "Existing pet should be an ErrDuplicate error, got %v", duplicateErr)
+	assert.False(errors.Is(duplicateErr, pet.ErrConflictSentinel), "Existing pet should not be a version conflict")
+	assert.True(errors.Is(conflictErr, pet.ErrConflictSentinel), "Version mismatch should be an ErrConflict error, got %v", conflictErr)
+}
+
+func TestClient_StatusesWithoutProblem(t *testing.T) {
+	tests := map[int]*pet.Error{
+		http.StatusConflict:           pet.ErrDuplicateSentinel,
+		http.StatusPreconditionFailed: pet.ErrConflictSentinel,
+	}
+	for status, sentinel := range tests {
+		// given
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "proxy error", status)
+		}))
+		client := newClient(t, petclient.Config{BaseURL: server.URL})
+
+		// when
+		err := client.CreatePet(context.Background(), &pet.Pet{ID: 1, Name: "Nemo", Species: "Clownfish"})
+
+		// then
+		tassert.True(t, errors.Is(err, sentinel), "Status %d should be mapped to %v, got %v", status, sentinel, err)
+		server.Close()
+	}
+}
+
+// flakyTransport answers the first failures requests with 503 Service Unavailable
+type flakyTransport struct {
+	sync.Mutex
+	failures int
+	requests int
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.Lock()
+	f.requests++
+	fail := f.requests <= f.failures
+	f.Unlock()
+	if !fail {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	recorder := httptest.NewRecorder()
+	recorder.WriteHeader(http.StatusServiceUnavailable)
+	return recorder.Result(), nil
+}
+
+func TestClient_Retries(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	server := newServer()
+	defer server.Close()
+	transport := &flakyTransport{failures: 2}
+	client := newClient(t, petclient.Config{BaseURL: server.URL, Retries: 2, Backoff: time.Millisecond, Transport: transport})
+	ctx := context.Background()
+
+	// when
+	_, listErr := client.ListPets(ctx, pet.ListQuery{})
+	transport.requests = 0
+	createErr := client.CreatePet(ctx, &pet.Pet{Name: "Nemo", Species: "Clownfish"})
+
+	// then
+	assert.NoError(listErr, "List should succeed once retried")
+	assert.Error(createErr, "Create should not be retried")
+	assert.Equal(1, transport.requests, "Create should be attempted once")
+}
+
+func TestClient_Timeout(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	client := newClient(t, petclient.Config{BaseURL: server.URL, Timeout: 20 * time.Millisecond})
+
+	// when
+	_, err := client.ReadPet(context.Background(), 1)
+
+	// then
+	tassert.True(t, errors.Is(err, pet.ErrTimeoutSentinel), "Slow response should time out, got %v", err)
+}
+
+func TestNew_InvalidBaseURL(t *testing.T) {
+	_, err := petclient.New(petclient.Config{BaseURL: "localhost:4852"})
+	tassert.Error(t, err, "Base URL without a scheme should be rejected")
+}
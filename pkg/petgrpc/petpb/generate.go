@@ -0,0 +1,5 @@
+// Package petpb holds the protobuf messages and gRPC service of the pet API,
+// generated from pet.proto
+package petpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative pet.proto
@@ -0,0 +1,334 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: pet.proto
+
+package petpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	PetService_Get_FullMethodName    = "/samplerest.pet.v1.PetService/Get"
+	PetService_Create_FullMethodName = "/samplerest.pet.v1.PetService/Create"
+	PetService_Update_FullMethodName = "/samplerest.pet.v1.PetService/Update"
+	PetService_Delete_FullMethodName = "/samplerest.pet.v1.PetService/Delete"
+	PetService_List_FullMethodName   = "/samplerest.pet.v1.PetService/List"
+	PetService_Watch_FullMethodName  = "/samplerest.pet.v1.PetService/Watch"
+)
+
+// PetServiceClient is the client API for PetService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PetServiceClient interface {
+	// Get reads a pet
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Pet, error)
+	// Create creates a pet, allocating an ID if it has none
+	Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*Pet, error)
+	// Update creates or replaces a pet, conditionally on its version
+	Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Pet, error)
+	// Delete deletes a pet, conditionally on its version
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// List lists the pets matching the filters, a page at a time
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Watch streams pet changes until the client cancels the call
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (PetService_WatchClient, error)
+}
+
+type petServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPetServiceClient(cc grpc.ClientConnInterface) PetServiceClient {
+	return &petServiceClient{cc}
+}
+
+func (c *petServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, PetService_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) Create(ctx context.Context, in *CreateRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, PetService_Create_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) Update(ctx context.Context, in *UpdateRequest, opts ...grpc.CallOption) (*Pet, error) {
+	out := new(Pet)
+	err := c.cc.Invoke(ctx, PetService_Update_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, PetService_Delete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, PetService_List_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *petServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (PetService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &PetService_ServiceDesc.Streams[0], PetService_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &petServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type PetService_WatchClient interface {
+	Recv() (*PetEvent, error)
+	grpc.ClientStream
+}
+
+type petServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *petServiceWatchClient) Recv() (*PetEvent, error) {
+	m := new(PetEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PetServiceServer is the server API for PetService service.
+// All implementations must embed UnimplementedPetServiceServer
+// for forward compatibility
+type PetServiceServer interface {
+	// Get reads a pet
+	Get(context.Context, *GetRequest) (*Pet, error)
+	// Create creates a pet, allocating an ID if it has none
+	Create(context.Context, *CreateRequest) (*Pet, error)
+	// Update creates or replaces a pet, conditionally on its version
+	Update(context.Context, *UpdateRequest) (*Pet, error)
+	// Delete deletes a pet, conditionally on its version
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// List lists the pets matching the filters, a page at a time
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Watch streams pet changes until the client cancels the call
+	Watch(*WatchRequest, PetService_WatchServer) error
+	mustEmbedUnimplementedPetServiceServer()
+}
+
+// UnimplementedPetServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedPetServiceServer struct {
+}
+
+func (UnimplementedPetServiceServer) Get(context.Context, *GetRequest) (*Pet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedPetServiceServer) Create(context.Context, *CreateRequest) (*Pet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Create not implemented")
+}
+func (UnimplementedPetServiceServer) Update(context.Context, *UpdateRequest) (*Pet, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Update not implemented")
+}
+func (UnimplementedPetServiceServer) Delete(context.Context, *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedPetServiceServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedPetServiceServer) Watch(*WatchRequest, PetService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedPetServiceServer) mustEmbedUnimplementedPetServiceServer() {}
+
+// UnsafePetServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PetServiceServer will
+// result in compilation errors.
+type UnsafePetServiceServer interface {
+	mustEmbedUnimplementedPetServiceServer()
+}
+
+func RegisterPetServiceServer(s grpc.ServiceRegistrar, srv PetServiceServer) {
+	s.RegisterService(&PetService_ServiceDesc, srv)
+}
+
+func _PetService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_Create_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).Create(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetService_Create_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).Create(ctx, req.(*CreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_Update_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).Update(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetService_Update_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).Update(ctx, req.(*UpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetService_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PetServiceServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PetService_List_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PetServiceServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _PetService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PetServiceServer).Watch(m, &petServiceWatchServer{stream})
+}
+
+type PetService_WatchServer interface {
+	Send(*PetEvent) error
+	grpc.ServerStream
+}
+
+type petServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *petServiceWatchServer) Send(m *PetEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// PetService_ServiceDesc is the grpc.ServiceDesc for PetService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var PetService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "samplerest.pet.v1.PetService",
+	HandlerType: (*PetServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _PetService_Get_Handler,
+		},
+		{
+			MethodName: "Create",
+			Handler:    _PetService_Create_Handler,
+		},
+		{
+			MethodName: "Update",
+			Handler:    _PetService_Update_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _PetService_Delete_Handler,
+		},
+		{
+			MethodName: "List",
+			Handler:    _PetService_List_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _PetService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pet.proto",
+}
@@ -0,0 +1,244 @@
+// Package petgrpc serves the pet operations of the REST API over gRPC, on top of the
+// same pet.Storer
+package petgrpc
+
+import (
+	"context"
+	"sync"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+	"github.service.anz/go/samplerest/pkg/petgrpc/petpb"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements petpb.PetServiceServer on top of a pet.Storer
+type Server struct {
+	petpb.UnimplementedPetServiceServer
+	store pet.Storer
+	hub   *pet.EventHub
+	// Validator checks pets before they are written, nil applies the default rules
+	Validator *pet.Validator
+	// Logger logs failed calls, defaults to the standard logger
+	Logger log.FieldLogger
+
+	stopOnce sync.Once
+	stopped  chan struct{}
+}
+
+// NewServer creates a server of the pets in store. Watch streams the events of hub,
+// and is unimplemented if hub is nil.
+func NewServer(store pet.Storer, hub *pet.EventHub) *Server {
+	return &Server{store: store, hub: hub, stopped: make(chan struct{})}
+}
+
+// Register registers the pet service with a gRPC server
+func Register(g *grpc.Server, s *Server) {
+	petpb.RegisterPetServiceServer(g, s)
+}
+
+// Stop ends the Watch streams, which would otherwise keep a graceful stop of the gRPC
+// server waiting. Clients resume them from another server.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stopped)
+	})
+}
+
+func (s *Server) logger() log.FieldLogger {
+	if s.Logger == nil {
+		return log.StandardLogger()
+	}
+	return s.Logger
+}
+
+// Get reads a pet
+func (s *Server) Get(ctx context.Context, req *petpb.GetRequest) (*petpb.Pet, error) {
+	read, err := s.store.ReadPet(ctx, req.GetId())
+	if err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	return s.reply(ctx, read)
+}
+
+// Create creates a pet, allocating an ID if it has none
+func (s *Server) Create(ctx context.Context, req *petpb.CreateRequest) (*petpb.Pet, error) {
+	newPet := fromProto(req.GetPet())
+	if err := s.Validator.Validate(newPet); err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	if err := s.store.CreatePet(ctx, newPet); err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	return s.reply(ctx, newPet)
+}
+
+// Update creates or replaces a pet, conditionally on its version.
+// A pet ID in the request pet must match the ID of the request.
+func (s *Server) Update(ctx context.Context, req *petpb.UpdateRequest) (*petpb.Pet, error) {
+	newPet := fromProto(req.GetPet())
+	if newPet.ID != 0 && newPet.ID != req.GetId() {
+		return nil, s.fail(ctx, pet.Errorf(pet.ErrInvalidInput, "The pet ID %d does not match the ID %d of the request", newPet.ID, req.GetId()).
+			AddField("pet.id", "should match the ID of the request"))
+	}
+	newPet.ID = req.GetId()
+	if err := s.Validator.Validate(newPet); err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	if err := s.store.UpdatePet(ctx, newPet.ID, newPet, fromProtoPrecondition(req.GetPrecondition())); err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	return s.reply(ctx, newPet)
+}
+
+// Delete deletes a pet, conditionally on its version
+func (s *Server) Delete(ctx context.Context, req *petpb.DeleteRequest) (*petpb.DeleteResponse, error) {
+	deleted, err := s.store.DeletePet(ctx, req.GetId(), fromProtoPrecondition(req.GetPrecondition()))
+	if err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	return &petpb.DeleteResponse{Deleted: deleted}, nil
+}
+
+// List lists the pets matching the filters of the request, a page at a time
+func (s *Server) List(ctx context.Context, req *petpb.ListRequest) (*petpb.ListResponse, error) {
+	page, err := s.store.ListPets(ctx, pet.ListQuery{
+		Owner:      req.GetOwner(),
+		OwnerID:    req.GetOwnerId(),
+		Species:    req.GetSpecies(),
+		NamePrefix: req.GetNamePrefix(),
+		Sort:       req.GetSort(),
+		Limit:      int(req.GetLimit()),
+		Cursor:     req.GetCursor(),
+	})
+	if err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	resp := &petpb.ListResponse{Pets: make([]*petpb.Pet, len(page.Pets)), NextCursor: page.NextCursor}
+	for i := range page.Pets {
+		if resp.Pets[i], err = toProto(&page.Pets[i]); err != nil {
+			return nil, s.fail(ctx, err)
+		}
+	}
+	return resp, nil
+}
+
+// eventTypes maps the types of pet events to their protobuf enum
+var eventTypes = map[string]petpb.PetEvent_Type{
+	pet.EventCreated: petpb.PetEvent_CREATED,
+	pet.EventUpdated: petpb.PetEvent_UPDATED,
+	pet.EventDeleted: petpb.PetEvent_DELETED,
+	pet.EventReset:   petpb.PetEvent_RESET,
+}
+
+// Watch streams pet events until the client cancels the call or the server stops.
+// A stream resuming after missed events starts with a reset event. A stream that
+// falls behind fails with Unavailable, and the client resumes it.
+func (s *Server) Watch(req *petpb.WatchRequest, stream petpb.PetService_WatchServer) error {
+	ctx := stream.Context()
+	if s.hub == nil {
+		return s.fail(ctx, pet.Errorf(pet.ErrUnsupported, "Pet events are not published by this server"))
+	}
+	sub, replay, complete := s.hub.Subscribe(req.GetLastEventId(), req.LastEventId != nil)
+	defer sub.Close()
+	if !complete {
+		if err := stream.Send(&petpb.PetEvent{Type: petpb.PetEvent_RESET}); err != nil {
+			return err
+		}
+	}
+	for _, event := range replay {
+		if err := s.sendEvent(stream, event); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-s.stopped:
+			return status.Error(codes.Unavailable, "Server is stopping, resume the stream")
+		case event, ok := <-sub.Events:
+			if !ok {
+				return status.Error(codes.Unavailable, "Stream fell behind, resume it from the last event received")
+			}
+			if err := s.sendEvent(stream, event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) sendEvent(stream petpb.PetService_WatchServer, event pet.Event) error {
+	msg := &petpb.PetEvent{
+		Id:    event.ID,
+		Type:  eventTypes[event.Type],
+		PetId: event.PetID,
+		Time:  timestamppb.New(event.Time),
+	}
+	if event.Pet != nil {
+		var err error
+		if msg.Pet, err = toProto(event.Pet); err != nil {
+			return s.fail(stream.Context(), err)
+		}
+	}
+	return stream.Send(msg)
+}
+
+// reply converts a pet to the response of a call
+func (s *Server) reply(ctx context.Context, p *pet.Pet) (*petpb.Pet, error) {
+	msg, err := toProto(p)
+	if err != nil {
+		return nil, s.fail(ctx, err)
+	}
+	return msg, nil
+}
+
+// toProto converts a pet to its protobuf message. It fails if the extra data of the
+// pet holds values that have no JSON representation.
+func toProto(p *pet.Pet) (*petpb.Pet, error) {
+	msg := &petpb.Pet{
+		Id:      p.ID,
+		Name:    p.Name,
+		Species: p.Species,
+		Owner:   p.Owner,
+		OwnerId: p.OwnerID,
+		Version: p.Version,
+	}
+	if p.Extra != nil {
+		extra, err := structpb.NewStruct(p.Extra)
+		if err != nil {
+			return nil, pet.ErrorEf(pet.ErrUnknown, err, "Extra data of pet %d cannot be converted", p.ID)
+		}
+		msg.Extra = extra
+	}
+	return msg, nil
+}
+
+// fromProto converts a protobuf message to a pet, a nil message to an empty pet
+func fromProto(msg *petpb.Pet) *pet.Pet {
+	p := &pet.Pet{
+		ID:      msg.GetId(),
+		Name:    msg.GetName(),
+		Species: msg.GetSpecies(),
+		Owner:   msg.GetOwner(),
+		OwnerID: msg.GetOwnerId(),
+	}
+	if msg.GetExtra() != nil {
+		p.Extra = msg.GetExtra().AsMap()
+	}
+	return p
+}
+
+func fromProtoPrecondition(msg *petpb.Precondition) pet.Precondition {
+	return pet.Precondition{
+		IfMatch:        msg.GetIfMatch(),
+		IfMatchAny:     msg.GetIfMatchAny(),
+		IfNoneMatch:    msg.GetIfNoneMatch(),
+		IfNoneMatchAny: msg.GetIfNoneMatchAny(),
+	}
+}
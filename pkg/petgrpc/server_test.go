@@ -0,0 +1,221 @@
+package petgrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+	"github.service.anz/go/samplerest/pkg/petgrpc"
+	"github.service.anz/go/samplerest/pkg/petgrpc/petpb"
+
+	tassert "github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// newClient serves a pet server over an in-memory connection, returning a client of it
+// and a function stopping both
+func newClient(t *testing.T, server *petgrpc.Server) (petpb.PetServiceClient, func()) {
+	listener := bufconn.Listen(1 << 20)
+	g := grpc.NewServer()
+	petgrpc.Register(g, server)
+	go g.Serve(listener)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	return petpb.NewPetServiceClient(conn), func() {
+		conn.Close()
+		server.Stop()
+		g.GracefulStop()
+	}
+}
+
+func TestServer_CRUD(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	client, stop := newClient(t, petgrpc.NewServer(pet.NewMemStore(), nil))
+	defer stop()
+	ctx := context.Background()
+	extra, err := structpb.NewStruct(map[string]interface{}{"fins": 3.0})
+	require.NoError(t, err)
+
+	// when
+	created, createErr := client.Create(ctx, &petpb.CreateRequest{Pet: &petpb.Pet{Name: "Nemo", Species: "Clownfish", Extra: extra}})
+	updated, updateErr := client.Update(ctx, &petpb.UpdateRequest{
+		Id:           1,
+		Pet:          &petpb.Pet{Name: "Nemo", Species: "Clownfish", Owner: "Marlin"},
+		Precondition: &petpb.Precondition{IfMatch: []uint64{1}},
+	})
+	read, readErr := client.Get(ctx, &petpb.GetRequest{Id: 1})
+	list, listErr := client.List(ctx, &petpb.ListRequest{Owner: "Marlin"})
+	deleted, deleteErr := client.Delete(ctx, &petpb.DeleteRequest{Id: 1})
+	deletedAgain, deleteAgainErr := client.Delete(ctx, &petpb.DeleteRequest{Id: 1})
+
+	// then
+	require.NoError(t, createErr)
+	assert.Equal(uint32(1), created.GetId(), "Created pet should be given an ID")
+	assert.Equal(map[string]interface{}{"fins": 3.0}, created.GetExtra().AsMap())
+	require.NoError(t, updateErr)
+	assert.Equal(uint64(2), updated.GetVersion())
+	require.NoError(t, readErr)
+	assert.Equal("Marlin", read.GetOwner())
+	assert.Nil(read.GetExtra(), "Replaced pet should have no extra data")
+	require.NoError(t, listErr)
+	assert.Len(list.GetPets(), 1)
+	require.NoError(t, deleteErr)
+	assert.True(deleted.GetDeleted())
+	require.NoError(t, deleteAgainErr)
+	assert.False(deletedAgain.GetDeleted(), "Missing pet should not be deleted")
+}
+
+func TestServer_Errors(t *testing.T) {
+	// given
+	client, stop := newClient(t, petgrpc.NewServer(pet.NewMemStore(), nil))
+	defer stop()
+	ctx := context.Background()
+	_, err := client.Create(ctx, &petpb.CreateRequest{Pet: &petpb.Pet{Name: "Nemo", Species: "Clownfish"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		call      func() error
+		code      codes.Code
+		reason    string
+		badFields []string
+	}{
+		{
+			call:   func() error { _, err := client.Get(ctx, &petpb.GetRequest{Id: 2}); return err },
+			code:   codes.NotFound,
+			reason: "not_found",
+		},
+		{
+			call: func() error {
+				_, err := client.Create(ctx, &petpb.CreateRequest{Pet: &petpb.Pet{Id: 1, Name: "Nemo", Species: "Clownfish"}})
+				return err
+			},
+			code:   codes.AlreadyExists,
+			reason: "duplicate",
+		},
+		{
+			call:      func() error { _, err := client.Create(ctx, &petpb.CreateRequest{Pet: &petpb.Pet{}}); return err },
+			code:      codes.InvalidArgument,
+			reason:    "invalid_input",
+			badFields: []string{"name", "species"},
+		},
+		{
+			call: func() error {
+				_, err := client.Update(ctx, &petpb.UpdateRequest{Id: 1, Pet: &petpb.Pet{Id: 2, Name: "Nemo", Species: "Clownfish"}})
+				return err
+			},
+			code:      codes.InvalidArgument,
+			reason:    "invalid_input",
+			badFields: []string{"pet.id"},
+		},
+		{
+			call: func() error {
+				_, err := client.Delete(ctx, &petpb.DeleteRequest{Id: 1, Precondition: &petpb.Precondition{IfMatch: []uint64{7}}})
+				return err
+			},
+			code:   codes.FailedPrecondition,
+			reason: "conflict",
+		},
+		{
+			call: func() error {
+				stream, err := client.Watch(ctx, &petpb.WatchRequest{})
+				if err == nil {
+					_, err = stream.Recv()
+				}
+				return err
+			},
+			code:   codes.Unimplemented,
+			reason: "unsupported",
+		},
+	}
+	for _, test := range tests {
+		// when
+		st := status.Convert(test.call())
+
+		// then
+		tassert.Equal(t, test.code, st.Code(), st.Message())
+		var reason string
+		var badFields []string
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.ErrorInfo:
+				reason = d.GetReason()
+			case *errdetails.BadRequest:
+				for _, violation := range d.GetFieldViolations() {
+					badFields = append(badFields, violation.GetField())
+				}
+			}
+		}
+		tassert.Equal(t, test.reason, reason, "Reason of %s", st.Message())
+		tassert.Equal(t, test.badFields, badFields, "Invalid fields of %s", st.Message())
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	hub := pet.NewEventHub(0)
+	store := pet.NewPublishingStore(pet.NewMemStore(), hub)
+	client, stop := newClient(t, petgrpc.NewServer(store, hub))
+	defer stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, store.CreatePet(ctx, &pet.Pet{ID: 1, Name: "Nemo", Species: "Clownfish"}))
+
+	// when
+	var lastEventID uint64
+	stream, err := client.Watch(ctx, &petpb.WatchRequest{LastEventId: &lastEventID})
+	require.NoError(t, err)
+	replayed, replayErr := stream.Recv()
+	_, err = store.DeletePet(ctx, 1, pet.Precondition{})
+	require.NoError(t, err)
+	live, liveErr := stream.Recv()
+
+	// then
+	require.NoError(t, replayErr)
+	assert.Equal(petpb.PetEvent_CREATED, replayed.GetType())
+	assert.Equal("Nemo", replayed.GetPet().GetName())
+	require.NoError(t, liveErr)
+	assert.Equal(uint64(2), live.GetId())
+	assert.Equal(petpb.PetEvent_DELETED, live.GetType())
+	assert.Nil(live.GetPet(), "Deleted event should carry no pet")
+}
+
+func TestServer_WatchReset(t *testing.T) {
+	// given
+	hub := pet.NewEventHub(1)
+	hub.Publish(pet.EventCreated, 1, &pet.Pet{ID: 1})
+	hub.Publish(pet.EventCreated, 2, &pet.Pet{ID: 2})
+	server := petgrpc.NewServer(pet.NewMemStore(), hub)
+	client, stop := newClient(t, server)
+	defer stop()
+	var lastEventID uint64
+
+	// when
+	stream, err := client.Watch(context.Background(), &petpb.WatchRequest{LastEventId: &lastEventID})
+	require.NoError(t, err)
+	var types []petpb.PetEvent_Type
+	for len(types) < 2 {
+		event, err := stream.Recv()
+		require.NoError(t, err)
+		types = append(types, event.GetType())
+	}
+	server.Stop()
+	_, stopErr := stream.Recv()
+
+	// then
+	tassert.Equal(t, []petpb.PetEvent_Type{petpb.PetEvent_RESET, petpb.PetEvent_CREATED}, types,
+		"Stream should start with a reset when events were missed")
+	tassert.Equal(t, codes.Unavailable, status.Code(stopErr), "Stopping the server should end the stream")
+}
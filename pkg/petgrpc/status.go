@@ -0,0 +1,100 @@
+package petgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.service.anz/go/samplerest/pkg/pet"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain is the domain of the ErrorInfo details of failed calls
+const errorDomain = "samplerest"
+
+// grpcCodes maps the codes of pet errors to gRPC status codes, as errStatusMap of
+// package pet maps them to HTTP statuses. Other errors are Internal.
+var grpcCodes = map[int]codes.Code{
+	pet.ErrUnknown:              codes.Internal,
+	pet.ErrInvalidInput:         codes.InvalidArgument,
+	pet.ErrDuplicate:            codes.AlreadyExists,
+	pet.ErrNotFound:             codes.NotFound,
+	pet.ErrConflict:             codes.FailedPrecondition,
+	pet.ErrUnsupportedMediaType: codes.InvalidArgument,
+	pet.ErrCanceled:             codes.Canceled,
+	pet.ErrTimeout:              codes.DeadlineExceeded,
+	pet.ErrReference:            codes.FailedPrecondition,
+	pet.ErrUnsupported:          codes.Unimplemented,
+	pet.ErrTooLarge:             codes.ResourceExhausted,
+	pet.ErrNotAcceptable:        codes.InvalidArgument,
+	pet.ErrPatchTestFailed:      codes.FailedPrecondition,
+}
+
+// toStatus converts an error to a gRPC status error. Only the message of a pet Error
+// is disclosed, other errors may hold internal details. The error code of the REST
+// API is attached as the reason of an ErrorInfo, and invalid fields as a BadRequest.
+func toStatus(err error) error {
+	code, errorCode, message := codes.Internal, pet.ErrUnknown, "Internal server error"
+	var fields []pet.FieldError
+	var petErr *pet.Error
+	if errors.As(err, &petErr) {
+		errorCode, message, fields = petErr.Code, petErr.Message, petErr.Fields
+		if grpcCode, ok := grpcCodes[petErr.Code]; ok {
+			code = grpcCode
+		}
+	}
+	st := status.New(code, message)
+	info := &errdetails.ErrorInfo{Reason: pet.ErrorCodeName(errorCode), Domain: errorDomain}
+	if len(fields) == 0 {
+		return withDetails(st, info)
+	}
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(fields))
+	for i, field := range fields {
+		violations[i] = &errdetails.BadRequest_FieldViolation{Field: field.Field, Description: field.Message}
+	}
+	return withDetails(st, info, &errdetails.BadRequest{FieldViolations: violations})
+}
+
+// withDetails attaches details to a status, leaving them out if they cannot be encoded
+func withDetails(st *status.Status, details ...protoadapt.MessageV1) error {
+	detailed, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// fail logs an error of a call, returning it as a gRPC status error
+func (s *Server) fail(ctx context.Context, err error) error {
+	statusErr := toStatus(err)
+	method, _ := grpc.Method(ctx)
+	entry := s.logger().WithFields(log.Fields{
+		"method":     method,
+		"grpc_code":  status.Code(statusErr).String(),
+		"error_code": errorCodeName(err),
+		"error":      err.Error(),
+	})
+	if cause := errors.Unwrap(err); cause != nil {
+		entry = entry.WithField("cause", cause.Error())
+	}
+	if status.Code(statusErr) == codes.Internal {
+		entry.Error("Call failed")
+	} else {
+		entry.Info("Call rejected")
+	}
+	return statusErr
+}
+
+// errorCodeName returns the name of the pet error code of err
+func errorCodeName(err error) string {
+	var petErr *pet.Error
+	if errors.As(err, &petErr) {
+		return pet.ErrorCodeName(petErr.Code)
+	}
+	return pet.ErrorCodeName(pet.ErrUnknown)
+}
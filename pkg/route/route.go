@@ -0,0 +1,25 @@
+// Package route names the chi route that handled a request, for the logs, metrics and
+// traces of the request.
+package route
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// Pattern returns the chi route pattern that handled the request, without the
+// trailing slash left by mounting a handler on "/" of a sub-router. It is empty if
+// the request was not routed by chi or matched no route.
+func Pattern(r *http.Request) string {
+	rctx, ok := r.Context().Value(chi.RouteCtxKey).(*chi.Context)
+	if !ok {
+		return ""
+	}
+	pattern := rctx.RoutePattern()
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	return pattern
+}
@@ -0,0 +1,55 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+func TestPattern(t *testing.T) {
+	// given
+	var pattern string
+	record := func(w http.ResponseWriter, r *http.Request) {}
+	router := chi.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			pattern = Pattern(r)
+		})
+	})
+	router.Get("/", record)
+	router.Route("/api/pet", func(r chi.Router) {
+		r.Get("/", record)
+		r.Get("/{id}", record)
+	})
+	tests := map[string]string{
+		"/":            "/",
+		"/api/pet":     "/api/pet",
+		"/api/pet/7":   "/api/pet/{id}",
+		"/api/unknown": "",
+	}
+	for path, expected := range tests {
+		req, _ := http.NewRequest("GET", path, nil)
+
+		// when
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		// then
+		tassert.Equal(t, expected, pattern, "Pattern of %s", path)
+	}
+}
+
+func TestPattern_NotRouted(t *testing.T) {
+	// given
+	req, _ := http.NewRequest("GET", "/api/pet", nil)
+
+	// when
+	pattern := Pattern(req)
+
+	// then
+	tassert.Empty(t, pattern, "Requests not routed by chi should have no pattern")
+}
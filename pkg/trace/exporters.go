@@ -0,0 +1,52 @@
+package trace
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// WriterExporter writes every span as a line of JSON, for example to stdout
+type WriterExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriterExporter creates an exporter writing to w
+func NewWriterExporter(w io.Writer) *WriterExporter {
+	return &WriterExporter{enc: json.NewEncoder(w)}
+}
+
+// ExportSpan writes span as JSON, ignoring write errors
+func (e *WriterExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_ = e.enc.Encode(span)
+}
+
+// MemoryExporter keeps exported spans in memory, for tests
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// ExportSpan records span
+func (e *MemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans exported so far, in the order they ended
+func (e *MemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset forgets all exported spans
+func (e *MemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
@@ -0,0 +1,44 @@
+package trace
+
+import (
+	"net/http"
+
+	"github.service.anz/go/samplerest/pkg/route"
+
+	mw "github.com/go-chi/chi/middleware"
+)
+
+// Middleware starts a server span for every request, continuing the trace of the
+// traceparent header when there is one. The span is named after the method and chi
+// route pattern, so it should be used on a chi router.
+func Middleware(tracer *Tracer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(Extract(r.Context(), r.Header), "HTTP "+r.Method, KindServer)
+			defer span.End()
+			ww := mw.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if route := route.Pattern(r); route != "" {
+				span.SetName(r.Method + " " + route)
+				span.SetAttribute("http.route", route)
+			}
+			span.SetAttribute("http.method", r.Method)
+			span.SetAttribute("http.target", r.URL.RequestURI())
+			span.SetAttribute("http.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetError(errorStatus(status))
+			}
+		})
+	}
+}
+
+// errorStatus is the error recorded on spans of requests that failed on the server
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,259 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OTLPConfig configures an OTLPExporter
+type OTLPConfig struct {
+	// Endpoint is the URL spans are posted to, such as http://localhost:4318/v1/traces
+	Endpoint string
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string
+	// Client sends the requests, defaults to a client with a 10 second timeout
+	Client *http.Client
+	// BatchSize is the most spans sent in one request, defaults to 512
+	BatchSize int
+	// FlushInterval is the longest a span waits before it is sent, defaults to 5 seconds
+	FlushInterval time.Duration
+	// OnError is called when a batch cannot be sent or spans are dropped, may be nil
+	OnError func(error)
+}
+
+// OTLPExporter sends spans in batches to an OpenTelemetry collector using OTLP over
+// HTTP with JSON encoding. Spans are dropped when they arrive faster than they can be sent.
+type OTLPExporter struct {
+	cfg   OTLPConfig
+	spans chan SpanData
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// NewOTLPExporter creates an exporter and starts sending batches in the background.
+// Close must be called to send the remaining spans and stop.
+func NewOTLPExporter(cfg OTLPConfig) *OTLPExporter {
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 512
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	e := &OTLPExporter{
+		cfg:   cfg,
+		spans: make(chan SpanData, 4*cfg.BatchSize),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// ExportSpan queues span to be sent with the next batch
+func (e *OTLPExporter) ExportSpan(span SpanData) {
+	select {
+	case <-e.quit:
+	case e.spans <- span:
+	default:
+		e.report(fmt.Errorf("otlp: queue is full, dropped span %s", span.Name))
+	}
+}
+
+// Close sends the queued spans and stops the exporter
+func (e *OTLPExporter) Close() error {
+	select {
+	case <-e.quit:
+	default:
+		close(e.quit)
+	}
+	<-e.done
+	return nil
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	var batch []SpanData
+	for {
+		select {
+		case span := <-e.spans:
+			if batch = append(batch, span); len(batch) >= e.cfg.BatchSize {
+				e.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.send(batch)
+			batch = nil
+		case <-e.quit:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					for len(batch) > e.cfg.BatchSize {
+						e.send(batch[:e.cfg.BatchSize])
+						batch = batch[e.cfg.BatchSize:]
+					}
+					e.send(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch []SpanData) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(otlpRequest(e.cfg.ServiceName, batch))
+	if err != nil {
+		e.report(fmt.Errorf("otlp: could not encode spans: %v", err))
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, e.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		e.report(fmt.Errorf("otlp: invalid endpoint: %v", err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.cfg.Client.Do(req)
+	if err != nil {
+		e.report(fmt.Errorf("otlp: could not send %d spans: %v", len(batch), err))
+		return
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		e.report(fmt.Errorf("otlp: collector rejected %d spans with status %s", len(batch), resp.Status))
+	}
+}
+
+func (e *OTLPExporter) report(err error) {
+	if e.cfg.OnError != nil {
+		e.cfg.OnError(err)
+	}
+}
+
+// OTLP JSON encoding, see opentelemetry/proto/trace/v1/trace.proto.
+// IDs are hex encoded and 64 bit integers are strings.
+type (
+	otlpTraces struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// OTLP enum values and names
+const (
+	otlpKindInternal   = 1
+	otlpKindServer     = 2
+	otlpKindClient     = 3
+	otlpStatusError    = 2
+	otlpScopeName      = "github.service.anz/go/samplerest/pkg/trace"
+	otlpServiceNameKey = "service.name"
+)
+
+var otlpKinds = map[Kind]int{
+	KindInternal: otlpKindInternal,
+	KindServer:   otlpKindServer,
+	KindClient:   otlpKindClient,
+}
+
+func otlpRequest(serviceName string, batch []SpanData) *otlpTraces {
+	spans := make([]otlpSpan, len(batch))
+	for i, span := range batch {
+		spans[i] = otlpSpan{
+			TraceID:           span.TraceID.String(),
+			SpanID:            span.SpanID.String(),
+			Name:              span.Name,
+			Kind:              otlpKinds[span.Kind],
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(span.Attributes),
+		}
+		if span.ParentSpanID != (SpanID{}) {
+			spans[i].ParentSpanID = span.ParentSpanID.String()
+		}
+		if span.Error != "" {
+			spans[i].Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+		}
+	}
+	return &otlpTraces{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{otlpServiceNameKey: serviceName})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: otlpScopeName},
+			Spans: spans,
+		}},
+	}}}
+}
+
+// otlpAttributes converts attributes to OTLP key values, ordered by key
+func otlpAttributes(attributes map[string]interface{}) []otlpKeyValue {
+	var result []otlpKeyValue
+	for key, value := range attributes {
+		var encoded map[string]interface{}
+		switch v := value.(type) {
+		case bool:
+			encoded = map[string]interface{}{"boolValue": v}
+		case int:
+			encoded = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			encoded = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint32:
+			encoded = map[string]interface{}{"intValue": strconv.FormatUint(uint64(v), 10)}
+		case uint64:
+			encoded = map[string]interface{}{"intValue": strconv.FormatUint(v, 10)}
+		case float64:
+			encoded = map[string]interface{}{"doubleValue": v}
+		default:
+			encoded = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		result = append(result, otlpKeyValue{Key: key, Value: encoded})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
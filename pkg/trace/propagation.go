@@ -0,0 +1,91 @@
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/http"
+)
+
+// TraceparentHeader carries the span context between services, see https://www.w3.org/TR/trace-context/
+const TraceparentHeader = "traceparent"
+
+// traceparentLength is the length of a version 00 traceparent header
+const traceparentLength = 55
+
+const sampledFlag = 0x01
+
+// ParseTraceparent parses a traceparent header value
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+	if len(value) < traceparentLength || value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return sc, errors.New("malformed traceparent")
+	}
+	version, err := decodeHex(value[0:2], 1)
+	if err != nil || version[0] == 0xff {
+		return sc, errors.New("invalid traceparent version")
+	}
+	// later versions may append fields, version 00 may not
+	if (version[0] == 0 && len(value) != traceparentLength) || (len(value) > traceparentLength && value[traceparentLength] != '-') {
+		return sc, errors.New("malformed traceparent")
+	}
+	traceID, err := decodeHex(value[3:35], 16)
+	if err != nil {
+		return sc, errors.New("invalid traceparent trace ID")
+	}
+	spanID, err := decodeHex(value[36:52], 8)
+	if err != nil {
+		return sc, errors.New("invalid traceparent parent ID")
+	}
+	flags, err := decodeHex(value[53:55], 1)
+	if err != nil {
+		return sc, errors.New("invalid traceparent flags")
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&sampledFlag != 0
+	if !sc.IsValid() {
+		return sc, errors.New("traceparent IDs should not be zero")
+	}
+	return sc, nil
+}
+
+// decodeHex decodes n bytes of lowercase hex
+func decodeHex(s string, n int) ([]byte, error) {
+	for _, c := range s {
+		if c >= 'A' && c <= 'F' {
+			return nil, errors.New("uppercase hex")
+		}
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != n {
+		return nil, errors.New("invalid hex")
+	}
+	return b, nil
+}
+
+// FormatTraceparent formats a span context as a version 00 traceparent header value
+func FormatTraceparent(sc SpanContext) string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// Extract returns a context continuing the trace of a valid traceparent header, and ctx
+// unchanged otherwise
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemote(ctx, sc)
+}
+
+// Inject sets the traceparent header to the current span in ctx, if there is one
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		header.Set(TraceparentHeader, FormatTraceparent(sc))
+	}
+}
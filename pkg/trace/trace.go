@@ -0,0 +1,220 @@
+// Package trace records spans of work done for a request and propagates their
+// trace context between services with the W3C traceparent header.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TraceID identifies all spans of a trace
+type TraceID [16]byte
+
+// String returns the trace ID as lowercase hex
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// MarshalText encodes the trace ID as lowercase hex
+func (t TraceID) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the span ID as lowercase hex
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// MarshalText encodes the span ID as lowercase hex
+func (s SpanID) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// SpanContext is the part of a span propagated to child spans and other services
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	// Sampled reports whether the spans of the trace are exported
+	Sampled bool
+}
+
+// IsValid reports whether both IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// Kind describes the relationship of a span to its parent
+type Kind string
+
+// Span kinds
+const (
+	KindInternal Kind = "internal"
+	KindServer   Kind = "server"
+	KindClient   Kind = "client"
+)
+
+// SpanData is a finished span as handed to an Exporter
+type SpanData struct {
+	Name         string                 `json:"name"`
+	Kind         Kind                   `json:"kind"`
+	TraceID      TraceID                `json:"trace_id"`
+	SpanID       SpanID                 `json:"span_id"`
+	ParentSpanID SpanID                 `json:"parent_span_id"`
+	Start        time.Time              `json:"start"`
+	End          time.Time              `json:"end"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	// Error is set when the work the span covers failed
+	Error string `json:"error,omitempty"`
+}
+
+// Exporter receives spans as they end. Only sampled spans are exported.
+type Exporter interface {
+	ExportSpan(span SpanData)
+}
+
+// Tracer starts spans and hands them to its exporter when they end
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer creates a tracer exporting to exporter, which may be nil to only propagate traces
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Span is a unit of work in a trace. All methods are safe to call on a nil span.
+type Span struct {
+	mu      sync.Mutex
+	tracer  *Tracer
+	sampled bool
+	data    SpanData
+	ended   bool
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// FromContext returns the span stored in ctx, or nil if there is none
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemote returns a context whose spans are children of a span of another service
+func ContextWithRemote(ctx context.Context, parent SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, parent)
+}
+
+// SpanContextFromContext returns the context of the current span in ctx, either local or
+// remote, and false if there is none
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span := FromContext(ctx); span != nil {
+		return span.SpanContext(), true
+	}
+	remote, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return remote, ok && remote.IsValid()
+}
+
+// Start starts a span as a child of the current span in ctx, or of a new trace if
+// there is none, and returns a context holding the new span
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	span := &Span{tracer: t, sampled: true, data: SpanData{Name: name, Kind: kind, Start: time.Now()}}
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.data.TraceID = parent.TraceID
+		span.data.ParentSpanID = parent.SpanID
+		span.sampled = parent.Sampled
+	} else {
+		span.data.TraceID = newTraceID()
+	}
+	span.data.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanContext returns the IDs of the span for propagation
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return SpanContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID, Sampled: s.sampled}
+}
+
+// SetName replaces the name the span was started with
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Name = name
+}
+
+// SetAttribute records a string, number or boolean describing the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]interface{}{}
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err. A nil err is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Error = err.Error()
+}
+
+// End finishes the span and exports it if it is sampled. Later calls have no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.sampled && s.tracer != nil && s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(data)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for id == (TraceID{}) {
+		randomize(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for id == (SpanID{}) {
+		randomize(id[:])
+	}
+	return id
+}
+
+func randomize(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		// Reaching this indicates the system random source is broken
+		panic(err)
+	}
+}
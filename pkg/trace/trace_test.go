@@ -0,0 +1,232 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	tassert "github.com/stretchr/testify/assert"
+)
+
+const validTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+
+	// when
+	sc, err := ParseTraceparent(validTraceparent)
+
+	// then
+	assert.NoError(err)
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal("00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(sc.Sampled, "Flag 01 should be sampled")
+	assert.Equal(validTraceparent, FormatTraceparent(sc), "Formatting should round trip")
+}
+
+func TestParseTraceparentVersions(t *testing.T) {
+	cases := map[string]bool{
+		validTraceparent: true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00":     true,
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc": true,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-abc": false,
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01abc":  false,
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":     false,
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01":     false,
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01":     false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01":     false,
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7":        false,
+		"00_4bf92f3577b34da6a3ce929d0e0e4736_00f067aa0ba902b7_01":     false,
+		"00-4bf92f3577b34da6a3ce929d0e0e473g-00f067aa0ba902b7-01":     false,
+		"": false,
+	}
+	for value, valid := range cases {
+		// when
+		_, err := ParseTraceparent(value)
+
+		// then
+		if valid {
+			tassert.NoError(t, err, "%q should be valid", value)
+		} else {
+			tassert.Error(t, err, "%q should be invalid", value)
+		}
+	}
+}
+
+func TestStartChildSpans(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	exporter := &MemoryExporter{}
+	tracer := NewTracer(exporter)
+
+	// when
+	ctx, parent := tracer.Start(context.Background(), "parent", KindServer)
+	_, child := tracer.Start(ctx, "child", KindInternal)
+	child.SetAttribute("answer", 42)
+	child.SetError(errors.New("failed"))
+	child.End()
+	child.End()
+	parent.End()
+
+	// then
+	spans := exporter.Spans()
+	if assert.Len(spans, 2, "Each span should be exported once") {
+		assert.Equal("child", spans[0].Name)
+		assert.Equal(spans[1].TraceID, spans[0].TraceID, "Child should share the trace of its parent")
+		assert.Equal(spans[1].SpanID, spans[0].ParentSpanID, "Child should point to its parent")
+		assert.Equal(SpanID{}, spans[1].ParentSpanID, "Root span should have no parent")
+		assert.Equal(42, spans[0].Attributes["answer"])
+		assert.Equal("failed", spans[0].Error)
+		assert.False(spans[0].End.Before(spans[0].Start), "Span should end after it starts")
+	}
+}
+
+func TestRemoteParent(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	exporter := &MemoryExporter{}
+	tracer := NewTracer(exporter)
+	header := http.Header{}
+	header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	// when
+	ctx, span := tracer.Start(Extract(context.Background(), header), "unsampled", KindServer)
+	span.End()
+	out := http.Header{}
+	Inject(ctx, out)
+
+	// then
+	assert.Empty(exporter.Spans(), "Spans of unsampled traces should not be exported")
+	sc, err := ParseTraceparent(out.Get(TraceparentHeader))
+	assert.NoError(err, "Injected traceparent should be valid")
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String(), "Trace should be continued")
+	assert.Equal(span.SpanContext().SpanID, sc.SpanID, "Injected parent should be the current span")
+	assert.False(sc.Sampled, "Sampling decision should be propagated")
+}
+
+func TestNilSpan(t *testing.T) {
+	// given
+	var span *Span
+
+	// when, then
+	tassert.NotPanics(t, func() {
+		span.SetName("name")
+		span.SetAttribute("key", "value")
+		span.SetError(errors.New("failed"))
+		span.End()
+	}, "Nil spans should be ignored")
+}
+
+func TestMiddleware(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	exporter := &MemoryExporter{}
+	router := chi.NewRouter()
+	router.Use(Middleware(NewTracer(exporter)))
+	var handlerSpan SpanContext
+	router.Route("/api/pet/{id}", func(r chi.Router) {
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			handlerSpan, _ = SpanContextFromContext(r.Context())
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+	})
+	req, _ := http.NewRequest("GET", "/api/pet/7", nil)
+	req.Header.Set(TraceparentHeader, validTraceparent)
+
+	// when
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	// then
+	spans := exporter.Spans()
+	if assert.Len(spans, 1) {
+		span := spans[0]
+		assert.Equal("GET /api/pet/{id}", span.Name, "Span should be named after the route")
+		assert.Equal(KindServer, span.Kind)
+		assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID.String(), "Trace should be continued")
+		assert.Equal("00f067aa0ba902b7", span.ParentSpanID.String(), "Remote span should be the parent")
+		assert.Equal(span.SpanID, handlerSpan.SpanID, "Handler should see the server span")
+		assert.Equal(http.StatusInternalServerError, span.Attributes["http.status_code"])
+		assert.Equal("/api/pet/7", span.Attributes["http.target"])
+		assert.NotEmpty(span.Error, "Server errors should mark the span as failed")
+	}
+}
+
+func TestWriterExporter(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	var out bytes.Buffer
+	tracer := NewTracer(NewWriterExporter(&out))
+
+	// when
+	_, span := tracer.Start(context.Background(), "written", KindInternal)
+	span.End()
+
+	// then
+	var written map[string]interface{}
+	assert.NoError(json.Unmarshal(out.Bytes(), &written), "Span should be written as JSON")
+	assert.Equal("written", written["name"])
+	assert.Equal(span.SpanContext().TraceID.String(), written["trace_id"], "IDs should be written as hex")
+}
+
+func TestOTLPExporter(t *testing.T) {
+	// given
+	assert := tassert.New(t)
+	var (
+		mu       sync.Mutex
+		requests []map[string]interface{}
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var request map[string]interface{}
+		if err := json.Unmarshal(body, &request); err != nil || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+	exporter := NewOTLPExporter(OTLPConfig{
+		Endpoint:      collector.URL + "/v1/traces",
+		ServiceName:   "petserver",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	tracer := NewTracer(exporter)
+
+	// when
+	for i := 0; i < 3; i++ {
+		_, span := tracer.Start(context.Background(), "span", KindServer)
+		span.SetAttribute("http.status_code", 200)
+		span.End()
+	}
+	assert.NoError(exporter.Close())
+
+	// then
+	mu.Lock()
+	defer mu.Unlock()
+	if !assert.Len(requests, 2, "A full batch and the remaining span should be sent") {
+		return
+	}
+	resourceSpans := requests[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	encoded, _ := json.Marshal(resourceSpans)
+	assert.Contains(string(encoded), `{"key":"service.name","value":{"stringValue":"petserver"}}`)
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	assert.Len(spans, 2, "First request should hold a full batch")
+	span := spans[0].(map[string]interface{})
+	assert.Equal(float64(2), span["kind"], "Server spans should have kind 2")
+	assert.Len(span["traceId"], 32, "Trace ID should be hex encoded")
+	assert.True(strings.Contains(string(encoded), `"intValue":"200"`), "Integers should be encoded as strings")
+}